@@ -13,6 +13,7 @@ import (
 
 	"github.com/certwatch-app/cw-agent/internal/certmanager"
 	"github.com/certwatch-app/cw-agent/internal/certmanager/config"
+	"github.com/certwatch-app/cw-agent/internal/secret"
 	"github.com/certwatch-app/cw-agent/internal/state"
 	"github.com/certwatch-app/cw-agent/internal/version"
 )
@@ -124,14 +125,16 @@ func loadConfig() (*config.Config, error) {
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
-	// Explicitly bind API key from environment variable
+	// Explicitly bind API key from environment variables
 	//nolint:errcheck // BindEnv always succeeds when args are valid
 	v.BindEnv("api.key", "CW_API_KEY")
+	//nolint:errcheck // BindEnv always succeeds when args are valid
+	v.BindEnv("api.key_file", "CW_API_KEY_FILE")
 
-	// Load config file if provided
+	// Load config file if provided, expanding ${VAR} references
 	if cfgFile != "" {
 		v.SetConfigFile(cfgFile)
-		if err := v.ReadInConfig(); err != nil {
+		if err := secret.ReadInConfig(v); err != nil {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 	}
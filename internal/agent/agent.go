@@ -6,14 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	gosync "sync"
 	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/certwatch-app/cw-agent/internal/alerting"
 	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/history"
 	"github.com/certwatch-app/cw-agent/internal/metrics"
+	"github.com/certwatch-app/cw-agent/internal/outbox"
 	"github.com/certwatch-app/cw-agent/internal/scanner"
 	"github.com/certwatch-app/cw-agent/internal/server"
 	"github.com/certwatch-app/cw-agent/internal/state"
@@ -25,17 +30,37 @@ import (
 type Agent struct {
 	config       *config.Config
 	scanner      *scanner.Scanner
-	client       *sync.Client
+	client       *sync.Client // nil in offline mode
 	stateManager *state.Manager
 	logger       *zap.Logger
 	server       *server.Server
-	lastScan     []scanner.ScanResult
+	control      *server.ControlServer // nil if the control API is disabled
+	scheduler    *scheduler
+	alerts       *alerting.Manager
+	history      *history.Store // nil if the history is disabled
+	notifyCh     chan struct{}  // Signals sendAlerts that a scan finished
+	loadConfig   ConfigLoader
+	reloadCh     chan string
+	controlCh    chan controlRequest
+	triggerCh    chan string // Requests an immediate scan and sync
+	dumpCh       chan string // Requests a state dump
+	dumpFile     string      // State dumps are logged if empty
+	logLevel     zap.AtomicLevel
+
+	// Targets managed in CertWatch, merged into config.Certificates
+	remoteTargets []config.RemoteTarget
+	remoteETag    string
+
+	// Latest scan result per target, keyed by hostname:port
+	results   map[string]scanner.ScanResult
+	resultsMu gosync.RWMutex
 }
 
 // New creates a new Agent with the given configuration and state manager
 func New(cfg *config.Config, stateManager *state.Manager) (*Agent, error) {
-	// Setup logger
-	logger, err := setupLogger(cfg.Agent.LogLevel)
+	// Setup logger; the level can be changed on reload
+	logLevel := zap.NewAtomicLevelAt(parseLogLevel(cfg.Agent.LogLevel))
+	logger, err := setupLogger(logLevel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup logger: %w", err)
 	}
@@ -43,8 +68,35 @@ func New(cfg *config.Config, stateManager *state.Manager) (*Agent, error) {
 	// Create scanner
 	s := scanner.New(cfg.API.Timeout, cfg.Agent.Concurrency, logger)
 
-	// Create sync client with state manager
-	client := sync.New(cfg, logger, stateManager)
+	// Create sync client with state manager, unless running without the API
+	var client *sync.Client
+	if !cfg.Agent.Offline {
+		client = sync.New(cfg, logger, stateManager)
+
+		// Payloads are queued on disk, so they survive API outages and restarts
+		if cfg.API.OutboxSizeMB > 0 {
+			o, err := outbox.Open(filepath.Join(stateManager.Dir(), outbox.DirName), int64(cfg.API.OutboxSizeMB)<<20)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open outbox: %w", err)
+			}
+			client.SetOutbox(o)
+		}
+	}
+
+	// Create alert manager; firing alerts are restored from the state file
+	alerts, err := alerting.New(cfg, stateManager, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup alerting: %w", err)
+	}
+
+	// Open the scan history in the state directory if enabled
+	var hist *history.Store
+	if cfg.History.Enabled() {
+		hist, err = history.Open(stateManager.Dir(), cfg.History.Retention, cfg.History.MaxEntries)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open scan history: %w", err)
+		}
+	}
 
 	// Create metrics/health server if enabled
 	var srv *server.Server
@@ -52,14 +104,32 @@ func New(cfg *config.Config, stateManager *state.Manager) (*Agent, error) {
 		srv = server.New(cfg.Agent.MetricsPort, logger)
 	}
 
-	return &Agent{
+	a := &Agent{
 		config:       cfg,
 		scanner:      s,
 		client:       client,
 		stateManager: stateManager,
 		logger:       logger,
 		server:       srv,
-	}, nil
+		scheduler:    newScheduler(cfg),
+		alerts:       alerts,
+		history:      hist,
+		notifyCh:     make(chan struct{}, 1),
+		reloadCh:     make(chan string, 1),
+		controlCh:    make(chan controlRequest),
+		triggerCh:    make(chan string, 1),
+		dumpCh:       make(chan string, 1),
+		logLevel:     logLevel,
+		results:      make(map[string]scanner.ScanResult),
+	}
+
+	// Create the control API server if enabled; it reads results from and
+	// triggers scans and syncs on the agent
+	if cfg.ControlAPI.Enabled() {
+		a.control = server.NewControl(cfg.ControlAPI.Listen, cfg.ControlAPI.Token, a, logger)
+	}
+
+	return a, nil
 }
 
 // Run starts the agent main loop
@@ -69,6 +139,8 @@ func (a *Agent) Run(ctx context.Context) error {
 		zap.Int("certificates", len(a.config.Certificates)),
 		zap.Duration("sync_interval", a.config.Agent.SyncInterval),
 		zap.Duration("scan_interval", a.config.Agent.ScanInterval),
+		zap.Bool("adaptive_scan", a.config.Agent.AdaptiveScan),
+		zap.Bool("offline", a.config.Agent.Offline),
 	)
 
 	// Set initial metrics
@@ -86,6 +158,50 @@ func (a *Agent) Run(ctx context.Context) error {
 		}()
 	}
 
+	// Start the control API server if enabled; requests are served by the
+	// main loop below
+	if a.control != nil {
+		if err := a.control.Start(); err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := a.control.Shutdown(shutdownCtx); err != nil {
+				a.logger.Error("failed to shutdown control API", zap.Error(err))
+			}
+		}()
+	}
+
+	if a.history != nil {
+		defer func() {
+			if err := a.history.Close(); err != nil {
+				a.logger.Error("failed to close scan history", zap.Error(err))
+			}
+		}()
+	}
+
+	// Start with the cached remote targets, so they are monitored even if
+	// the API can't be reached
+	if a.config.Agent.RemoteTargets {
+		a.loadCachedTargets()
+		a.refreshRemoteTargets(ctx)
+	}
+
+	// Alerts are sent in the background, so slow receivers don't delay
+	// scans or reloads
+	go a.sendAlerts(ctx)
+
+	// Payloads queued while the API couldn't be reached are retried in the
+	// background
+	if a.client != nil {
+		go a.client.RunOutbox(ctx)
+	}
+
+	// Register all targets with the scheduler; the initial scan below
+	// records their results and spreads out their next scans
+	a.scheduler.SetTargets(a.config, time.Now())
+
 	// Perform initial scan and sync
 	if err := a.scanAndSync(ctx); err != nil {
 		a.logger.Error("initial sync failed", zap.Error(err))
@@ -102,21 +218,35 @@ func (a *Agent) Run(ctx context.Context) error {
 	}
 	metrics.SetAgentInfo(version.GetVersion(), a.config.Agent.Name, agentID)
 
-	// Setup tickers
-	syncTicker := time.NewTicker(a.config.Agent.SyncInterval)
-	scanTicker := time.NewTicker(a.config.Agent.ScanInterval)
-	defer syncTicker.Stop()
-	defer scanTicker.Stop()
+	// Setup scan timer (reset to the next due target after each scan)
+	scanTimer := time.NewTimer(a.untilNextScan())
+	defer scanTimer.Stop()
+
+	// Setup sync ticker unless offline
+	var syncTicker *time.Ticker
+	var syncChan <-chan time.Time
+	if a.client != nil {
+		syncTicker = time.NewTicker(a.config.Agent.SyncInterval)
+		defer syncTicker.Stop()
+		syncChan = syncTicker.C
+	} else {
+		a.logger.Info("offline mode, not syncing with CertWatch")
+	}
 
 	// Setup heartbeat ticker if enabled
 	var heartbeatTicker *time.Ticker
 	var heartbeatChan <-chan time.Time
-	if a.config.Agent.HeartbeatInterval > 0 {
+	if a.client != nil && a.config.Agent.HeartbeatInterval > 0 {
 		heartbeatTicker = time.NewTicker(a.config.Agent.HeartbeatInterval)
 		heartbeatChan = heartbeatTicker.C
-		defer heartbeatTicker.Stop()
 		a.logger.Info("heartbeat enabled", zap.Duration("interval", a.config.Agent.HeartbeatInterval))
 	}
+	defer func() {
+		// The heartbeat ticker may be replaced on reload
+		if heartbeatTicker != nil {
+			heartbeatTicker.Stop()
+		}
+	}()
 
 	// Start uptime counter
 	go a.trackUptime(ctx)
@@ -128,14 +258,22 @@ func (a *Agent) Run(ctx context.Context) error {
 			server.SetReady(false)
 			return ctx.Err()
 
-		case <-scanTicker.C:
-			a.logger.Debug("scan interval triggered")
-			if err := a.scan(ctx); err != nil {
-				a.logger.Error("scan failed", zap.Error(err))
+		case <-scanTimer.C:
+			due := a.scheduler.Due(time.Now())
+			if len(due) > 0 {
+				a.logger.Debug("scheduled scan triggered", zap.Int("due", len(due)))
+				if err := a.scanTargets(ctx, due); err != nil {
+					a.logger.Error("scan failed", zap.Error(err))
+				}
 			}
+			scanTimer.Reset(a.untilNextScan())
 
-		case <-syncTicker.C:
+		case <-syncChan:
 			a.logger.Debug("sync interval triggered")
+			if a.refreshRemoteTargets(ctx) {
+				// New targets are due immediately
+				scanTimer.Reset(a.untilNextScan())
+			}
 			if err := a.syncWithCloud(ctx); err != nil {
 				a.logger.Error("sync failed", zap.Error(err))
 			}
@@ -145,6 +283,49 @@ func (a *Agent) Run(ctx context.Context) error {
 			if err := a.sendHeartbeat(ctx); err != nil {
 				a.logger.Error("heartbeat failed", zap.Error(err))
 			}
+
+		case req := <-a.controlCh:
+			req.done <- a.handleControl(ctx, req)
+			// Scanned targets were rescheduled
+			scanTimer.Reset(a.untilNextScan())
+
+		case trigger := <-a.triggerCh:
+			a.logger.Info("immediate scan triggered", zap.String("trigger", trigger))
+			if err := a.scanAndSync(ctx); err != nil {
+				a.logger.Error("triggered scan failed", zap.Error(err))
+			}
+			scanTimer.Reset(a.untilNextScan())
+
+		case trigger := <-a.dumpCh:
+			a.writeStateDump(trigger)
+
+		case trigger := <-a.reloadCh:
+			old, ok := a.reload(trigger)
+			if !ok {
+				continue
+			}
+
+			if a.config.Agent.RemoteTargets && !old.Agent.RemoteTargets {
+				a.refreshRemoteTargets(ctx)
+			}
+
+			// New targets are due immediately
+			scanTimer.Reset(a.untilNextScan())
+
+			if syncTicker != nil && a.config.Agent.SyncInterval != old.Agent.SyncInterval {
+				syncTicker.Reset(a.config.Agent.SyncInterval)
+			}
+
+			if interval := a.config.Agent.HeartbeatInterval; a.client != nil && interval != old.Agent.HeartbeatInterval {
+				if heartbeatTicker != nil {
+					heartbeatTicker.Stop()
+					heartbeatTicker, heartbeatChan = nil, nil
+				}
+				if interval > 0 {
+					heartbeatTicker = time.NewTicker(interval)
+					heartbeatChan = heartbeatTicker.C
+				}
+			}
 		}
 	}
 }
@@ -155,6 +336,10 @@ func (a *Agent) scanAndSync(ctx context.Context) error {
 		return fmt.Errorf("scan failed: %w", err)
 	}
 
+	if a.client == nil {
+		return nil
+	}
+
 	if err := a.syncWithCloud(ctx); err != nil {
 		return fmt.Errorf("sync failed: %w", err)
 	}
@@ -162,53 +347,44 @@ func (a *Agent) scanAndSync(ctx context.Context) error {
 	return nil
 }
 
-// scan performs certificate scanning
+// scan performs a scan of all configured certificates
 func (a *Agent) scan(ctx context.Context) error {
+	return a.scanTargets(ctx, a.config.Certificates)
+}
+
+// scanTargets scans the given certificates, records their results and
+// reschedules them
+func (a *Agent) scanTargets(ctx context.Context, certs []config.CertificateConfig) error {
 	start := time.Now()
 	a.logger.Info("starting certificate scan",
-		zap.Int("certificates", len(a.config.Certificates)),
+		zap.Int("certificates", len(certs)),
 	)
 
-	results := a.scanner.ScanAll(ctx, a.config.Certificates)
-	a.lastScan = results
-
 	// Count successes and failures, update metrics
 	successCount := 0
 	failCount := 0
-	scanDuration := time.Since(start).Seconds() / float64(len(a.config.Certificates))
 
-	for _, r := range results {
-		portStr := strconv.Itoa(r.Port)
+	// Alerts carry the tags of their target
+	tags := make(map[string][]string, len(certs))
+	for i := range certs {
+		tags[certs[i].GetHostPort()] = certs[i].Tags
+	}
 
+	// Results are processed as they stream in, so memory use doesn't
+	// grow with the number of targets being scanned
+	for r := range a.scanner.ScanStream(ctx, scanner.Feed(certs)) {
+		a.recordResult(&r)
+		a.alerts.Observe(&r, tags[resultKey(r.Hostname, r.Port)], time.Now())
 		if r.Success {
 			successCount++
-			metrics.RecordScanSuccess(r.Hostname, scanDuration)
-
-			// Update certificate metrics
-			if r.Certificate != nil {
-				daysUntilExpiry := float64(r.Certificate.DaysUntilExpiry)
-				expiryTimestamp := float64(r.Certificate.NotAfter.Unix())
-
-				// Determine validity: certificate is valid if it hasn't expired
-				valid := r.Certificate.DaysUntilExpiry >= 0
-
-				// Determine chain validity
-				chainValid := r.Chain != nil && r.Chain.Valid
-
-				metrics.RecordCertificateMetrics(
-					r.Hostname,
-					portStr,
-					daysUntilExpiry,
-					expiryTimestamp,
-					valid,
-					chainValid,
-				)
-			}
 		} else {
 			failCount++
-			metrics.RecordScanFailure(r.Hostname, scanDuration)
 		}
 	}
+	select {
+	case a.notifyCh <- struct{}{}:
+	default: // A notification is already pending
+	}
 
 	// Record scan time for health checks
 	server.RecordScan()
@@ -222,19 +398,106 @@ func (a *Agent) scan(ctx context.Context) error {
 	return nil
 }
 
+// recordResult stores a scan result, reschedules its target and updates metrics
+func (a *Agent) recordResult(r *scanner.ScanResult) {
+	a.resultsMu.Lock()
+	a.results[resultKey(r.Hostname, r.Port)] = *r
+	a.resultsMu.Unlock()
+
+	a.scheduler.Record(r, time.Now())
+
+	if a.history != nil {
+		if err := a.history.Record(r); err != nil {
+			a.logger.Warn("failed to record scan history", zap.Error(err))
+		}
+	}
+
+	portStr := strconv.Itoa(r.Port)
+	scanDuration := r.Timings.Total.Seconds()
+	metrics.RecordScanPhases(r.Hostname,
+		r.Timings.DNSLookup.Seconds(),
+		r.Timings.TCPConnect.Seconds(),
+		r.Timings.TLSHandshake.Seconds(),
+	)
+
+	if !r.Success {
+		metrics.RecordScanFailure(r.Hostname, scanDuration)
+		return
+	}
+
+	metrics.RecordScanSuccess(r.Hostname, scanDuration)
+
+	// Update certificate metrics
+	if r.Certificate != nil {
+		daysUntilExpiry := float64(r.Certificate.DaysUntilExpiry)
+		expiryTimestamp := float64(r.Certificate.NotAfter.Unix())
+
+		// Determine validity: certificate is valid if it hasn't expired
+		valid := r.Certificate.DaysUntilExpiry >= 0
+
+		// Determine chain validity
+		chainValid := r.Chain != nil && r.Chain.Valid
+
+		metrics.RecordCertificateMetrics(
+			r.Hostname,
+			portStr,
+			daysUntilExpiry,
+			expiryTimestamp,
+			valid,
+			chainValid,
+		)
+	}
+
+	metrics.RecordTLSMetrics(r.Hostname, portStr, r.TLSVersion, r.KeyExchange, r.PostQuantum)
+}
+
+// untilNextScan returns how long to wait until the next target is due
+func (a *Agent) untilNextScan() time.Duration {
+	next, ok := a.scheduler.NextDue()
+	if !ok {
+		return a.config.Agent.ScanInterval
+	}
+	return max(time.Until(next), 0)
+}
+
+// lastResults returns the latest scan result of every target
+func (a *Agent) lastResults() []scanner.ScanResult {
+	a.resultsMu.RLock()
+	defer a.resultsMu.RUnlock()
+
+	results := make([]scanner.ScanResult, 0, len(a.results))
+	for _, r := range a.results {
+		results = append(results, r)
+	}
+	return results
+}
+
+func resultKey(hostname string, port int) string {
+	return fmt.Sprintf("%s:%d", hostname, port)
+}
+
 // syncWithCloud sends scan results to the CertWatch API
 func (a *Agent) syncWithCloud(ctx context.Context) error {
-	if a.lastScan == nil {
+	// Only possible with remote targets that couldn't be fetched yet; an
+	// empty sync would orphan every certificate of the agent
+	if len(a.config.Certificates) == 0 {
+		a.logger.Warn("no targets to monitor, skipping sync")
+		return nil
+	}
+
+	results := a.lastResults()
+	if len(results) == 0 {
 		a.logger.Debug("no scan results to sync, performing scan first")
 		if err := a.scan(ctx); err != nil {
 			return err
 		}
+		results = a.lastResults()
 	}
 
 	start := time.Now()
 	a.logger.Info("syncing with cloud")
 
-	resp, err := a.client.Sync(ctx, a.config.Certificates, a.lastScan)
+	resp, err := a.client.Sync(ctx, a.config.Certificates, results)
 	duration := time.Since(start).Seconds()
 
 	if err != nil {
@@ -317,6 +580,18 @@ func (a *Agent) sendHeartbeat(ctx context.Context) error {
 	return nil
 }
 
+// sendAlerts notifies the alert receivers after each scan until ctx is done
+func (a *Agent) sendAlerts(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.notifyCh:
+			a.alerts.Notify(ctx, time.Now())
+		}
+	}
+}
+
 // trackUptime increments the uptime counter every second
 func (a *Agent) trackUptime(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
@@ -332,22 +607,24 @@ func (a *Agent) trackUptime(ctx context.Context) {
 	}
 }
 
-// setupLogger creates a configured zap logger
-func setupLogger(level string) (*zap.Logger, error) {
-	// Parse log level
-	var zapLevel zapcore.Level
+// parseLogLevel converts a configured log level to a zap level
+func parseLogLevel(level string) zapcore.Level {
 	switch level {
 	case "debug":
-		zapLevel = zapcore.DebugLevel
+		return zapcore.DebugLevel
 	case "info":
-		zapLevel = zapcore.InfoLevel
+		return zapcore.InfoLevel
 	case "warn":
-		zapLevel = zapcore.WarnLevel
+		return zapcore.WarnLevel
 	case "error":
-		zapLevel = zapcore.ErrorLevel
+		return zapcore.ErrorLevel
 	default:
-		zapLevel = zapcore.InfoLevel
+		return zapcore.InfoLevel
 	}
+}
+
+// setupLogger creates a configured zap logger
+func setupLogger(level zap.AtomicLevel) (*zap.Logger, error) {
 
 	// Create encoder config
 	encoderConfig := zapcore.EncoderConfig{
@@ -368,7 +645,7 @@ func setupLogger(level string) (*zap.Logger, error) {
 	core := zapcore.NewCore(
 		zapcore.NewConsoleEncoder(encoderConfig),
 		zapcore.AddSync(os.Stdout),
-		zapLevel,
+		level,
 	)
 
 	return zap.New(core), nil
@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/state"
+)
+
+// newTestAgent returns an agent monitoring a.example.com:443 and
+// b.example.com:443 with its targets scheduled and a state directory of its
+// own. If set, modify adjusts the configuration before the agent is created.
+func newTestAgent(t *testing.T, modify func(*config.Config)) *Agent {
+	t.Helper()
+
+	cfg := &config.Config{
+		API: config.APIConfig{Endpoint: "https://api.example.com", Key: "cw_test", Timeout: time.Second},
+		Agent: config.AgentConfig{
+			Name:         "agent",
+			LogLevel:     "info",
+			ScanInterval: time.Minute,
+			SyncInterval: 5 * time.Minute,
+			Concurrency:  1,
+		},
+		Certificates: []config.CertificateConfig{
+			{Hostname: "a.example.com", Port: 443},
+			{Hostname: "b.example.com", Port: 443},
+		},
+	}
+	if modify != nil {
+		modify(cfg)
+	}
+
+	a, err := New(cfg, state.NewManagerWithStateDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	a.scheduler.SetTargets(cfg, time.Now())
+	return a
+}
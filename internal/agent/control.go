@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"sort"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/server"
+)
+
+// controlRequest asks the main loop for a scan or sync on demand
+type controlRequest struct {
+	done   chan controlReply
+	target string // hostname:port to scan; every target if empty
+	sync   bool   // Sync instead of scanning
+}
+
+type controlReply struct {
+	err     error
+	results []scanner.ScanResult
+}
+
+// Results returns the latest scan result of every target, ordered by
+// hostname:port
+func (a *Agent) Results() []scanner.ScanResult {
+	results := a.lastResults()
+	sort.Slice(results, func(i, j int) bool {
+		return resultKey(results[i].Hostname, results[i].Port) < resultKey(results[j].Hostname, results[j].Port)
+	})
+	return results
+}
+
+// TriggerScan requests an immediate scan and sync of every target from the
+// agent's main loop. It doesn't block; a scan that is already pending
+// covers this request.
+func (a *Agent) TriggerScan(trigger string) {
+	select {
+	case a.triggerCh <- trigger:
+	default:
+	}
+}
+
+// Scan scans a target given as hostname:port, or every target if target is
+// empty, in the agent's main loop and returns the new results. The scan
+// completes even if ctx is done before.
+func (a *Agent) Scan(ctx context.Context, target string) ([]scanner.ScanResult, error) {
+	reply, err := a.request(ctx, controlRequest{target: target})
+	return reply.results, err
+}
+
+// Sync syncs the latest results to CertWatch in the agent's main loop
+func (a *Agent) Sync(ctx context.Context) error {
+	_, err := a.request(ctx, controlRequest{sync: true})
+	return err
+}
+
+// request sends a control request to the main loop and waits for its reply
+func (a *Agent) request(ctx context.Context, req controlRequest) (controlReply, error) {
+	req.done = make(chan controlReply, 1)
+	select {
+	case a.controlCh <- req:
+	case <-ctx.Done():
+		return controlReply{}, ctx.Err()
+	}
+	select {
+	case reply := <-req.done:
+		return reply, reply.err
+	case <-ctx.Done():
+		return controlReply{}, ctx.Err()
+	}
+}
+
+// handleControl serves a control request in the main loop
+func (a *Agent) handleControl(ctx context.Context, req controlRequest) controlReply {
+	if req.sync {
+		if a.client == nil {
+			return controlReply{err: server.ErrOffline}
+		}
+		return controlReply{err: a.syncWithCloud(ctx)}
+	}
+
+	certs := a.config.Certificates
+	if req.target != "" {
+		certs = nil
+		for i := range a.config.Certificates {
+			if a.config.Certificates[i].GetHostPort() == req.target {
+				certs = []config.CertificateConfig{a.config.Certificates[i]}
+				break
+			}
+		}
+		if certs == nil {
+			return controlReply{err: server.ErrUnknownTarget}
+		}
+	}
+
+	if err := a.scanTargets(ctx, certs); err != nil {
+		return controlReply{err: err}
+	}
+
+	a.resultsMu.RLock()
+	defer a.resultsMu.RUnlock()
+	results := make([]scanner.ScanResult, 0, len(certs))
+	for i := range certs {
+		if r, ok := a.results[certs[i].GetHostPort()]; ok {
+			results = append(results, r)
+		}
+	}
+	return controlReply{results: results}
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/server"
+)
+
+// testControlAgent returns an offline agent monitoring a local TLS server
+// and an unreachable target
+func testControlAgent(t *testing.T) (*Agent, string) {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(srv.Close)
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	a := newTestAgent(t, func(cfg *config.Config) {
+		cfg.Agent.Offline = true
+		cfg.Certificates = []config.CertificateConfig{
+			{Hostname: host, Port: port},
+			{Hostname: "127.0.0.1", Port: 1},
+		}
+	})
+	return a, a.config.Certificates[0].GetHostPort()
+}
+
+// serveControl serves control requests like the main loop until the test ends
+func serveControl(t *testing.T, a *Agent) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case req := <-a.controlCh:
+				req.done <- a.handleControl(ctx, req)
+			}
+		}
+	}()
+}
+
+func TestControl_Scan(t *testing.T) {
+	a, target := testControlAgent(t)
+	serveControl(t, a)
+	ctx := context.Background()
+
+	results, err := a.Scan(ctx, target)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(results) != 1 || !results[0].Success {
+		t.Fatalf("Scan() = %+v, want a successful result of the target", results)
+	}
+	if got := a.Results(); len(got) != 1 {
+		t.Errorf("Results() = %d results, want only the scanned target", len(got))
+	}
+
+	results, err = a.Scan(ctx, "")
+	if err != nil {
+		t.Fatalf("Scan() of all targets error = %v", err)
+	}
+	if len(results) != 2 || results[1].Success {
+		t.Errorf("Scan() of all targets = %+v, want both results in target order", results)
+	}
+	got := a.Results()
+	if len(got) != 2 || got[0].Port != 1 {
+		t.Errorf("Results() = %+v, want both results ordered by hostname:port", got)
+	}
+
+	if _, err := a.Scan(ctx, "other.example.com:443"); !errors.Is(err, server.ErrUnknownTarget) {
+		t.Errorf("Scan() of an unknown target error = %v, want ErrUnknownTarget", err)
+	}
+	if err := a.Sync(ctx); !errors.Is(err, server.ErrOffline) {
+		t.Errorf("Sync() error = %v, want ErrOffline", err)
+	}
+}
+
+func TestControl_CanceledWhileWaiting(t *testing.T) {
+	a, target := testControlAgent(t)
+
+	// Nothing serves the request
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := a.Scan(ctx, target); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Scan() error = %v, want the context error", err)
+	}
+}
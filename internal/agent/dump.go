@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/server"
+	"github.com/certwatch-app/cw-agent/internal/sync"
+	"github.com/certwatch-app/cw-agent/internal/version"
+)
+
+// stateDump is a snapshot of the agent's state for troubleshooting
+// Fields are ordered for optimal memory alignment
+type stateDump struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	LastScan    *time.Time          `json:"last_scan,omitempty"`
+	LastSync    *time.Time          `json:"last_sync,omitempty"`
+	Backlog     *sync.Backlog       `json:"sync_backlog,omitempty"` // nil in offline mode
+	Agent       string              `json:"agent"`
+	AgentID     string              `json:"agent_id,omitempty"`
+	Version     string              `json:"version"`
+	Results     []server.ResultJSON `json:"results"`
+	Schedule    []scheduleJSON      `json:"schedule"`
+	Offline     bool                `json:"offline"`
+}
+
+// scheduleJSON is the scheduling state of a target in a state dump
+type scheduleJSON struct {
+	ScheduleInfo
+	Interval string `json:"interval"` // Shadows ScheduleInfo.Interval, e.g. "1m0s"
+}
+
+// SetDumpFile sets the file state dumps are written to. Without a file,
+// they are logged.
+func (a *Agent) SetDumpFile(path string) {
+	a.dumpFile = path
+}
+
+// DumpState requests a dump of the current results, scheduler state and
+// sync backlog from the agent's main loop. It doesn't block; a dump that is
+// already pending covers this request.
+func (a *Agent) DumpState(trigger string) {
+	select {
+	case a.dumpCh <- trigger:
+	default:
+	}
+}
+
+// newStateDump collects the state of the agent
+func (a *Agent) newStateDump(now time.Time) *stateDump {
+	d := &stateDump{
+		GeneratedAt: now,
+		Agent:       a.config.Agent.Name,
+		AgentID:     a.stateManager.GetAgentID(),
+		Version:     version.GetVersion(),
+		Offline:     a.config.Agent.Offline,
+	}
+	if t, ok := server.GetLastScan(); ok {
+		d.LastScan = &t
+	}
+	if t, ok := server.GetLastSync(); ok {
+		d.LastSync = &t
+	}
+	if a.client != nil {
+		backlog := a.client.Backlog()
+		d.Backlog = &backlog
+	}
+
+	results := a.Results()
+	d.Results = make([]server.ResultJSON, 0, len(results))
+	for i := range results {
+		d.Results = append(d.Results, server.NewResultJSON(&results[i]))
+	}
+
+	schedule := a.scheduler.Snapshot()
+	sort.Slice(schedule, func(i, j int) bool { return schedule[i].Target < schedule[j].Target })
+	d.Schedule = make([]scheduleJSON, 0, len(schedule))
+	for _, info := range schedule {
+		d.Schedule = append(d.Schedule, scheduleJSON{ScheduleInfo: info, Interval: info.Interval.String()})
+	}
+	return d
+}
+
+// writeStateDump writes a state dump to the dump file, or logs it
+func (a *Agent) writeStateDump(trigger string) {
+	d := a.newStateDump(time.Now())
+	if a.dumpFile == "" {
+		a.logger.Info("state dump", zap.String("trigger", trigger), zap.Reflect("state", d))
+		return
+	}
+
+	if err := writeFileAtomic(a.dumpFile, d); err != nil {
+		a.logger.Error("failed to write state dump", zap.String("file", a.dumpFile), zap.Error(err))
+		return
+	}
+	a.logger.Info("state dump written", zap.String("trigger", trigger), zap.String("file", a.dumpFile))
+}
+
+// writeFileAtomic writes v as indented JSON to a temporary file and renames
+// it to path, so readers never see a partial dump
+func writeFileAtomic(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state dump: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteStateDump(t *testing.T) {
+	a, target := testControlAgent(t)
+	if err := a.scanTargets(context.Background(), a.config.Certificates); err != nil {
+		t.Fatalf("scanTargets() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.json")
+	a.SetDumpFile(path)
+	a.writeStateDump("test")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("dump not written: %v", err)
+	}
+	var got struct {
+		SyncBacklog *json.RawMessage `json:"sync_backlog"`
+		Agent       string           `json:"agent"`
+		Results     []struct {
+			Target  string `json:"target"`
+			Success bool   `json:"success"`
+		} `json:"results"`
+		Schedule []struct {
+			Target   string `json:"target"`
+			Interval string `json:"interval"`
+		} `json:"schedule"`
+		Offline bool `json:"offline"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid dump: %v\n%s", err, data)
+	}
+
+	if got.Agent != "agent" || !got.Offline || got.SyncBacklog != nil {
+		t.Errorf("dump = %s, want an offline agent without a sync backlog", data)
+	}
+	if len(got.Results) != 2 || got.Results[1].Target != target || !got.Results[1].Success {
+		t.Errorf("results = %+v, want both targets ordered by hostname:port", got.Results)
+	}
+	if len(got.Schedule) != 2 || got.Schedule[0].Interval == "" || got.Schedule[0].Interval[0] < '0' || got.Schedule[0].Interval[0] > '9' {
+		t.Errorf("schedule = %+v, want both targets with their interval as a duration", got.Schedule)
+	}
+}
+
+func TestTriggerAndDumpDontBlock(t *testing.T) {
+	a, _ := testControlAgent(t)
+
+	// Nothing serves the requests; pending ones cover the repeats
+	a.TriggerScan("SIGUSR1")
+	a.TriggerScan("SIGUSR1")
+	a.DumpState("SIGUSR2")
+	a.DumpState("SIGUSR2")
+	if len(a.triggerCh) != 1 || len(a.dumpCh) != 1 {
+		t.Errorf("pending requests = %d scans, %d dumps, want 1 each", len(a.triggerCh), len(a.dumpCh))
+	}
+}
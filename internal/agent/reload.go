@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/metrics"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// ConfigLoader reads and validates the configuration from its source
+type ConfigLoader func() (*config.Config, error)
+
+// SetConfigLoader sets how the agent re-reads its configuration when a
+// reload is requested. Without a loader, reload requests are ignored.
+func (a *Agent) SetConfigLoader(load ConfigLoader) {
+	a.loadConfig = load
+}
+
+// Reload requests a configuration reload from the agent's main loop.
+// It doesn't block; a reload that is already pending covers this request.
+func (a *Agent) Reload(trigger string) {
+	select {
+	case a.reloadCh <- trigger:
+	default:
+	}
+}
+
+// certDiff describes how the monitored target set changed between two configs
+type certDiff struct {
+	added   []string
+	removed []string
+	changed []string
+}
+
+func (d *certDiff) empty() bool {
+	return len(d.added) == 0 && len(d.removed) == 0 && len(d.changed) == 0
+}
+
+// diffCertificates compares two target lists by hostname:port
+func diffCertificates(old, updated []config.CertificateConfig) certDiff {
+	before := make(map[string]*config.CertificateConfig, len(old))
+	for i := range old {
+		before[old[i].GetHostPort()] = &old[i]
+	}
+
+	var d certDiff
+	seen := make(map[string]bool, len(updated))
+	for i := range updated {
+		key := updated[i].GetHostPort()
+		seen[key] = true
+		prev, ok := before[key]
+		switch {
+		case !ok:
+			d.added = append(d.added, key)
+		case !reflect.DeepEqual(*prev, updated[i]):
+			d.changed = append(d.changed, key)
+		}
+	}
+	for key := range before {
+		if !seen[key] {
+			d.removed = append(d.removed, key)
+		}
+	}
+
+	sort.Strings(d.added)
+	sort.Strings(d.removed)
+	sort.Strings(d.changed)
+	return d
+}
+
+// reload loads a new configuration and applies it. Invalid configurations
+// are rejected and the current one is kept. Returns the previous config
+// when the new one was applied.
+func (a *Agent) reload(trigger string) (*config.Config, bool) {
+	if a.loadConfig == nil {
+		a.logger.Warn("configuration reload requested but not supported", zap.String("trigger", trigger))
+		return nil, false
+	}
+
+	cfg, err := a.loadConfig()
+	if err != nil {
+		metrics.RecordConfigReload(false)
+		a.logger.Error("configuration reload rejected, keeping current configuration",
+			zap.String("trigger", trigger),
+			zap.Error(err),
+		)
+		return nil, false
+	}
+
+	old := a.config
+	a.keepRestartOnlySettings(old, cfg)
+	cfg.Certificates = a.mergeRemoteTargets(cfg)
+	diff := diffCertificates(old.Certificates, cfg.Certificates)
+
+	a.config = cfg
+	a.logLevel.SetLevel(parseLogLevel(cfg.Agent.LogLevel))
+
+	if a.client != nil && (cfg.API.Key != old.API.Key || cfg.API.KeyFile != old.API.KeyFile) {
+		a.client.SetAPIKey(cfg.API.Key, cfg.API.KeyFile)
+		a.logger.Info("API key updated")
+	}
+
+	if cfg.API.Timeout != old.API.Timeout || cfg.Agent.Concurrency != old.Agent.Concurrency {
+		a.scanner = scanner.New(cfg.API.Timeout, cfg.Agent.Concurrency, a.logger)
+	}
+
+	if err := a.alerts.Configure(cfg); err != nil {
+		a.logger.Error("failed to apply alerting settings", zap.Error(err))
+	}
+
+	if a.history != nil {
+		a.history.SetLimits(cfg.History.Retention, cfg.History.MaxEntries)
+	}
+
+	if a.control != nil && cfg.ControlAPI.Token != old.ControlAPI.Token {
+		a.control.SetToken(cfg.ControlAPI.Token)
+		a.logger.Info("control API token updated")
+	}
+
+	a.scheduler.SetTargets(cfg, time.Now())
+	a.forgetTargets(diff.removed)
+	metrics.SetCertificatesConfigured(len(cfg.Certificates))
+	metrics.RecordConfigReload(true)
+
+	if diff.empty() {
+		a.logger.Info("configuration reloaded", zap.String("trigger", trigger))
+	} else {
+		a.logger.Info("configuration reloaded",
+			zap.String("trigger", trigger),
+			zap.Strings("added", diff.added),
+			zap.Strings("removed", diff.removed),
+			zap.Strings("changed", diff.changed),
+		)
+	}
+
+	return old, true
+}
+
+// keepRestartOnlySettings carries over settings that can't change while the
+// agent is running, warning about any that were edited
+func (a *Agent) keepRestartOnlySettings(old, cfg *config.Config) {
+	var ignored []string
+	if cfg.API.Endpoint != old.API.Endpoint {
+		ignored = append(ignored, "api.endpoint")
+		cfg.API.Endpoint = old.API.Endpoint
+	}
+	if cfg.API.OutboxSizeMB != old.API.OutboxSizeMB {
+		ignored = append(ignored, "api.outbox_size_mb")
+		cfg.API.OutboxSizeMB = old.API.OutboxSizeMB
+	}
+	if cfg.Agent.Name != old.Agent.Name {
+		ignored = append(ignored, "agent.name")
+		cfg.Agent.Name = old.Agent.Name
+	}
+	if cfg.Agent.Offline != old.Agent.Offline {
+		ignored = append(ignored, "agent.offline")
+		cfg.Agent.Offline = old.Agent.Offline
+	}
+	if cfg.Agent.MetricsPort != old.Agent.MetricsPort {
+		ignored = append(ignored, "agent.metrics_port")
+		cfg.Agent.MetricsPort = old.Agent.MetricsPort
+	}
+	if cfg.ControlAPI.Listen != old.ControlAPI.Listen {
+		ignored = append(ignored, "control_api.listen")
+		cfg.ControlAPI.Listen = old.ControlAPI.Listen
+	}
+	// The history file is opened at startup; its limits can change
+	if cfg.History.Enabled() != old.History.Enabled() {
+		ignored = append(ignored, "history.retention")
+		cfg.History = old.History
+	}
+
+	if len(ignored) > 0 {
+		a.logger.Warn("configuration changes require a restart and were not applied",
+			zap.Strings("settings", ignored),
+		)
+	}
+}
+
+// forgetTargets drops the stored results and metrics of removed targets
+// so they are neither synced nor exported any longer
+func (a *Agent) forgetTargets(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	a.alerts.Forget(keys)
+
+	a.resultsMu.Lock()
+	defer a.resultsMu.Unlock()
+
+	hostnames := make(map[string]bool)
+	for _, key := range keys {
+		r, ok := a.results[key]
+		if !ok {
+			continue
+		}
+		delete(a.results, key)
+		metrics.DeleteCertificateMetrics(r.Hostname, strconv.Itoa(r.Port))
+		hostnames[r.Hostname] = true
+	}
+
+	// Scan duration series are per hostname, which other ports may share
+	for _, r := range a.results {
+		delete(hostnames, r.Hostname)
+	}
+	for hostname := range hostnames {
+		metrics.DeleteHostMetrics(hostname)
+	}
+}
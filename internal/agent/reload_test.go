@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/metrics"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+func TestDiffCertificates(t *testing.T) {
+	old := []config.CertificateConfig{
+		{Hostname: "a.example.com", Port: 443},
+		{Hostname: "b.example.com", Port: 443},
+		{Hostname: "c.example.com", Port: 443, Tags: []string{"prod"}},
+	}
+	updated := []config.CertificateConfig{
+		{Hostname: "a.example.com", Port: 443},
+		{Hostname: "c.example.com", Port: 443, Tags: []string{"staging"}},
+		{Hostname: "b.example.com", Port: 8443},
+	}
+
+	d := diffCertificates(old, updated)
+
+	if want := []string{"b.example.com:8443"}; !reflect.DeepEqual(d.added, want) {
+		t.Errorf("added = %v, want %v", d.added, want)
+	}
+	if want := []string{"b.example.com:443"}; !reflect.DeepEqual(d.removed, want) {
+		t.Errorf("removed = %v, want %v", d.removed, want)
+	}
+	if want := []string{"c.example.com:443"}; !reflect.DeepEqual(d.changed, want) {
+		t.Errorf("changed = %v, want %v", d.changed, want)
+	}
+
+	if d := diffCertificates(old, old); !d.empty() {
+		t.Errorf("expected no differences, got %+v", d)
+	}
+}
+
+func TestReload_AppliesValidConfig(t *testing.T) {
+	a := newTestAgent(t, nil)
+	old := a.config
+
+	updated := *old
+	updated.API.Key = "cw_new"
+	updated.API.Endpoint = "https://other.example.com"
+	updated.Agent.LogLevel = "debug"
+	updated.Certificates = []config.CertificateConfig{
+		{Hostname: "a.example.com", Port: 443},
+		{Hostname: "c.example.com", Port: 443},
+	}
+	a.SetConfigLoader(func() (*config.Config, error) { return &updated, nil })
+
+	prev, ok := a.reload("test")
+	if !ok {
+		t.Fatal("expected reload to be applied")
+	}
+	if prev != old {
+		t.Error("expected the previous config to be returned")
+	}
+	if a.config != &updated {
+		t.Error("expected the new config to be active")
+	}
+	if got := a.logLevel.Level(); got != zapcore.DebugLevel {
+		t.Errorf("log level = %v, want debug", got)
+	}
+	if a.config.API.Key != "cw_new" {
+		t.Errorf("api.key = %q, want the new key applied", a.config.API.Key)
+	}
+	if a.config.API.Endpoint != "https://api.example.com" {
+		t.Errorf("api.endpoint = %q, expected restart-only setting to be kept", a.config.API.Endpoint)
+	}
+
+	targets := make(map[string]bool)
+	for _, info := range a.scheduler.Snapshot() {
+		targets[info.Target] = true
+	}
+	if len(targets) != 2 || !targets["a.example.com:443"] || !targets["c.example.com:443"] {
+		t.Errorf("scheduled targets = %v, want a and c", targets)
+	}
+}
+
+func TestReload_RejectsInvalidConfig(t *testing.T) {
+	a := newTestAgent(t, nil)
+	old := a.config
+
+	a.SetConfigLoader(func() (*config.Config, error) {
+		return nil, errors.New("invalid configuration")
+	})
+
+	if _, ok := a.reload("test"); ok {
+		t.Fatal("expected reload to be rejected")
+	}
+	if a.config != old {
+		t.Error("expected the current config to be kept")
+	}
+	if got := len(a.scheduler.Snapshot()); got != 2 {
+		t.Errorf("expected 2 scheduled targets, got %d", got)
+	}
+}
+
+func TestReload_OfflineIsRestartOnly(t *testing.T) {
+	a := newTestAgent(t, nil)
+	a.config.Agent.Offline = true
+	a.client = nil
+
+	updated := *a.config
+	updated.Agent.Offline = false
+	updated.Agent.RemoteTargets = true
+	updated.API.Key = "cw_new"
+	a.SetConfigLoader(func() (*config.Config, error) { return &updated, nil })
+
+	if _, ok := a.reload("test"); !ok {
+		t.Fatal("expected reload to be applied")
+	}
+	if !a.config.Agent.Offline {
+		t.Error("expected offline mode to be kept until restart")
+	}
+	if a.refreshRemoteTargets(context.Background()) {
+		t.Error("expected no remote targets without the API")
+	}
+}
+
+func TestReload_Coalesces(t *testing.T) {
+	a := newTestAgent(t, nil)
+
+	// Reload never blocks, even when nothing is consuming requests
+	a.Reload("first")
+	a.Reload("second")
+
+	if got := <-a.reloadCh; got != "first" {
+		t.Errorf("pending reload = %q, want first", got)
+	}
+	select {
+	case got := <-a.reloadCh:
+		t.Errorf("unexpected second pending reload %q", got)
+	default:
+	}
+}
+
+func TestForgetTargets_DeletesHostMetrics(t *testing.T) {
+	a := newTestAgent(t, nil)
+	for _, r := range []scanner.ScanResult{
+		{Hostname: "a.example.com", Port: 443},
+		{Hostname: "a.example.com", Port: 8443},
+		{Hostname: "b.example.com", Port: 443},
+	} {
+		a.results[resultKey(r.Hostname, r.Port)] = r
+		metrics.RecordScanPhases(r.Hostname, 0.01, 0.02, 0.03)
+	}
+
+	// a.example.com is still scanned on port 8443, so only the phase
+	// series of b.example.com go
+	a.forgetTargets([]string{"a.example.com:443", "b.example.com:443"})
+	if got := phaseSeries(t, "a.example.com"); got != 3 {
+		t.Errorf("a.example.com has %d phase series, want all 3 kept", got)
+	}
+	if got := phaseSeries(t, "b.example.com"); got != 0 {
+		t.Errorf("b.example.com has %d phase series, want them removed", got)
+	}
+}
+
+// phaseSeries counts the scan phase duration series of a hostname. The
+// histogram is gathered through a registry of its own, so metrics of other
+// tests don't affect the count.
+func phaseSeries(t *testing.T, hostname string) int {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(metrics.ScanPhaseDurationSeconds)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	n := 0
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "hostname" && l.GetValue() == hostname {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/metrics"
+	"github.com/certwatch-app/cw-agent/internal/sync"
+)
+
+// loadCachedTargets applies the remote targets cached in the state file,
+// so they are monitored even if the API can't be reached at startup
+func (a *Agent) loadCachedTargets() {
+	a.remoteTargets, a.remoteETag = a.stateManager.GetRemoteTargets()
+	a.applyRemoteTargets()
+}
+
+// refreshRemoteTargets fetches the targets managed in CertWatch and applies
+// them if the list changed. If the fetch fails, the current list is kept.
+// Returns whether the monitored targets changed.
+func (a *Agent) refreshRemoteTargets(ctx context.Context) bool {
+	if a.client == nil || !a.config.Agent.RemoteTargets {
+		return false
+	}
+
+	targets, etag, err := a.client.FetchTargets(ctx, a.remoteETag)
+	if errors.Is(err, sync.ErrNotModified) {
+		a.logger.Debug("remote targets unchanged")
+		return false
+	}
+	if err != nil {
+		a.logger.Warn("failed to fetch remote targets, keeping the current list",
+			zap.Int("remote_targets", len(a.remoteTargets)),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	a.remoteTargets, a.remoteETag = targets, etag
+	a.stateManager.SetRemoteTargets(targets, etag)
+	if err := a.stateManager.Save(); err != nil {
+		a.logger.Warn("failed to cache remote targets", zap.Error(err))
+	}
+
+	return a.applyRemoteTargets()
+}
+
+// applyRemoteTargets merges the remote targets into the configured targets
+// and reschedules them. Returns whether the monitored targets changed.
+func (a *Agent) applyRemoteTargets() bool {
+	certs := a.mergeRemoteTargets(a.config)
+	diff := diffCertificates(a.config.Certificates, certs)
+	if diff.empty() {
+		return false
+	}
+
+	cfg := *a.config
+	cfg.Certificates = certs
+	a.config = &cfg
+
+	a.scheduler.SetTargets(a.config, time.Now())
+	a.forgetTargets(diff.removed)
+	metrics.SetCertificatesConfigured(len(certs))
+
+	a.logger.Info("remote targets updated",
+		zap.Int("remote_targets", len(a.remoteTargets)),
+		zap.Strings("added", diff.added),
+		zap.Strings("removed", diff.removed),
+		zap.Strings("changed", diff.changed),
+	)
+	return true
+}
+
+// mergeRemoteTargets returns the configured targets of cfg followed by the
+// remote targets, if cfg enables them
+func (a *Agent) mergeRemoteTargets(cfg *config.Config) []config.CertificateConfig {
+	var remote []config.RemoteTarget
+	if cfg.Agent.RemoteTargets {
+		remote = a.remoteTargets
+	}
+
+	certs, errs := config.MergeRemoteTargets(cfg.Certificates, remote)
+	for _, err := range errs {
+		a.logger.Warn("skipping invalid remote target", zap.Error(err))
+	}
+	return certs
+}
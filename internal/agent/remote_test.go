@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/state"
+)
+
+// newTargetsServer starts an API stub that serves the given targets JSON
+// with ETag "v1"
+func newTargetsServer(t *testing.T, targets string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"success":true,"targets":` + targets + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// remoteTargets configures a test agent to fetch its targets from endpoint,
+// besides a.example.com:443 from its config file
+func remoteTargets(endpoint string) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.API.Endpoint = endpoint
+		cfg.Agent.RemoteTargets = true
+		cfg.Certificates = cfg.Certificates[:1]
+	}
+}
+
+func scheduledTargets(a *Agent) []string {
+	var targets []string
+	for _, info := range a.scheduler.Snapshot() {
+		targets = append(targets, info.Target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+func TestRefreshRemoteTargets(t *testing.T) {
+	srv := newTargetsServer(t, `[{"hostname":"a.example.com"},{"hostname":"r.example.com","port":8443}]`)
+	a := newTestAgent(t, remoteTargets(srv.URL))
+
+	if !a.refreshRemoteTargets(context.Background()) {
+		t.Fatal("expected the fetched targets to be applied")
+	}
+
+	// The local a.example.com wins over the remote one
+	want := []string{"a.example.com:443", "r.example.com:8443"}
+	if got := scheduledTargets(a); !slices.Equal(got, want) {
+		t.Errorf("scheduled targets = %v, want %v", got, want)
+	}
+	if a.config.Certificates[0].Remote || !a.config.Certificates[1].Remote {
+		t.Errorf("certificates = %+v, want only r.example.com marked remote", a.config.Certificates)
+	}
+
+	// Cached for the next start
+	reloaded := state.NewManagerWithStateDir(a.stateManager.Dir())
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cached, etag := reloaded.GetRemoteTargets(); len(cached) != 2 || etag != `"v1"` {
+		t.Errorf("cached targets = %+v with ETag %q", cached, etag)
+	}
+
+	// Unchanged list
+	if a.refreshRemoteTargets(context.Background()) {
+		t.Error("expected no change for a 304 response")
+	}
+
+	// Reloading the file keeps the remote targets
+	file := *a.config
+	file.Certificates = []config.CertificateConfig{{Hostname: "b.example.com", Port: 443}}
+	a.SetConfigLoader(func() (*config.Config, error) { return &file, nil })
+	if _, ok := a.reload("test"); !ok {
+		t.Fatal("expected reload to be applied")
+	}
+	want = []string{"a.example.com:443", "b.example.com:443", "r.example.com:8443"}
+	if got := scheduledTargets(a); !slices.Equal(got, want) {
+		t.Errorf("scheduled targets after reload = %v, want %v", got, want)
+	}
+}
+
+func TestLoadCachedTargets(t *testing.T) {
+	// The API can't be reached
+	a := newTestAgent(t, remoteTargets("http://127.0.0.1:1"))
+	a.stateManager.SetRemoteTargets([]config.RemoteTarget{{Hostname: "r.example.com"}}, `"v1"`)
+	a.loadCachedTargets()
+	if a.refreshRemoteTargets(context.Background()) {
+		t.Error("expected a failed fetch to keep the cached targets")
+	}
+
+	want := []string{"a.example.com:443", "r.example.com:443"}
+	if got := scheduledTargets(a); !slices.Equal(got, want) {
+		t.Errorf("scheduled targets = %v, want %v", got, want)
+	}
+	if a.remoteETag != `"v1"` {
+		t.Errorf("ETag = %q, want the cached one", a.remoteETag)
+	}
+}
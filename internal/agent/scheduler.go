@@ -0,0 +1,257 @@
+package agent
+
+import (
+	"container/heap"
+	"math/rand/v2"
+	gosync "sync"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// Adaptive scanning thresholds. A target's base interval is divided by the
+// matching factor, but never drops below config.MinScanInterval.
+const (
+	expiryCriticalDays   = 7
+	expiryWarningDays    = 30
+	expiryCriticalFactor = 4
+	expiryWarningFactor  = 2
+	failureFactor        = 4
+)
+
+// scheduler decides when each target is due for its next scan.
+// Targets are kept in a min-heap ordered by their next scan time.
+type scheduler struct {
+	cfg      *config.Config
+	entries  map[string]*scheduleEntry
+	queue    scheduleQueue
+	inFlight map[string]bool
+	mu       gosync.Mutex
+}
+
+// scheduleEntry tracks the scheduling state of a single target
+// Fields are ordered for optimal memory alignment
+type scheduleEntry struct {
+	next            time.Time
+	lastScan        time.Time
+	cert            config.CertificateConfig
+	interval        time.Duration
+	index           int // Position in the heap, -1 when not queued
+	failures        int // Consecutive scan failures
+	daysUntilExpiry int
+	hasExpiry       bool
+}
+
+// ScheduleInfo is a snapshot of a target's scheduling state
+type ScheduleInfo struct {
+	NextScan time.Time     `json:"next_scan"`
+	LastScan time.Time     `json:"last_scan,omitempty"`
+	Target   string        `json:"target"`
+	Interval time.Duration `json:"interval"`
+	Failures int           `json:"consecutive_failures"`
+	InFlight bool          `json:"in_flight"`
+}
+
+func newScheduler(cfg *config.Config) *scheduler {
+	return &scheduler{
+		cfg:      cfg,
+		entries:  make(map[string]*scheduleEntry),
+		inFlight: make(map[string]bool),
+	}
+}
+
+// SetTargets replaces the scheduled target set. Targets that are already
+// known keep their schedule unless their interval changed; new targets
+// become due at now.
+func (s *scheduler) SetTargets(cfg *config.Config, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = cfg
+	keep := make(map[string]bool, len(cfg.Certificates))
+	for i := range cfg.Certificates {
+		cert := cfg.Certificates[i]
+		key := cert.GetHostPort()
+		keep[key] = true
+
+		if e, ok := s.entries[key]; ok {
+			e.cert = cert
+			interval := s.intervalFor(e)
+			if interval != e.interval && !e.lastScan.IsZero() && e.index >= 0 {
+				// Apply a changed interval from the last scan rather than
+				// waiting out the old one
+				e.next = e.lastScan.Add(interval)
+				heap.Fix(&s.queue, e.index)
+			}
+			e.interval = interval
+			continue
+		}
+
+		e := &scheduleEntry{cert: cert, next: now, index: -1}
+		e.interval = s.intervalFor(e)
+		s.entries[key] = e
+		if !s.inFlight[key] {
+			heap.Push(&s.queue, e)
+		}
+	}
+
+	for key, e := range s.entries {
+		if keep[key] {
+			continue
+		}
+		if e.index >= 0 {
+			heap.Remove(&s.queue, e.index)
+		}
+		delete(s.entries, key)
+	}
+}
+
+// Due removes and returns all targets whose next scan time has passed,
+// most overdue first. They stay in flight until Record is called.
+func (s *scheduler) Due(now time.Time) []config.CertificateConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []config.CertificateConfig
+	for s.queue.Len() > 0 && !s.queue[0].next.After(now) {
+		e, ok := heap.Pop(&s.queue).(*scheduleEntry)
+		if !ok {
+			continue
+		}
+		s.inFlight[e.cert.GetHostPort()] = true
+		due = append(due, e.cert)
+	}
+	return due
+}
+
+// Record updates a target's state from a scan result and schedules its next scan
+func (s *scheduler) Record(result *scanner.ScanResult, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := (&config.CertificateConfig{Hostname: result.Hostname, Port: result.Port}).GetHostPort()
+	delete(s.inFlight, key)
+
+	e, ok := s.entries[key]
+	if !ok {
+		// Target was removed while it was being scanned
+		return
+	}
+
+	e.lastScan = now
+	if result.Success {
+		e.failures = 0
+	} else {
+		e.failures++
+	}
+	if result.Certificate != nil {
+		e.hasExpiry = true
+		e.daysUntilExpiry = result.Certificate.DaysUntilExpiry
+	}
+
+	e.interval = s.intervalFor(e)
+	e.next = now.Add(e.interval + s.jitter(e.interval))
+
+	if e.index >= 0 {
+		heap.Fix(&s.queue, e.index)
+	} else {
+		heap.Push(&s.queue, e)
+	}
+}
+
+// NextDue returns the time the next target becomes due
+func (s *scheduler) NextDue() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.queue.Len() == 0 {
+		return time.Time{}, false
+	}
+	return s.queue[0].next, true
+}
+
+// Snapshot returns the scheduling state of all targets
+func (s *scheduler) Snapshot() []ScheduleInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]ScheduleInfo, 0, len(s.entries))
+	for key, e := range s.entries {
+		infos = append(infos, ScheduleInfo{
+			Target:   key,
+			NextScan: e.next,
+			LastScan: e.lastScan,
+			Interval: e.interval,
+			Failures: e.failures,
+			InFlight: s.inFlight[key],
+		})
+	}
+	return infos
+}
+
+// intervalFor returns the current scan interval for a target, shortened
+// when adaptive scanning is enabled and the target needs closer attention
+func (s *scheduler) intervalFor(e *scheduleEntry) time.Duration {
+	base := s.cfg.EffectiveScanInterval(&e.cert)
+	if !s.cfg.Agent.AdaptiveScan {
+		return base
+	}
+
+	factor := 1
+	switch {
+	case e.failures > 0:
+		factor = failureFactor
+	case e.hasExpiry && e.daysUntilExpiry <= expiryCriticalDays:
+		factor = expiryCriticalFactor
+	case e.hasExpiry && e.daysUntilExpiry <= expiryWarningDays:
+		factor = expiryWarningFactor
+	}
+
+	interval := base / time.Duration(factor)
+	if interval < config.MinScanInterval {
+		interval = min(base, config.MinScanInterval)
+	}
+	return interval
+}
+
+// jitter returns a random delay that spreads scans of targets sharing an
+// interval, capped at half the interval
+func (s *scheduler) jitter(interval time.Duration) time.Duration {
+	maxJitter := min(s.cfg.Agent.ScanJitter, interval/2)
+	if maxJitter <= 0 {
+		return 0
+	}
+	return rand.N(maxJitter) //nolint:gosec // Jitter does not need a secure source
+}
+
+// scheduleQueue implements heap.Interface ordered by next scan time
+type scheduleQueue []*scheduleEntry
+
+func (q scheduleQueue) Len() int           { return len(q) }
+func (q scheduleQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x any) {
+	e, ok := x.(*scheduleEntry)
+	if !ok {
+		return
+	}
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *scheduleQueue) Pop() any {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*q = old[:n-1]
+	return e
+}
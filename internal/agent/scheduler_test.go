@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+func testSchedulerConfig() *config.Config {
+	return &config.Config{
+		Agent: config.AgentConfig{
+			ScanInterval:     10 * time.Minute,
+			TagScanIntervals: map[string]time.Duration{"prod": 2 * time.Minute, "critical": time.Minute},
+		},
+		Certificates: []config.CertificateConfig{
+			{Hostname: "a.example.com", Port: 443},
+			{Hostname: "b.example.com", Port: 443, Tags: []string{"Prod"}},
+			{Hostname: "c.example.com", Port: 443, Tags: []string{"prod", "critical"}},
+			{Hostname: "d.example.com", Port: 443, Tags: []string{"prod"}, ScanInterval: 30 * time.Minute},
+		},
+	}
+}
+
+func successResult(hostname string, days int) *scanner.ScanResult {
+	return &scanner.ScanResult{
+		Hostname:    hostname,
+		Port:        443,
+		Success:     true,
+		Certificate: &scanner.CertificateInfo{DaysUntilExpiry: days},
+	}
+}
+
+func TestScheduler_EffectiveIntervals(t *testing.T) {
+	cfg := testSchedulerConfig()
+	now := time.Now()
+
+	s := newScheduler(cfg)
+	s.SetTargets(cfg, now)
+
+	due := s.Due(now)
+	if len(due) != 4 {
+		t.Fatalf("expected all 4 targets due initially, got %d", len(due))
+	}
+
+	for _, c := range due {
+		s.Record(successResult(c.Hostname, 365), now)
+	}
+
+	want := map[string]time.Duration{
+		"a.example.com:443": 10 * time.Minute,
+		"b.example.com:443": 2 * time.Minute,
+		"c.example.com:443": time.Minute,
+		"d.example.com:443": 30 * time.Minute,
+	}
+	for _, info := range s.Snapshot() {
+		if info.Interval != want[info.Target] {
+			t.Errorf("%s: interval = %v, want %v", info.Target, info.Interval, want[info.Target])
+		}
+		if !info.NextScan.Equal(now.Add(info.Interval)) {
+			t.Errorf("%s: next scan = %v, want %v (no jitter configured)", info.Target, info.NextScan, now.Add(info.Interval))
+		}
+	}
+
+	if len(s.Due(now.Add(90*time.Second))) != 1 {
+		t.Error("expected only the critical target due after 90s")
+	}
+}
+
+func TestScheduler_AdaptiveInterval(t *testing.T) {
+	cfg := &config.Config{
+		Agent: config.AgentConfig{ScanInterval: 8 * time.Minute, AdaptiveScan: true},
+		Certificates: []config.CertificateConfig{
+			{Hostname: "host.example.com", Port: 443},
+		},
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		result *scanner.ScanResult
+		want   time.Duration
+	}{
+		{"healthy", successResult("host.example.com", 90), 8 * time.Minute},
+		{"warning", successResult("host.example.com", 20), 4 * time.Minute},
+		{"critical", successResult("host.example.com", 3), 2 * time.Minute},
+		{"failure", &scanner.ScanResult{Hostname: "host.example.com", Port: 443}, 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScheduler(cfg)
+			s.SetTargets(cfg, now)
+			s.Due(now)
+			s.Record(tt.result, now)
+
+			info := s.Snapshot()[0]
+			if info.Interval != tt.want {
+				t.Errorf("interval = %v, want %v", info.Interval, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduler_AdaptiveIntervalFloor(t *testing.T) {
+	cfg := &config.Config{
+		Agent: config.AgentConfig{ScanInterval: 20 * time.Second, AdaptiveScan: true},
+		Certificates: []config.CertificateConfig{
+			{Hostname: "host.example.com", Port: 443},
+		},
+	}
+	now := time.Now()
+
+	s := newScheduler(cfg)
+	s.SetTargets(cfg, now)
+	s.Record(successResult("host.example.com", 1), now)
+
+	if got := s.Snapshot()[0].Interval; got != config.MinScanInterval {
+		t.Errorf("interval = %v, want floor of %v", got, config.MinScanInterval)
+	}
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	cfg := testSchedulerConfig()
+	cfg.Agent.ScanJitter = 30 * time.Second
+	now := time.Now()
+
+	s := newScheduler(cfg)
+	s.SetTargets(cfg, now)
+	for _, c := range s.Due(now) {
+		s.Record(successResult(c.Hostname, 365), now)
+	}
+
+	for _, info := range s.Snapshot() {
+		delay := info.NextScan.Sub(now) - info.Interval
+		maxJitter := min(cfg.Agent.ScanJitter, info.Interval/2)
+		if delay < 0 || delay >= maxJitter {
+			t.Errorf("%s: jitter %v outside [0, %v)", info.Target, delay, maxJitter)
+		}
+	}
+}
+
+func TestScheduler_SetTargets(t *testing.T) {
+	cfg := testSchedulerConfig()
+	now := time.Now()
+
+	s := newScheduler(cfg)
+	s.SetTargets(cfg, now)
+	for _, c := range s.Due(now) {
+		s.Record(successResult(c.Hostname, 365), now)
+	}
+
+	// Drop a and b, keep c and d, add e
+	updated := testSchedulerConfig()
+	updated.Certificates = append(updated.Certificates[2:], config.CertificateConfig{Hostname: "e.example.com", Port: 443})
+	s.SetTargets(updated, now.Add(time.Second))
+
+	if got := len(s.Snapshot()); got != 3 {
+		t.Fatalf("expected 3 scheduled targets, got %d", got)
+	}
+
+	due := s.Due(now.Add(time.Second))
+	if len(due) != 1 || due[0].Hostname != "e.example.com" {
+		t.Errorf("expected only the new target to be due, got %+v", due)
+	}
+
+	// Results for removed targets are ignored
+	s.Record(successResult("a.example.com", 365), now)
+	if got := len(s.Snapshot()); got != 3 {
+		t.Errorf("expected removed target to stay removed, got %d targets", got)
+	}
+}
+
+func TestScheduler_SetTargetsIntervalChange(t *testing.T) {
+	cfg := testSchedulerConfig()
+	now := time.Now()
+
+	s := newScheduler(cfg)
+	s.SetTargets(cfg, now)
+	for _, c := range s.Due(now) {
+		s.Record(successResult(c.Hostname, 365), now)
+	}
+
+	// Shorten the interval of a.example.com from 10m to 3m
+	updated := testSchedulerConfig()
+	updated.Certificates[0].ScanInterval = 3 * time.Minute
+	s.SetTargets(updated, now.Add(time.Second))
+
+	due := s.Due(now.Add(3 * time.Minute))
+	hosts := make(map[string]bool)
+	for _, c := range due {
+		hosts[c.Hostname] = true
+	}
+	if !hosts["a.example.com"] {
+		t.Errorf("expected a.example.com due 3m after its last scan, got %v", hosts)
+	}
+}
@@ -0,0 +1,150 @@
+// Package alerting evaluates scan results against alert rules and notifies
+// the configured receivers when alerts fire, repeat or resolve.
+package alerting
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alert rules
+const (
+	RuleExpiry      = "expiry"       // Certificate expires within a threshold
+	RuleChain       = "chain"        // Chain or hostname problems
+	RuleScanFailure = "scan_failure" // Consecutive failed scans
+)
+
+// Alert severities
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Alert statuses
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Alert is a rule that fires for a target
+// Fields are ordered for optimal memory alignment
+type Alert struct {
+	StartsAt         time.Time           `json:"starts_at"`
+	EndsAt           *time.Time          `json:"ends_at,omitempty"`    // Set once resolved
+	Deliveries       map[string]Delivery `json:"deliveries,omitempty"` // Keyed by notifier name; not sent to receivers
+	Rule             string              `json:"rule"`
+	Target           string              `json:"target"` // hostname:port
+	Hostname         string              `json:"hostname"`
+	Severity         string              `json:"severity"`
+	PreviousSeverity string              `json:"previous_severity,omitempty"` // Severity the receiver was sent before it changed
+	Status           string              `json:"status"`
+	Summary          string              `json:"summary"`
+	Tags             []string            `json:"tags,omitempty"`
+	Port             int                 `json:"port"`
+}
+
+// Delivery is the last successful notification of an alert to a receiver
+type Delivery struct {
+	SentAt   time.Time `json:"sent_at"`
+	Severity string    `json:"severity"`
+}
+
+// Key identifies an alert; a target has at most one alert per rule
+func (a *Alert) Key() string {
+	return a.Rule + "/" + a.Target
+}
+
+// alertNames name the rules in the alertname label
+var alertNames = map[string]string{
+	RuleExpiry:      "CertificateExpiring",
+	RuleChain:       "CertificateChainInvalid",
+	RuleScanFailure: "CertificateScanFailing",
+}
+
+// clone returns a copy of the alert that shares no maps with it
+func (a *Alert) clone() Alert {
+	c := *a
+	c.Deliveries = maps.Clone(a.Deliveries)
+	return c
+}
+
+// Labels identifies the alert to incident management systems: the rule,
+// severity, agent, target and, sorted and comma-separated, the tags of the
+// target
+func (a *Alert) Labels(agent string) map[string]string {
+	labels := map[string]string{
+		"alertname": alertNames[a.Rule],
+		"rule":      a.Rule,
+		"severity":  a.Severity,
+		"agent":     agent,
+		"instance":  a.Target,
+		"hostname":  a.Hostname,
+		"port":      strconv.Itoa(a.Port),
+	}
+	if len(a.Tags) > 0 {
+		tags := slices.Clone(a.Tags)
+		slices.Sort(tags)
+		labels["tags"] = strings.Join(tags, ",")
+	}
+	return labels
+}
+
+// Notification is what receivers are sent: every alert that fired, repeated
+// or resolved in one evaluation
+type Notification struct {
+	Agent  string  `json:"agent"`
+	Status string  `json:"status"` // firing if any alert fires, else resolved
+	Alerts []Alert `json:"alerts"`
+}
+
+// Firing returns the firing alerts of the notification
+func (n *Notification) Firing() []Alert {
+	return n.filter(StatusFiring)
+}
+
+// Resolved returns the resolved alerts of the notification
+func (n *Notification) Resolved() []Alert {
+	return n.filter(StatusResolved)
+}
+
+func (n *Notification) filter(status string) []Alert {
+	var alerts []Alert
+	for i := range n.Alerts {
+		if n.Alerts[i].Status == status {
+			alerts = append(alerts, n.Alerts[i])
+		}
+	}
+	return alerts
+}
+
+// Notifier delivers notifications to a receiver
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// PartialError is returned by notifiers that delivered some alerts of a
+// notification but not others, which are sent again next time
+type PartialError struct {
+	Failed map[string]bool // Keys of the alerts not delivered
+	Err    error
+}
+
+func (e *PartialError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// Store persists firing alerts across restarts; *state.Manager implements it
+type Store interface {
+	GetAlerts() []Alert
+	SetAlerts(alerts []Alert)
+	Save() error
+}
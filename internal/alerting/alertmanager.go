@@ -0,0 +1,96 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+// Alertmanager posts alerts to the /api/v2/alerts endpoint of every
+// configured Alertmanager. Firing alerts end after a lifetime covering the
+// next repeat, so Alertmanager resolves them if the agent goes away.
+type Alertmanager struct {
+	headers    map[string]string
+	httpClient *http.Client
+	urls       []string
+	lifetime   time.Duration
+}
+
+// amAlert is an alert in the Alertmanager v2 API
+type amAlert struct {
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// NewAlertmanager creates an Alertmanager notifier for alerts repeated every
+// repeatInterval
+func NewAlertmanager(cfg *config.AlertmanagerConfig, repeatInterval time.Duration) *Alertmanager {
+	am := &Alertmanager{
+		headers:    cfg.Headers,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		lifetime:   2 * repeatInterval,
+	}
+	for _, u := range cfg.URLs {
+		am.urls = append(am.urls, strings.TrimSuffix(u, "/")+"/api/v2/alerts")
+	}
+	return am
+}
+
+// Name returns the notifier's name
+func (am *Alertmanager) Name() string {
+	return "alertmanager"
+}
+
+// Notify posts the alerts of n to every Alertmanager. Severity is a label,
+// so an alert whose severity changed also resolves the alert with the
+// severity it was last sent with. Posting again is harmless, so any failure
+// is an error and the alerts are posted again next time.
+func (am *Alertmanager) Notify(ctx context.Context, n *Notification) error {
+	now := time.Now()
+	alerts := make([]amAlert, 0, len(n.Alerts))
+	for i := range n.Alerts {
+		a := &n.Alerts[i]
+		if a.PreviousSeverity != "" && a.PreviousSeverity != a.Severity {
+			old := *a
+			old.Severity = a.PreviousSeverity
+			alerts = append(alerts, am.convert(&old, n.Agent, now))
+		}
+		endsAt := now.Add(am.lifetime)
+		if a.EndsAt != nil {
+			endsAt = *a.EndsAt
+		}
+		alerts = append(alerts, am.convert(a, n.Agent, endsAt))
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, u := range am.urls {
+		if err := postJSON(ctx, am.httpClient, u, am.headers, body); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", u, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to post alerts to %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (am *Alertmanager) convert(a *Alert, agent string, endsAt time.Time) amAlert {
+	return amAlert{
+		StartsAt:    a.StartsAt,
+		EndsAt:      endsAt,
+		Labels:      a.Labels(agent),
+		Annotations: map[string]string{"summary": a.Summary},
+	}
+}
@@ -0,0 +1,110 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+func TestAlertmanager_Notify(t *testing.T) {
+	var posts [][]amAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" {
+			http.NotFound(w, r)
+			return
+		}
+		var alerts []amAlert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		posts = append(posts, alerts)
+	}))
+	defer srv.Close()
+
+	am := NewAlertmanager(&config.AlertmanagerConfig{URLs: []string{srv.URL + "/"}, Timeout: time.Second}, time.Hour)
+
+	alert := Alert{
+		StartsAt: time.Now(),
+		Rule:     RuleExpiry,
+		Target:   "a.example.com:443",
+		Hostname: "a.example.com",
+		Port:     443,
+		Severity: SeverityWarning,
+		Status:   StatusFiring,
+		Summary:  "certificate expires in 20 days",
+		Tags:     []string{"prod", "payments"},
+	}
+	send := func(a Alert) []amAlert {
+		t.Helper()
+		if err := am.Notify(context.Background(), &Notification{Agent: "edge", Alerts: []Alert{a}}); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		return posts[len(posts)-1]
+	}
+
+	got := send(alert)
+	if len(got) != 1 {
+		t.Fatalf("posted %d alerts, want 1", len(got))
+	}
+	want := map[string]string{
+		"alertname": "CertificateExpiring",
+		"rule":      RuleExpiry,
+		"severity":  SeverityWarning,
+		"agent":     "edge",
+		"instance":  "a.example.com:443",
+		"hostname":  "a.example.com",
+		"port":      "443",
+		"tags":      "payments,prod",
+	}
+	for name, value := range want {
+		if got[0].Labels[name] != value {
+			t.Errorf("label %s = %q, want %q", name, got[0].Labels[name], value)
+		}
+	}
+	if got[0].Annotations["summary"] != alert.Summary {
+		t.Errorf("summary = %q, want %q", got[0].Annotations["summary"], alert.Summary)
+	}
+	if until := time.Until(got[0].EndsAt); until < time.Hour || until > 2*time.Hour {
+		t.Errorf("endsAt in %s, want a lifetime covering the next repeat", until)
+	}
+
+	// A change of severity resolves the alert with the severity last sent
+	alert.PreviousSeverity = SeverityWarning
+	alert.Severity = SeverityCritical
+	got = send(alert)
+	if len(got) != 2 || got[0].Labels["severity"] != SeverityWarning || got[1].Labels["severity"] != SeverityCritical {
+		t.Fatalf("posted %+v, want the warning resolved and the critical alert", got)
+	}
+	if got[0].EndsAt.After(time.Now()) {
+		t.Errorf("old severity endsAt = %s, want it resolved", got[0].EndsAt)
+	}
+
+	// Renewal resolves the alert
+	endsAt := time.Now()
+	alert.PreviousSeverity = ""
+	alert.Status = StatusResolved
+	alert.EndsAt = &endsAt
+	got = send(alert)
+	if len(got) != 1 || !got[0].EndsAt.Equal(endsAt) {
+		t.Errorf("posted %+v, want the alert resolved at %s", got, endsAt)
+	}
+}
+
+func TestAlertmanager_NotifyError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	am := NewAlertmanager(&config.AlertmanagerConfig{URLs: []string{srv.URL}, Timeout: time.Second}, time.Hour)
+	n := &Notification{Alerts: []Alert{{Rule: RuleExpiry, Target: "a.example.com:443", Severity: SeverityWarning, Status: StatusFiring}}}
+	if err := am.Notify(context.Background(), n); err == nil {
+		t.Error("Notify() succeeded with Alertmanager unavailable")
+	}
+}
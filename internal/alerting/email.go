@@ -0,0 +1,280 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/version"
+)
+
+// Email sends notifications as plain text digests over SMTP. Each alert is
+// routed by the tags of its target; recipients of the same alerts share
+// one message.
+type Email struct {
+	tlsConfig *tls.Config // Base config for STARTTLS and implicit TLS
+	cfg       config.EmailConfig
+}
+
+// EmailOption customizes an Email notifier
+type EmailOption func(*Email)
+
+// WithTLSConfig sets the base TLS config of the SMTP connection, e.g. to
+// trust a private CA. The server name is always the configured host.
+func WithTLSConfig(cfg *tls.Config) EmailOption {
+	return func(e *Email) {
+		e.tlsConfig = cfg
+	}
+}
+
+// NewEmail creates an email notifier
+func NewEmail(cfg *config.EmailConfig, opts ...EmailOption) *Email {
+	e := &Email{cfg: *cfg}
+	if e.cfg.Timeout == 0 {
+		e.cfg.Timeout = config.DefaultNotifierTimeout
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Name returns the notifier's name
+func (e *Email) Name() string {
+	return "email"
+}
+
+// Notify sends a digest to the recipients of every alert in n. If some
+// digests fail, the error is a *PartialError with the alerts in them, so
+// alerts only in delivered digests aren't sent again.
+func (e *Email) Notify(ctx context.Context, n *Notification) error {
+	var errs []string
+	failed := make(map[string]bool)
+	for _, d := range e.route(n) {
+		if err := e.send(ctx, d.to, e.message(d.to, &d.notification)); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", strings.Join(d.to, ", "), err))
+			for i := range d.notification.Alerts {
+				failed[d.notification.Alerts[i].Key()] = true
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &PartialError{
+			Failed: failed,
+			Err:    fmt.Errorf("failed to send email to %s", strings.Join(errs, "; ")),
+		}
+	}
+	return nil
+}
+
+// digest is a notification for a set of recipients
+type digest struct {
+	to           []string
+	notification Notification
+}
+
+// route splits a notification by recipients. Alerts go to the recipients of
+// every route with a tag of their target, or to the default recipients if
+// no route matches.
+func (e *Email) route(n *Notification) []digest {
+	byRecipients := make(map[string]*digest)
+	var keys []string
+
+	for i := range n.Alerts {
+		a := &n.Alerts[i]
+		to := e.recipients(a.Tags)
+		if len(to) == 0 {
+			continue
+		}
+
+		key := strings.Join(to, ",")
+		d, ok := byRecipients[key]
+		if !ok {
+			d = &digest{to: to, notification: Notification{Agent: n.Agent, Status: StatusResolved}}
+			byRecipients[key] = d
+			keys = append(keys, key)
+		}
+		d.notification.Alerts = append(d.notification.Alerts, *a)
+		if a.Status == StatusFiring {
+			d.notification.Status = StatusFiring
+		}
+	}
+
+	digests := make([]digest, 0, len(keys))
+	for _, key := range keys {
+		digests = append(digests, *byRecipients[key])
+	}
+	return digests
+}
+
+// recipients returns the sorted addresses the alerts of a target with tags
+// are sent to
+func (e *Email) recipients(tags []string) []string {
+	var to []string
+	for _, r := range e.cfg.Routes {
+		if matchesTag(r.Tags, tags) {
+			to = append(to, r.To...)
+		}
+	}
+	if to == nil {
+		to = append(to, e.cfg.To...)
+	}
+
+	sort.Strings(to)
+	return slices.Compact(to)
+}
+
+// matchesTag reports whether any route tag is one of tags, ignoring case
+func matchesTag(routeTags, tags []string) bool {
+	for _, rt := range routeTags {
+		for _, t := range tags {
+			if strings.EqualFold(rt, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// message builds the email for a digest
+func (e *Email) message(to []string, n *Notification) []byte {
+	firing, resolved := n.Firing(), n.Resolved()
+
+	subject := fmt.Sprintf("[CertWatch] %s: %d firing", n.Agent, len(firing))
+	if len(resolved) > 0 {
+		subject += fmt.Sprintf(", %d resolved", len(resolved))
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "X-Mailer: cw-agent/%s\r\n", version.GetVersion())
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+
+	fmt.Fprintf(&b, "Certificate alerts from agent %s\r\n", n.Agent)
+	writeAlerts(&b, "Firing", firing)
+	writeAlerts(&b, "Resolved", resolved)
+	return b.Bytes()
+}
+
+func writeAlerts(b *bytes.Buffer, title string, alerts []Alert) {
+	if len(alerts) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\r\n%s (%d):\r\n", title, len(alerts))
+	for i := range alerts {
+		a := &alerts[i]
+		fmt.Fprintf(b, "\r\n  [%s] %s (%s)\r\n", strings.ToUpper(a.Severity), a.Target, a.Rule)
+		if a.Summary != "" {
+			fmt.Fprintf(b, "  %s\r\n", a.Summary)
+		}
+		fmt.Fprintf(b, "  Since %s", a.StartsAt.UTC().Format(time.RFC3339))
+		if a.EndsAt != nil {
+			fmt.Fprintf(b, ", resolved %s", a.EndsAt.UTC().Format(time.RFC3339))
+		}
+		if len(a.Tags) > 0 {
+			fmt.Fprintf(b, ", tags: %s", strings.Join(a.Tags, ", "))
+		}
+		b.WriteString("\r\n")
+	}
+}
+
+// send delivers a message over one SMTP session
+func (e *Email) send(ctx context.Context, to []string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel()
+
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, e.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if e.cfg.TLS == config.EmailTLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server does not support STARTTLS")
+		}
+		if err := c.StartTLS(e.newTLSConfig()); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if e.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	from, err := mail.ParseAddress(e.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		rcpt, err := mail.ParseAddress(addr)
+		if err != nil {
+			return fmt.Errorf("invalid recipient: %w", err)
+		}
+		if err := c.Rcpt(rcpt.Address); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// dial connects to the SMTP server, with TLS from the start in implicit mode
+func (e *Email) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.cfg.Port))
+	if e.cfg.TLS == config.EmailTLSImplicit {
+		d := &tls.Dialer{Config: e.newTLSConfig()}
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+func (e *Email) newTLSConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if e.tlsConfig != nil {
+		cfg = e.tlsConfig.Clone()
+	}
+	cfg.ServerName = e.cfg.Host
+	return cfg
+}
@@ -0,0 +1,306 @@
+package alerting
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"slices"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+// smtpMessage is a message received by smtpServer
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+	user string // Authenticated user
+	tls  bool   // Sent after STARTTLS
+}
+
+// smtpServer is a minimal SMTP server supporting STARTTLS and AUTH PLAIN
+type smtpServer struct {
+	listener net.Listener
+	tls      *tls.Config
+	messages []smtpMessage
+	mu       gosync.Mutex
+	wg       gosync.WaitGroup
+}
+
+// newSMTPServer starts an SMTP server on 127.0.0.1 and returns it with a
+// client TLS config that trusts its certificate
+func newSMTPServer(t *testing.T) (*smtpServer, *tls.Config) {
+	t.Helper()
+
+	// Borrow the test certificate of httptest, which is valid for 127.0.0.1
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	https.Close()
+	clientTLS := https.Client().Transport.(*http.Transport).TLSClientConfig
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &smtpServer{listener: l, tls: https.TLS}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(func() {
+		l.Close()
+		s.wg.Wait()
+	})
+	return s, clientTLS
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+func (s *smtpServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	tp := textproto.NewConn(conn)
+	var msg smtpMessage
+
+	_ = tp.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			ext := "250-AUTH PLAIN"
+			if !msg.tls {
+				ext = "250-STARTTLS\r\n" + ext
+			}
+			_ = tp.PrintfLine("250-localhost\r\n%s\r\n250 8BITMIME", ext)
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			msg.tls = true
+		case "AUTH":
+			creds, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			parts := strings.Split(string(creds), "\x00")
+			if len(parts) != 3 || parts[2] != "secret" {
+				_ = tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			msg.user = parts[1]
+			_ = tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			msg.from = smtpPath(arg, "FROM:")
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			rcpt := smtpPath(arg, "TO:")
+			if strings.HasPrefix(rcpt, "unknown@") {
+				_ = tp.PrintfLine("550 no such user")
+				continue
+			}
+			msg.to = append(msg.to, rcpt)
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			msg = smtpMessage{tls: msg.tls, user: msg.user}
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+// smtpPath returns the address of a MAIL or RCPT argument, without
+// parameters such as BODY=8BITMIME
+func smtpPath(arg, prefix string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(arg, prefix), " ")
+	return strings.Trim(path, "<>")
+}
+
+func testEmail(srv *smtpServer, clientTLS *tls.Config, mode string) *Email {
+	return NewEmail(&config.EmailConfig{
+		To: []string{"ops@example.com"},
+		Routes: []config.EmailRoute{
+			{Tags: []string{"payments"}, To: []string{"payments@example.com"}},
+			{Tags: []string{"PCI"}, To: []string{"security@example.com", "payments@example.com"}},
+		},
+		Host:     "127.0.0.1",
+		Username: "certwatch",
+		Password: "secret",
+		From:     "CertWatch <certwatch@example.com>",
+		TLS:      mode,
+		Timeout:  5 * time.Second,
+		Port:     srv.port(),
+	}, WithTLSConfig(clientTLS))
+}
+
+func TestEmail_Notify(t *testing.T) {
+	srv, clientTLS := newSMTPServer(t)
+	e := testEmail(srv, clientTLS, config.EmailTLSStartTLS)
+
+	ends := time.Date(2025, 12, 2, 0, 0, 0, 0, time.UTC)
+	n := &Notification{
+		Agent:  "edge",
+		Status: StatusFiring,
+		Alerts: []Alert{
+			{Rule: RuleExpiry, Target: "pay.example.com:443", Severity: SeverityCritical, Status: StatusFiring, Summary: "certificate expires in 5 days (2025-12-06)", Tags: []string{"payments"}},
+			{Rule: RuleChain, Target: "www.example.com:443", Severity: SeverityWarning, Status: StatusFiring, Summary: "Leaf certificate is self-signed"},
+			{Rule: RuleScanFailure, Target: "card.example.com:443", Severity: SeverityCritical, Status: StatusResolved, EndsAt: &ends, Tags: []string{"pci", "payments"}},
+		},
+	}
+	if err := e.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	msgs := srv.received()
+	if len(msgs) != 3 {
+		t.Fatalf("received %d messages, want one per set of recipients", len(msgs))
+	}
+
+	want := []struct {
+		to      []string
+		subject string
+		targets []string
+	}{
+		{[]string{"payments@example.com"}, "[CertWatch] edge: 1 firing", []string{"pay.example.com:443"}},
+		{[]string{"ops@example.com"}, "[CertWatch] edge: 1 firing", []string{"www.example.com:443"}},
+		{[]string{"payments@example.com", "security@example.com"}, "[CertWatch] edge: 0 firing, 1 resolved", []string{"card.example.com:443"}},
+	}
+	for i, w := range want {
+		m := msgs[i]
+		if !m.tls || m.user != "certwatch" {
+			t.Errorf("message %d: tls = %v, user = %q, want sent over STARTTLS with auth", i, m.tls, m.user)
+		}
+		if m.from != "certwatch@example.com" {
+			t.Errorf("message %d: from = %q", i, m.from)
+		}
+		if !slices.Equal(m.to, w.to) {
+			t.Errorf("message %d: recipients = %v, want %v", i, m.to, w.to)
+		}
+		if !strings.Contains(m.data, "Subject: "+w.subject+"\n") {
+			t.Errorf("message %d: want subject %q in\n%s", i, w.subject, m.data)
+		}
+		for _, target := range w.targets {
+			if !strings.Contains(m.data, target) {
+				t.Errorf("message %d: want %s in\n%s", i, target, m.data)
+			}
+		}
+	}
+	if !strings.Contains(msgs[0].data, "[CRITICAL] pay.example.com:443 (expiry)\n  certificate expires in 5 days") {
+		t.Errorf("unexpected digest body:\n%s", msgs[0].data)
+	}
+}
+
+func TestEmail_NotifyErrors(t *testing.T) {
+	srv, clientTLS := newSMTPServer(t)
+	n := &Notification{Agent: "edge", Alerts: []Alert{{Rule: RuleExpiry, Target: "a.example.com:443", Status: StatusFiring}}}
+
+	e := testEmail(srv, clientTLS, config.EmailTLSStartTLS)
+	e.cfg.Password = "wrong"
+	if err := e.Notify(context.Background(), n); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("Notify() error = %v, want an authentication error", err)
+	}
+
+	// The server's certificate isn't trusted by default
+	e = testEmail(srv, nil, config.EmailTLSStartTLS)
+	if err := e.Notify(context.Background(), n); err == nil || !strings.Contains(err.Error(), "STARTTLS failed") {
+		t.Errorf("Notify() error = %v, want a certificate error", err)
+	}
+
+	// Implicit TLS against a plain server
+	e = testEmail(srv, clientTLS, config.EmailTLSImplicit)
+	if err := e.Notify(context.Background(), n); err == nil {
+		t.Error("Notify() succeeded with TLS against a plain SMTP port")
+	}
+
+	if len(srv.received()) != 0 {
+		t.Errorf("received %d messages, want none", len(srv.received()))
+	}
+}
+
+func TestEmail_NotifyPartial(t *testing.T) {
+	srv, clientTLS := newSMTPServer(t)
+	e := testEmail(srv, clientTLS, config.EmailTLSStartTLS)
+	e.cfg.Routes = []config.EmailRoute{{Tags: []string{"payments"}, To: []string{"unknown@example.com"}}}
+
+	n := &Notification{Agent: "edge", Alerts: []Alert{
+		{Rule: RuleExpiry, Target: "pay.example.com:443", Status: StatusFiring, Tags: []string{"payments"}},
+		{Rule: RuleExpiry, Target: "www.example.com:443", Status: StatusFiring},
+	}}
+	err := e.Notify(context.Background(), n)
+
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Notify() error = %v, want a PartialError", err)
+	}
+	if len(partial.Failed) != 1 || !partial.Failed["expiry/pay.example.com:443"] {
+		t.Errorf("failed alerts = %v, want the alert of the rejected digest", partial.Failed)
+	}
+	if msgs := srv.received(); len(msgs) != 1 || !slices.Equal(msgs[0].to, []string{"ops@example.com"}) {
+		t.Errorf("received %+v, want the digest for ops", msgs)
+	}
+}
+
+func TestEmail_Route(t *testing.T) {
+	e := NewEmail(&config.EmailConfig{
+		Routes: []config.EmailRoute{{Tags: []string{"prod"}, To: []string{"prod@example.com"}}},
+	})
+	n := &Notification{Alerts: []Alert{
+		{Target: "a.example.com:443", Tags: []string{"Prod"}},
+		{Target: "b.example.com:443", Tags: []string{"staging"}},
+	}}
+
+	digests := e.route(n)
+	if len(digests) != 1 || len(digests[0].notification.Alerts) != 1 {
+		t.Fatalf("route() = %+v, want only the prod alert without default recipients", digests)
+	}
+	if got := digests[0].to; !slices.Equal(got, []string{"prod@example.com"}) {
+		t.Errorf("recipients = %v", got)
+	}
+}
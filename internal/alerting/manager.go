@@ -0,0 +1,390 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	gosync "sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/metrics"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// Manager tracks the alerts of every target and notifies the receivers.
+// Firing alerts are kept in the store, with what each receiver was sent, so
+// a restart neither repeats nor loses them.
+type Manager struct {
+	store     Store
+	logger    *zap.Logger
+	firing    map[string]*Alert // Keyed by Alert.Key
+	failures  map[string]int    // Consecutive failed scans per target
+	resolved  []*Alert          // Resolved alerts receivers in their Deliveries weren't told of yet
+	notifiers []Notifier
+	agent     string
+	settings  config.AlertingConfig
+	dirty     bool // Firing alerts changed since they were saved
+	mu        gosync.Mutex
+	sendMu    gosync.Mutex // Held while notifications are sent, without mu
+}
+
+// New creates a Manager for the alerting settings of cfg and restores the
+// firing alerts from the store
+func New(cfg *config.Config, store Store, logger *zap.Logger) (*Manager, error) {
+	m := &Manager{
+		store:    store,
+		logger:   logger,
+		firing:   make(map[string]*Alert),
+		failures: make(map[string]int),
+	}
+	for _, a := range store.GetAlerts() {
+		m.firing[a.Key()] = &a
+	}
+	if err := m.Configure(cfg); err != nil {
+		return nil, err
+	}
+	m.updateMetrics()
+	return m, nil
+}
+
+// Configure applies the alerting settings of cfg, e.g. after a reload
+func (m *Manager) Configure(cfg *config.Config) error {
+	notifiers, err := newNotifiers(&cfg.Alerting)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifiers = notifiers
+	m.settings = cfg.Alerting
+	m.agent = cfg.Agent.Name
+	return nil
+}
+
+// newNotifiers creates a notifier for every configured receiver
+func newNotifiers(settings *config.AlertingConfig) ([]Notifier, error) {
+	var notifiers []Notifier
+	for i := range settings.Webhooks {
+		w, err := NewWebhook(&settings.Webhooks[i])
+		if err != nil {
+			return nil, fmt.Errorf("webhook %q: %w", settings.Webhooks[i].Name, err)
+		}
+		notifiers = append(notifiers, w)
+	}
+	if settings.Email.Enabled() {
+		notifiers = append(notifiers, NewEmail(&settings.Email))
+	}
+	if settings.Alertmanager.Enabled() {
+		notifiers = append(notifiers, NewAlertmanager(&settings.Alertmanager, settings.RepeatInterval))
+	}
+	if settings.PagerDuty.Enabled() {
+		notifiers = append(notifiers, NewPagerDuty(&settings.PagerDuty))
+	}
+	return notifiers, nil
+}
+
+// Observe evaluates a scan result. Alerts that start firing or change
+// severity are sent with the next Notify. Without receivers, nothing is
+// tracked.
+func (m *Manager) Observe(r *scanner.ScanResult, tags []string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.notifiers) == 0 {
+		return
+	}
+
+	target := fmt.Sprintf("%s:%d", r.Hostname, r.Port)
+	if r.Success {
+		delete(m.failures, target)
+	} else {
+		m.failures[target]++
+	}
+
+	rules, findings := evaluate(r, m.failures[target], &m.settings)
+	for _, rule := range rules {
+		key := rule + "/" + target
+		current, firing := m.firing[key]
+		f, active := findings[rule]
+
+		switch {
+		case active && !firing:
+			m.firing[key] = &Alert{
+				StartsAt: now,
+				Rule:     rule,
+				Target:   target,
+				Hostname: r.Hostname,
+				Port:     r.Port,
+				Severity: f.severity,
+				Status:   StatusFiring,
+				Summary:  f.summary,
+				Tags:     tags,
+			}
+			// Receivers still to be told of an earlier resolution see
+			// the alert firing again instead
+			m.resolved = slices.DeleteFunc(m.resolved, func(a *Alert) bool { return a.Key() == key })
+			m.dirty = true
+
+		case active:
+			if current.Severity != f.severity {
+				// Notify the change right away
+				current.Severity = f.severity
+				m.dirty = true
+			}
+			current.Summary = f.summary
+			current.Tags = tags
+
+		case firing:
+			delete(m.firing, key)
+			m.dirty = true
+			// The alert is kept until the receivers that were sent it are
+			// told it resolved
+			if m.settings.SendResolved {
+				current.Status = StatusResolved
+				current.EndsAt = &now
+				m.resolved = append(m.resolved, current)
+			}
+		}
+	}
+}
+
+// batch is a notification for one receiver, with the alerts it is about
+type batch struct {
+	notifier     Notifier
+	notification Notification
+	alerts       []*Alert        // Alerts of notification, in the same order
+	failed       map[string]bool // Keys of alerts not delivered, if others were
+	delivered    bool
+}
+
+// Notify sends each receiver the alerts that started firing, changed
+// severity or are due for a repeat for it, and the resolution of alerts it
+// was sent, in one notification. What a receiver fails to get is sent again
+// next time, without repeating what the others got. Notifications are sent
+// without blocking Observe; a Notify while another is sending does nothing,
+// as the next one picks up its alerts.
+func (m *Manager) Notify(ctx context.Context, now time.Time) {
+	if !m.sendMu.TryLock() {
+		return
+	}
+	defer m.sendMu.Unlock()
+
+	m.mu.Lock()
+	batches := m.batches(now)
+	m.mu.Unlock()
+
+	for i := range batches {
+		b := &batches[i]
+		name := b.notifier.Name()
+		err := b.notifier.Notify(ctx, &b.notification)
+		metrics.RecordNotification(name, err == nil)
+
+		var partial *PartialError
+		if err == nil || errors.As(err, &partial) {
+			b.delivered = true
+		}
+		if partial != nil {
+			b.failed = partial.Failed
+		}
+		if err != nil {
+			m.logger.Warn("failed to send alert notification",
+				zap.String("notifier", name),
+				zap.Int("alerts", len(b.alerts)),
+				zap.Int("failed", len(b.failed)),
+				zap.Error(err),
+			)
+			continue
+		}
+		m.logger.Info("alert notification sent",
+			zap.String("notifier", name),
+			zap.Int("firing", len(b.notification.Firing())),
+			zap.Int("resolved", len(b.notification.Resolved())),
+		)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range batches {
+		if batches[i].delivered {
+			m.recordDelivery(&batches[i], now)
+		}
+	}
+	m.pruneResolved()
+	m.save()
+}
+
+// batches builds the notification of every receiver with something to
+// send. Must be called with m.mu held.
+func (m *Manager) batches(now time.Time) []batch {
+	m.pruneResolved()
+
+	var batches []batch
+	for _, notifier := range m.notifiers {
+		name := notifier.Name()
+		b := batch{
+			notifier:     notifier,
+			notification: Notification{Agent: m.agent, Status: StatusResolved},
+		}
+
+		for _, key := range m.sortedKeys() {
+			if a := m.firing[key]; m.isDue(a, name, now) {
+				b.add(a, name)
+				b.notification.Status = StatusFiring
+			}
+		}
+		for _, a := range m.resolved {
+			if _, sent := a.Deliveries[name]; sent {
+				b.add(a, name)
+			}
+		}
+
+		if len(b.alerts) > 0 {
+			batches = append(batches, b)
+		}
+	}
+	return batches
+}
+
+// add adds an alert to the notification, as seen by the receiver name
+func (b *batch) add(a *Alert, name string) {
+	n := *a
+	n.Deliveries = nil
+	if d, sent := a.Deliveries[name]; sent && d.Severity != a.Severity {
+		n.PreviousSeverity = d.Severity
+	}
+	b.notification.Alerts = append(b.notification.Alerts, n)
+	b.alerts = append(b.alerts, a)
+}
+
+// recordDelivery records that a receiver got the alerts of its
+// notification that didn't fail. A firing
+// alert that resolved while it was sent is then resolved with the receiver.
+// Must be called with m.mu held.
+func (m *Manager) recordDelivery(b *batch, now time.Time) {
+	name := b.notifier.Name()
+	for i, a := range b.alerts {
+		sent := &b.notification.Alerts[i]
+		if b.failed[sent.Key()] {
+			continue
+		}
+		if sent.Status == StatusResolved {
+			delete(a.Deliveries, name)
+			continue
+		}
+		if a.Deliveries == nil {
+			a.Deliveries = make(map[string]Delivery)
+		}
+		a.Deliveries[name] = Delivery{SentAt: now, Severity: sent.Severity}
+	}
+	m.dirty = true
+}
+
+// pruneResolved drops resolved alerts no receiver needs to be told of,
+// including receivers that were removed. Must be called with m.mu and
+// m.sendMu held, so no notification of them is in flight.
+func (m *Manager) pruneResolved() {
+	names := make(map[string]bool, len(m.notifiers))
+	for _, notifier := range m.notifiers {
+		names[notifier.Name()] = true
+	}
+
+	kept := m.resolved[:0]
+	for _, a := range m.resolved {
+		for name := range a.Deliveries {
+			if !names[name] {
+				delete(a.Deliveries, name)
+			}
+		}
+		if len(a.Deliveries) > 0 {
+			kept = append(kept, a)
+		}
+	}
+	clear(m.resolved[len(kept):])
+	m.resolved = kept
+}
+
+// isDue reports whether a firing alert needs to be sent to the receiver
+// name: it never was, its severity changed or the repeat interval passed
+func (m *Manager) isDue(a *Alert, name string, now time.Time) bool {
+	d, sent := a.Deliveries[name]
+	switch {
+	case !sent || d.Severity != a.Severity:
+		return true
+	case m.settings.RepeatInterval == 0:
+		return false
+	default:
+		return now.Sub(d.SentAt) >= m.settings.RepeatInterval
+	}
+}
+
+// Forget drops the alerts of targets that are no longer monitored, without
+// notifying their resolution
+func (m *Manager) Forget(targets []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, target := range targets {
+		delete(m.failures, target)
+		for key, a := range m.firing {
+			if a.Target == target {
+				delete(m.firing, key)
+				m.dirty = true
+			}
+		}
+	}
+	m.save()
+}
+
+// Firing returns the firing alerts, ordered by rule and target
+func (m *Manager) Firing() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(m.firing))
+	for _, key := range m.sortedKeys() {
+		alerts = append(alerts, m.firing[key].clone())
+	}
+	return alerts
+}
+
+func (m *Manager) sortedKeys() []string {
+	keys := make([]string, 0, len(m.firing))
+	for key := range m.firing {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// save persists the firing alerts if they changed. Must be called with
+// m.mu held.
+func (m *Manager) save() {
+	if !m.dirty {
+		return
+	}
+	m.dirty = false
+	m.updateMetrics()
+
+	alerts := make([]Alert, 0, len(m.firing))
+	for _, key := range m.sortedKeys() {
+		alerts = append(alerts, m.firing[key].clone())
+	}
+	m.store.SetAlerts(alerts)
+	if err := m.store.Save(); err != nil {
+		m.logger.Warn("failed to save alert state", zap.Error(err))
+	}
+}
+
+func (m *Manager) updateMetrics() {
+	counts := make(map[[2]string]int)
+	for _, a := range m.firing {
+		counts[[2]string{a.Rule, a.Severity}]++
+	}
+	metrics.SetAlertsFiring(counts)
+}
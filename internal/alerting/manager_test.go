@@ -0,0 +1,291 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// memStore keeps alerts in memory
+type memStore struct {
+	alerts []Alert
+}
+
+func (s *memStore) GetAlerts() []Alert       { return s.alerts }
+func (s *memStore) SetAlerts(alerts []Alert) { s.alerts = alerts }
+func (s *memStore) Save() error              { return nil }
+
+// recorder is a notifier that records notifications
+type recorder struct {
+	err  error
+	name string // "recorder" if empty
+	sent []Notification
+}
+
+func (r *recorder) Name() string {
+	if r.name == "" {
+		return "recorder"
+	}
+	return r.name
+}
+
+func (r *recorder) Notify(_ context.Context, n *Notification) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, *n)
+	return nil
+}
+
+func testManager(t *testing.T, store Store, recs ...*recorder) *Manager {
+	t.Helper()
+	cfg := &config.Config{
+		Agent: config.AgentConfig{Name: "agent"},
+		Alerting: config.AlertingConfig{
+			ExpiryWarningDays:  30,
+			ExpiryCriticalDays: 7,
+			FailureThreshold:   2,
+			RepeatInterval:     time.Hour,
+			SendResolved:       true,
+		},
+	}
+	m, err := New(cfg, store, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	m.notifiers = nil
+	for _, rec := range recs {
+		m.notifiers = append(m.notifiers, rec)
+	}
+	return m
+}
+
+func expiring(days int) *scanner.ScanResult {
+	return &scanner.ScanResult{
+		Hostname: "a.example.com",
+		Port:     443,
+		Success:  true,
+		Certificate: &scanner.CertificateInfo{
+			DaysUntilExpiry: days,
+			NotAfter:        time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Chain: &scanner.ChainInfo{Valid: true},
+	}
+}
+
+// summarize lists the rule, severity and status of each notified alert
+func summarize(n Notification) []string {
+	var s []string
+	for _, a := range n.Alerts {
+		s = append(s, a.Rule+" "+a.Severity+" "+a.Status)
+	}
+	return s
+}
+
+func TestManager_Lifecycle(t *testing.T) {
+	store := &memStore{}
+	rec := &recorder{}
+	m := testManager(t, store, rec)
+	now := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name   string
+		result *scanner.ScanResult
+		after  time.Duration
+		want   []string // Notified alerts, nil for no notification
+	}{
+		{"healthy", expiring(60), 0, nil},
+		{"fires", expiring(20), time.Minute, []string{"expiry warning firing"}},
+		{"deduplicated", expiring(19), time.Minute, nil},
+		{"severity change", expiring(5), time.Minute, []string{"expiry critical firing"}},
+		{"repeat", expiring(5), time.Hour, []string{"expiry critical firing"}},
+		{"first failure", &scanner.ScanResult{Hostname: "a.example.com", Port: 443, Error: "timeout"}, time.Minute, nil},
+		{"failure threshold", &scanner.ScanResult{Hostname: "a.example.com", Port: 443, Error: "timeout"}, time.Minute, []string{"scan_failure critical firing"}},
+		{"renewed", expiring(90), time.Minute, []string{"scan_failure critical resolved", "expiry critical resolved"}},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.after)
+		before := len(rec.sent)
+		m.Observe(step.result, []string{"prod"}, now)
+		m.Notify(context.Background(), now)
+
+		switch {
+		case step.want == nil && len(rec.sent) != before:
+			t.Errorf("%s: unexpected notification %v", step.name, summarize(rec.sent[len(rec.sent)-1]))
+		case step.want != nil && len(rec.sent) != before+1:
+			t.Errorf("%s: expected a notification", step.name)
+		case step.want != nil:
+			if got := summarize(rec.sent[len(rec.sent)-1]); !slices.Equal(got, step.want) {
+				t.Errorf("%s: notified %v, want %v", step.name, got, step.want)
+			}
+		}
+	}
+
+	if len(store.alerts) != 0 {
+		t.Errorf("stored alerts = %+v, want none after resolution", store.alerts)
+	}
+}
+
+func TestManager_RestoresFiringAlerts(t *testing.T) {
+	store := &memStore{}
+	rec := &recorder{}
+	m := testManager(t, store, rec)
+	now := time.Now()
+
+	m.Observe(expiring(3), nil, now)
+	m.Notify(context.Background(), now)
+	if len(store.alerts) != 1 || store.alerts[0].Deliveries["recorder"].SentAt.IsZero() {
+		t.Fatalf("stored alerts = %+v, want the sent expiry alert", store.alerts)
+	}
+
+	// A restarted agent doesn't repeat the alert, but resolves it
+	restarted := testManager(t, store, rec)
+	restarted.Observe(expiring(3), nil, now.Add(time.Minute))
+	restarted.Notify(context.Background(), now.Add(time.Minute))
+	if len(rec.sent) != 1 {
+		t.Fatalf("got %d notifications, want no repeat after restart", len(rec.sent))
+	}
+
+	restarted.Observe(expiring(90), nil, now.Add(2*time.Minute))
+	restarted.Notify(context.Background(), now.Add(2*time.Minute))
+	if len(rec.sent) != 2 || rec.sent[1].Status != StatusResolved {
+		t.Errorf("notifications = %+v, want the resolution", rec.sent)
+	}
+}
+
+func TestManager_SeverityChangeSurvivesRestart(t *testing.T) {
+	store := &memStore{}
+	rec := &recorder{}
+	m := testManager(t, store, rec)
+	now := time.Now()
+
+	m.Observe(expiring(20), nil, now)
+	m.Notify(context.Background(), now)
+	rec.err = errors.New("unavailable")
+	m.Observe(expiring(5), nil, now.Add(time.Minute))
+	m.Notify(context.Background(), now.Add(time.Minute))
+	if len(store.alerts) != 1 || store.alerts[0].Deliveries["recorder"].Severity != SeverityWarning {
+		t.Fatalf("stored alerts = %+v, want the severity last sent", store.alerts)
+	}
+
+	// A restarted agent still sends the change, with the severity it replaces
+	rec.err = nil
+	restarted := testManager(t, store, rec)
+	restarted.Observe(expiring(5), nil, now.Add(2*time.Minute))
+	restarted.Notify(context.Background(), now.Add(2*time.Minute))
+	if len(rec.sent) != 2 {
+		t.Fatalf("got %d notifications, want the severity change", len(rec.sent))
+	}
+	a := rec.sent[1].Alerts[0]
+	if a.Severity != SeverityCritical || a.PreviousSeverity != SeverityWarning || a.Deliveries != nil {
+		t.Errorf("sent %+v, want critical replacing warning", a)
+	}
+	if store.alerts[0].Deliveries["recorder"].Severity != SeverityCritical {
+		t.Errorf("stored alerts = %+v, want the change recorded once sent", store.alerts)
+	}
+}
+
+func TestManager_DeliversPerReceiver(t *testing.T) {
+	healthy := &recorder{name: "healthy"}
+	failing := &recorder{name: "failing", err: errors.New("unavailable")}
+	m := testManager(t, &memStore{}, healthy, failing)
+	now := time.Now()
+
+	m.Observe(expiring(3), nil, now)
+	m.Notify(context.Background(), now)
+	m.Observe(expiring(90), nil, now.Add(time.Minute))
+	m.Notify(context.Background(), now.Add(time.Minute))
+	if len(healthy.sent) != 2 || summarize(healthy.sent[1])[0] != "expiry critical resolved" {
+		t.Fatalf("healthy receiver got %+v, want the alert and its resolution", healthy.sent)
+	}
+
+	// The failing receiver never got the alert, so it isn't told of its
+	// resolution. Once it recovers, both receivers get the next alert once.
+	m.Observe(expiring(3), []string{"b"}, now.Add(2*time.Minute))
+	failing.err = nil
+	m.Notify(context.Background(), now.Add(2*time.Minute))
+	if len(failing.sent) != 1 || !slices.Equal(summarize(failing.sent[0]), []string{"expiry critical firing"}) {
+		t.Errorf("failing receiver got %+v, want only the alert firing again", failing.sent)
+	}
+	if len(healthy.sent) != 3 || !slices.Equal(summarize(healthy.sent[2]), []string{"expiry critical firing"}) {
+		t.Errorf("healthy receiver got %+v, want the new alert once", healthy.sent)
+	}
+
+	// A resolution the receiver failed to get is kept until it gets it
+	failing.err = errors.New("unavailable")
+	m.Observe(expiring(90), nil, now.Add(3*time.Minute))
+	m.Notify(context.Background(), now.Add(3*time.Minute))
+	failing.err = nil
+	m.Notify(context.Background(), now.Add(4*time.Minute))
+	if len(failing.sent) != 2 || !slices.Equal(summarize(failing.sent[1]), []string{"expiry critical resolved"}) {
+		t.Errorf("failing receiver got %+v, want the resolution after recovering", failing.sent)
+	}
+	if len(healthy.sent) != 4 {
+		t.Errorf("healthy receiver got %d notifications, want the resolution once", len(healthy.sent))
+	}
+}
+
+func TestManager_RetriesFailedDelivery(t *testing.T) {
+	rec := &recorder{err: errors.New("unavailable")}
+	m := testManager(t, &memStore{}, rec)
+	now := time.Now()
+
+	m.Observe(expiring(3), nil, now)
+	m.Notify(context.Background(), now)
+
+	rec.err = nil
+	m.Notify(context.Background(), now.Add(time.Minute))
+	if len(rec.sent) != 1 || len(rec.sent[0].Alerts) != 1 {
+		t.Errorf("notifications = %+v, want the alert sent again", rec.sent)
+	}
+}
+
+func TestManager_RetriesPartialDelivery(t *testing.T) {
+	rec := &recorder{}
+	m := testManager(t, &memStore{}, rec)
+	now := time.Now()
+
+	other := expiring(3)
+	other.Hostname = "b.example.com"
+	m.Observe(expiring(3), nil, now)
+	m.Observe(other, nil, now)
+	rec.err = &PartialError{Failed: map[string]bool{"expiry/b.example.com:443": true}, Err: errors.New("rejected")}
+	m.Notify(context.Background(), now)
+
+	rec.err = nil
+	m.Notify(context.Background(), now.Add(time.Minute))
+	if len(rec.sent) != 1 || len(rec.sent[0].Alerts) != 1 || rec.sent[0].Alerts[0].Target != "b.example.com:443" {
+		t.Errorf("notifications = %+v, want only the failed alert sent again", rec.sent)
+	}
+}
+
+func TestManager_ChainAndForget(t *testing.T) {
+	rec := &recorder{}
+	m := testManager(t, &memStore{}, rec)
+
+	r := expiring(90)
+	r.Chain = &scanner.ChainInfo{Issues: []scanner.ChainIssue{
+		{Type: "expired", Severity: scanner.SeverityHigh, Message: "Certificate expired", CertificateIndex: 0},
+		{Type: "self_signed", Severity: scanner.SeverityMedium, Message: "Leaf certificate is self-signed"},
+	}}
+	m.Observe(r, nil, time.Now())
+
+	firing := m.Firing()
+	if len(firing) != 1 || firing[0].Severity != SeverityWarning || firing[0].Summary != "Leaf certificate is self-signed" {
+		t.Fatalf("firing = %+v, want a warning for the self-signed leaf only", firing)
+	}
+
+	m.Forget([]string{"a.example.com:443"})
+	m.Notify(context.Background(), time.Now())
+	if len(m.Firing()) != 0 || len(rec.sent) != 0 {
+		t.Errorf("expected forgotten alerts to be dropped without notification, sent %+v", rec.sent)
+	}
+}
@@ -0,0 +1,116 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+// pagerDutyMaxSummary is the longest summary the Events API accepts
+const pagerDutyMaxSummary = 1024
+
+// PagerDuty sends every alert as a PagerDuty Events API v2 event. Events of
+// an alert share a dedup key, so repeats update one PagerDuty alert and a
+// resolution closes it.
+type PagerDuty struct {
+	httpClient *http.Client
+	routingKey string
+	url        string
+}
+
+// pdEvent is an Events API v2 event
+type pdEvent struct {
+	Payload     *pdPayload `json:"payload,omitempty"` // Only for triggers
+	RoutingKey  string     `json:"routing_key"`
+	EventAction string     `json:"event_action"`
+	DedupKey    string     `json:"dedup_key"`
+}
+
+type pdPayload struct {
+	CustomDetails map[string]string `json:"custom_details"`
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp"`
+	Component     string            `json:"component"`
+	Group         string            `json:"group"`
+	Class         string            `json:"class"`
+}
+
+// NewPagerDuty creates a PagerDuty notifier
+func NewPagerDuty(cfg *config.PagerDutyConfig) *PagerDuty {
+	return &PagerDuty{
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		routingKey: cfg.RoutingKey,
+		url:        cfg.URL,
+	}
+}
+
+// Name returns the notifier's name
+func (p *PagerDuty) Name() string {
+	return "pagerduty"
+}
+
+// Notify sends a trigger event for every firing alert of n and a resolve
+// event for every resolved one
+func (p *PagerDuty) Notify(ctx context.Context, n *Notification) error {
+	var errs []string
+	for i := range n.Alerts {
+		a := &n.Alerts[i]
+		body, err := json.Marshal(p.event(a, n.Agent))
+		if err != nil {
+			return err
+		}
+		if err := postJSON(ctx, p.httpClient, p.url, nil, body); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", a.Key(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send events for %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// event builds the event of an alert. The dedup key leaves out the
+// severity, so a change of severity updates the same PagerDuty alert.
+func (p *PagerDuty) event(a *Alert, agent string) pdEvent {
+	e := pdEvent{
+		RoutingKey: p.routingKey,
+		DedupKey:   "cw-agent/" + agent + "/" + a.Key(),
+	}
+	if a.Status == StatusResolved {
+		e.EventAction = "resolve"
+		return e
+	}
+
+	summary := truncate(a.Target+": "+a.Summary, pagerDutyMaxSummary)
+	e.EventAction = "trigger"
+	e.Payload = &pdPayload{
+		CustomDetails: a.Labels(agent),
+		Summary:       summary,
+		Source:        a.Target,
+		Severity:      a.Severity, // warning and critical are PagerDuty severities too
+		Timestamp:     a.StartsAt.UTC().Format(time.RFC3339),
+		Component:     a.Hostname,
+		Group:         agent,
+		Class:         a.Rule,
+	}
+	return e
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,94 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+func TestPagerDuty_Notify(t *testing.T) {
+	var events []pdEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e pdEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events = append(events, e)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	p := NewPagerDuty(&config.PagerDutyConfig{RoutingKey: "key", URL: srv.URL, Timeout: time.Second})
+	endsAt := time.Now()
+	n := &Notification{
+		Agent: "edge",
+		Alerts: []Alert{
+			{Rule: RuleExpiry, Target: "a.example.com:443", Hostname: "a.example.com", Port: 443, Severity: SeverityCritical, Status: StatusFiring, Summary: "certificate expires in 3 days", Tags: []string{"prod"}},
+			{Rule: RuleChain, Target: "b.example.com:443", Hostname: "b.example.com", Port: 443, Severity: SeverityWarning, Status: StatusResolved, EndsAt: &endsAt},
+		},
+	}
+	if err := p.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("sent %d events, want 2", len(events))
+	}
+
+	trigger := events[0]
+	if trigger.EventAction != "trigger" || trigger.RoutingKey != "key" || trigger.DedupKey != "cw-agent/edge/expiry/a.example.com:443" {
+		t.Errorf("trigger = %+v", trigger)
+	}
+	if trigger.Payload == nil {
+		t.Fatal("expected the trigger to have a payload")
+	}
+	if trigger.Payload.Severity != "critical" || trigger.Payload.Source != "a.example.com:443" || trigger.Payload.Summary != "a.example.com:443: certificate expires in 3 days" {
+		t.Errorf("payload = %+v", trigger.Payload)
+	}
+	if trigger.Payload.CustomDetails["tags"] != "prod" || trigger.Payload.CustomDetails["port"] != "443" {
+		t.Errorf("custom details = %v, want the alert labels", trigger.Payload.CustomDetails)
+	}
+
+	resolve := events[1]
+	if resolve.EventAction != "resolve" || resolve.DedupKey != "cw-agent/edge/chain/b.example.com:443" || resolve.Payload != nil {
+		t.Errorf("resolve = %+v", resolve)
+	}
+}
+
+func TestPagerDuty_NotifyError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"status":"invalid event"}`, http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	p := NewPagerDuty(&config.PagerDutyConfig{RoutingKey: "key", URL: srv.URL, Timeout: time.Second})
+	n := &Notification{Alerts: []Alert{{Rule: RuleExpiry, Target: "a.example.com:443", Status: StatusFiring}}}
+	err := p.Notify(context.Background(), n)
+	if err == nil || !strings.Contains(err.Error(), "expiry/a.example.com:443") || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Notify() error = %v, want the failed alert and status", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"abcdef", 3, "abc"},
+		{"ab€", 4, "ab"}, // € is 3 bytes
+		{"ab€", 5, "ab€"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package alerting
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// finding is a rule that matches a scan result
+type finding struct {
+	severity string
+	summary  string
+}
+
+// evaluate returns the rules a scan result was evaluated against and the
+// findings of those that match. A failed scan is only evaluated against
+// RuleScanFailure, since nothing is known about the certificate.
+func evaluate(r *scanner.ScanResult, failures int, settings *config.AlertingConfig) ([]string, map[string]finding) {
+	findings := make(map[string]finding)
+
+	if !r.Success {
+		if failures >= settings.FailureThreshold {
+			findings[RuleScanFailure] = finding{
+				severity: SeverityCritical,
+				summary:  fmt.Sprintf("%d consecutive scans failed: %s", failures, r.Error),
+			}
+		}
+		return []string{RuleScanFailure}, findings
+	}
+
+	if f, ok := expiryFinding(r.Certificate, settings); ok {
+		findings[RuleExpiry] = f
+	}
+	if f, ok := chainFinding(r.Chain); ok {
+		findings[RuleChain] = f
+	}
+	return []string{RuleScanFailure, RuleExpiry, RuleChain}, findings
+}
+
+func expiryFinding(cert *scanner.CertificateInfo, settings *config.AlertingConfig) (finding, bool) {
+	if cert == nil {
+		return finding{}, false
+	}
+
+	days := cert.DaysUntilExpiry
+	var f finding
+	switch {
+	case days <= settings.ExpiryCriticalDays:
+		f.severity = SeverityCritical
+	case days <= settings.ExpiryWarningDays:
+		f.severity = SeverityWarning
+	default:
+		return finding{}, false
+	}
+
+	expiry := cert.NotAfter.Format("2006-01-02")
+	switch {
+	case days < 0:
+		f.summary = fmt.Sprintf("certificate expired %d days ago (%s)", -days, expiry)
+	case days == 0:
+		f.summary = fmt.Sprintf("certificate expires today (%s)", expiry)
+	default:
+		f.summary = fmt.Sprintf("certificate expires in %d days (%s)", days, expiry)
+	}
+	return f, true
+}
+
+// chainFinding reports medium and high severity chain issues. An expired
+// leaf is left to RuleExpiry.
+func chainFinding(chain *scanner.ChainInfo) (finding, bool) {
+	if chain == nil {
+		return finding{}, false
+	}
+
+	var f finding
+	var messages []string
+	for _, issue := range chain.Issues {
+		if issue.Type == "expired" && issue.CertificateIndex == 0 {
+			continue
+		}
+		switch issue.Severity {
+		case scanner.SeverityHigh:
+			f.severity = SeverityCritical
+		case scanner.SeverityMedium:
+			if f.severity == "" {
+				f.severity = SeverityWarning
+			}
+		default:
+			continue
+		}
+		messages = append(messages, issue.Message)
+	}
+	if len(messages) == 0 {
+		return finding{}, false
+	}
+
+	f.summary = strings.Join(messages, "; ")
+	return f, true
+}
@@ -0,0 +1,34 @@
+// Package tmpl parses the Go templates that shape alert notifications.
+// It is separate from alerting so the configuration can check templates
+// without importing the notifiers.
+package tmpl
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+)
+
+// Funcs are the functions available in notification templates
+var Funcs = template.FuncMap{
+	// json encodes a value as JSON, e.g. {"text": {{ .Summary | json }}}
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// jsonEscape escapes a string for use inside a JSON string, e.g.
+	// {"text": "{{ range .Alerts }}{{ .Summary | jsonEscape }}\n{{ end }}"}
+	"jsonEscape": func(s string) string {
+		data, _ := json.Marshal(s) // Marshaling a string can't fail
+		return string(data[1 : len(data)-1])
+	},
+	"join":  func(sep string, s []string) string { return strings.Join(s, sep) },
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Parse parses a notification template. Missing keys are errors, so a
+// typo in a field name isn't rendered as "<no value>".
+func Parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(Funcs).Option("missingkey=error").Parse(text)
+}
@@ -0,0 +1,104 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+
+	"github.com/certwatch-app/cw-agent/internal/alerting/tmpl"
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/version"
+)
+
+// Webhook posts notifications to an HTTP endpoint. The body is rendered
+// from the configured template, or is the notification as JSON.
+type Webhook struct {
+	headers    map[string]string
+	template   *template.Template // nil sends JSON
+	httpClient *http.Client
+	name       string
+	url        string
+}
+
+// NewWebhook creates a webhook notifier
+func NewWebhook(cfg *config.WebhookConfig) (*Webhook, error) {
+	w := &Webhook{
+		headers: cfg.Headers,
+		name:    cfg.Name,
+		url:     cfg.URL,
+	}
+
+	if cfg.Template != "" {
+		t, err := tmpl.Parse(cfg.Name, cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		w.template = t
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = config.DefaultNotifierTimeout
+	}
+	w.httpClient = &http.Client{Timeout: timeout}
+
+	return w, nil
+}
+
+// Name returns the webhook's configured name
+func (w *Webhook) Name() string {
+	return "webhook/" + w.name
+}
+
+// Notify posts a notification
+func (w *Webhook) Notify(ctx context.Context, n *Notification) error {
+	body, err := w.render(n)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, w.httpClient, w.url, w.headers, body)
+}
+
+// postJSON posts a JSON body with extra headers. Any status other than 2xx
+// is an error.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("cw-agent/%s", version.GetVersion()))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server returned status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// render builds the request body of a notification
+func (w *Webhook) render(n *Notification) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(n)
+	}
+
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, n); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
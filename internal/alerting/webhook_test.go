@@ -0,0 +1,109 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+func TestWebhook_Notify(t *testing.T) {
+	n := &Notification{
+		Agent:  "edge",
+		Status: StatusFiring,
+		Alerts: []Alert{
+			{Rule: RuleExpiry, Target: "a.example.com:443", Severity: SeverityCritical, Status: StatusFiring, Summary: `expires "soon"`},
+			{Rule: RuleChain, Target: "b.example.com:443", Severity: SeverityWarning, Status: StatusResolved},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name: "default JSON",
+			want: `{"agent":"edge","status":"firing","alerts":[` +
+				`{"starts_at":"0001-01-01T00:00:00Z","rule":"expiry","target":"a.example.com:443","hostname":"","severity":"critical","status":"firing","summary":"expires \"soon\"","port":0},` +
+				`{"starts_at":"0001-01-01T00:00:00Z","rule":"chain","target":"b.example.com:443","hostname":"","severity":"warning","status":"resolved","summary":"","port":0}]}`,
+		},
+		{
+			name:     "slack template",
+			template: `{"text": {{ printf "%s: %d firing, %d resolved" .Agent (len .Firing) (len .Resolved) | json }}}`,
+			want:     `{"text": "edge: 1 firing, 1 resolved"}`,
+		},
+		{
+			name:     "message text",
+			template: `{"text": "{{ range .Firing }}[{{ .Severity }}] {{ .Target }}: {{ .Summary | jsonEscape }}\n{{ end }}"}`,
+			want:     `{"text": "[critical] a.example.com:443: expires \"soon\"\n"}`,
+		},
+		{
+			name:     "escaping",
+			template: `{{ range .Alerts }}{{ .Severity | upper }} {{ .Summary | json }};{{ end }}`,
+			want:     `CRITICAL "expires \"soon\"";WARNING "";`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body, auth string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				body = string(data)
+				auth = r.Header.Get("Authorization")
+			}))
+			defer srv.Close()
+
+			w, err := NewWebhook(&config.WebhookConfig{
+				Name:     "test",
+				URL:      srv.URL,
+				Template: tt.template,
+				Headers:  map[string]string{"authorization": "Bearer token"},
+			})
+			if err != nil {
+				t.Fatalf("NewWebhook() error = %v", err)
+			}
+			if err := w.Notify(context.Background(), n); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+
+			if body != tt.want {
+				t.Errorf("body =\n%s\nwant\n%s", body, tt.want)
+			}
+			if tt.template == "" && !json.Valid([]byte(body)) {
+				t.Error("expected the default body to be valid JSON")
+			}
+			if auth != "Bearer token" {
+				t.Errorf("Authorization = %q, want the configured header", auth)
+			}
+		})
+	}
+}
+
+func TestWebhook_NotifyError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(&config.WebhookConfig{Name: "test", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	err = w.Notify(context.Background(), &Notification{})
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "invalid payload") {
+		t.Errorf("Notify() error = %v, want the status and response", err)
+	}
+}
+
+func TestNewWebhook_InvalidTemplate(t *testing.T) {
+	if _, err := NewWebhook(&config.WebhookConfig{Name: "test", URL: "http://localhost", Template: "{{ .Agent"}); err == nil {
+		t.Error("NewWebhook() succeeded for an invalid template")
+	}
+}
@@ -3,6 +3,7 @@ package certmanager
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	gosync "sync"
 	"time"
 
@@ -22,6 +23,7 @@ import (
 	"github.com/certwatch-app/cw-agent/internal/certmanager/controller"
 	"github.com/certwatch-app/cw-agent/internal/certmanager/metrics"
 	"github.com/certwatch-app/cw-agent/internal/certmanager/types"
+	"github.com/certwatch-app/cw-agent/internal/outbox"
 	"github.com/certwatch-app/cw-agent/internal/state"
 	"github.com/certwatch-app/cw-agent/internal/sync"
 	"github.com/certwatch-app/cw-agent/internal/version"
@@ -62,12 +64,22 @@ func New(cfg *config.Config, stateManager *state.Manager) (*Agent, error) {
 
 	// Create sync client using the config adapter
 	syncCfg := &sync.ClientConfig{
-		Endpoint: cfg.API.Endpoint,
-		APIKey:   cfg.API.Key,
-		Timeout:  cfg.API.Timeout,
+		Endpoint:   cfg.API.Endpoint,
+		APIKey:     cfg.API.Key,
+		APIKeyFile: cfg.API.KeyFile,
+		Timeout:    cfg.API.Timeout,
 	}
 	syncClient := sync.NewWithConfig(syncCfg, cfg.Agent.Name, logger, stateManager)
 
+	// Queue payloads on disk, so events from an API outage aren't lost
+	if cfg.API.OutboxSizeMB > 0 {
+		o, err := outbox.Open(filepath.Join(stateManager.Dir(), outbox.DirName), int64(cfg.API.OutboxSizeMB)<<20)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open outbox: %w", err)
+		}
+		syncClient.SetOutbox(o)
+	}
+
 	return &Agent{
 		config:                cfg,
 		logger:                logger,
@@ -162,6 +174,9 @@ func (a *Agent) Run(ctx context.Context) error {
 	// Start sync loop in background
 	go a.syncLoop(ctx)
 
+	// Retry payloads queued while the API couldn't be reached
+	go a.syncClient.RunOutbox(ctx)
+
 	// Start heartbeat loop in background if enabled
 	if a.config.Agent.HeartbeatInterval > 0 {
 		go a.heartbeatLoop(ctx)
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/certwatch-app/cw-agent/internal/secret"
 )
 
 // Config holds all configuration for the cert-manager agent
@@ -17,7 +19,11 @@ type Config struct {
 type APIConfig struct {
 	Endpoint string        `mapstructure:"endpoint"`
 	Key      string        `mapstructure:"key"`
+	KeyFile  string        `mapstructure:"key_file"` // Takes precedence over key; re-read when it changes
 	Timeout  time.Duration `mapstructure:"timeout"`
+	// OutboxSizeMB bounds the payloads queued on disk while the API can't be
+	// reached; 0 disables the outbox
+	OutboxSizeMB int `mapstructure:"outbox_size_mb"`
 }
 
 // AgentConfig holds agent-specific settings
@@ -32,6 +38,12 @@ type AgentConfig struct {
 	Namespaces        []string      `mapstructure:"namespaces"` // If not watching all
 }
 
+// Limits enforced by Validate and published in the JSON Schema
+const (
+	MinSyncInterval = 10 * time.Second
+	MaxMetricsPort  = 65535
+)
+
 // Load loads configuration from viper
 func Load(v *viper.Viper) (*Config, error) {
 	setDefaults(v)
@@ -41,6 +53,15 @@ func Load(v *viper.Viper) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// The sync client re-reads the key file when it changes
+	if cfg.API.KeyFile != "" {
+		key, err := secret.ReadKeyFile(cfg.API.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("api.key_file: %w", err)
+		}
+		cfg.API.Key = key
+	}
+
 	// ClusterName defaults to Name if not set
 	if cfg.Agent.ClusterName == "" {
 		cfg.Agent.ClusterName = cfg.Agent.Name
@@ -52,6 +73,7 @@ func Load(v *viper.Viper) (*Config, error) {
 func setDefaults(v *viper.Viper) {
 	v.SetDefault("api.endpoint", "https://api.certwatch.app")
 	v.SetDefault("api.timeout", "30s")
+	v.SetDefault("api.outbox_size_mb", 50)
 	v.SetDefault("agent.log_level", "info")
 	v.SetDefault("agent.metrics_port", 9402)
 	v.SetDefault("agent.sync_interval", "30s")
@@ -62,16 +84,19 @@ func setDefaults(v *viper.Viper) {
 // Validate validates the configuration
 func (c *Config) Validate() error {
 	if c.API.Key == "" {
-		return fmt.Errorf("api.key is required")
+		return fmt.Errorf("api.key or api.key_file is required")
 	}
 	if c.Agent.Name == "" {
 		return fmt.Errorf("agent.name is required")
 	}
-	if c.Agent.MetricsPort < 0 || c.Agent.MetricsPort > 65535 {
-		return fmt.Errorf("agent.metrics_port must be between 0 and 65535")
+	if c.Agent.MetricsPort < 0 || c.Agent.MetricsPort > MaxMetricsPort {
+		return fmt.Errorf("agent.metrics_port must be between 0 and %d", MaxMetricsPort)
+	}
+	if c.Agent.SyncInterval < MinSyncInterval {
+		return fmt.Errorf("agent.sync_interval must be at least %s", MinSyncInterval)
 	}
-	if c.Agent.SyncInterval < 10*time.Second {
-		return fmt.Errorf("agent.sync_interval must be at least 10s")
+	if c.API.OutboxSizeMB < 0 {
+		return fmt.Errorf("api.outbox_size_mb must not be negative (or 0 to disable)")
 	}
 	return nil
 }
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -39,6 +41,9 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.Agent.HeartbeatInterval != 30*time.Second {
 		t.Errorf("Agent.HeartbeatInterval = %v, want 30s", cfg.Agent.HeartbeatInterval)
 	}
+	if cfg.API.OutboxSizeMB != 50 {
+		t.Errorf("API.OutboxSizeMB = %v, want 50", cfg.API.OutboxSizeMB)
+	}
 }
 
 func TestLoad_ClusterNameDefaultsToAgentName(t *testing.T) {
@@ -190,3 +195,28 @@ func TestValidate_InvalidMetricsPort(t *testing.T) {
 		t.Error("Validate() error = nil, want error for invalid metrics_port")
 	}
 }
+
+func TestLoad_KeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(keyFile, []byte("cw_from_file\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+
+	v := viper.New()
+	v.Set("api.key", "cw_literal")
+	v.Set("api.key_file", keyFile)
+	v.Set("agent.name", "test-agent")
+
+	cfg, err := Load(v)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.API.Key != "cw_from_file" {
+		t.Errorf("API.Key = %v, want the key from key_file", cfg.API.Key)
+	}
+
+	v.Set("api.key_file", filepath.Join(t.TempDir(), "missing"))
+	if _, err := Load(v); err == nil {
+		t.Error("expected an error for a missing key file")
+	}
+}
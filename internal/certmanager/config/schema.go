@@ -0,0 +1,45 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/certwatch-app/cw-agent/internal/jsonschema"
+	"github.com/certwatch-app/cw-agent/internal/secret"
+)
+
+// Schema returns the JSON Schema of the cert-manager agent configuration
+// file, with the limits Validate enforces and the defaults Load applies
+func Schema() *jsonschema.Schema {
+	s := schema()
+	s.Schema = jsonschema.Draft
+	s.ID = "https://certwatch.app/schemas/cw-agent-certmanager.json"
+	s.Title = "CertWatch cert-manager agent configuration"
+	s.AllowAlternative(&jsonschema.Schema{Type: "string", Pattern: secret.ReferencePattern})
+	return s
+}
+
+// schema builds the configuration schema before environment references are
+// allowed, so its constraints can be checked against Validate
+func schema() *jsonschema.Schema {
+	s := jsonschema.Reflect(Config{})
+
+	api := s.Field("api")
+	api.Field("outbox_size_mb").Minimum = jsonschema.Int(0)
+	api.Field("outbox_size_mb").Description = "0 disables the outbox"
+
+	agent := s.Field("agent")
+	agent.Field("name").MinLength = jsonschema.Int(1)
+	agent.Field("cluster_name").Description = "Defaults to agent.name"
+	agent.Field("metrics_port").Minimum = jsonschema.Int(0)
+	agent.Field("metrics_port").Maximum = jsonschema.Int(MaxMetricsPort)
+	agent.Field("sync_interval").MinimumDuration = MinSyncInterval.String()
+	agent.Field("namespaces").Description = "Namespaces to watch unless watch_all_namespaces is set"
+
+	v := viper.New()
+	setDefaults(v)
+	for _, key := range v.AllKeys() {
+		s.Field(key).Default = v.Get(key)
+	}
+
+	return s
+}
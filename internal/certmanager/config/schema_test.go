@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/jsonschema"
+)
+
+// TestSchema_MatchesValidate checks every limit in the schema against
+// Validate, so the two can't drift apart
+func TestSchema_MatchesValidate(t *testing.T) {
+	for _, c := range jsonschema.Cases(schema()) {
+		t.Run(c.String(), func(t *testing.T) {
+			cfg := &Config{
+				API:   APIConfig{Key: "cw_test"},
+				Agent: AgentConfig{Name: "test", MetricsPort: 9402, SyncInterval: time.Minute},
+			}
+			if err := jsonschema.Set(cfg, c.Path, c.Value); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			err := cfg.Validate()
+			if c.Valid && err != nil {
+				t.Errorf("schema allows the value, but Validate() error = %v", err)
+			}
+			if !c.Valid && err == nil {
+				t.Error("schema rejects the value, but Validate() accepts it")
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s := Schema()
+	if got := s.Field("agent.metrics_port").Default; got != 9402 {
+		t.Errorf("agent.metrics_port default = %v, want 9402", got)
+	}
+	if got := s.Field("agent.watch_all_namespaces").Default; got != true {
+		t.Errorf("agent.watch_all_namespaces default = %v, want true", got)
+	}
+}
@@ -3,6 +3,8 @@ package metrics
 import (
 	"github.com/prometheus/client_golang/prometheus"
 	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/certwatch-app/cw-agent/internal/outbox"
 )
 
 func init() {
@@ -32,6 +34,8 @@ func init() {
 		EventSyncTotal,
 		RequestSyncTotal,
 	)
+	// Outbox metrics, shared with the agent
+	ctrlmetrics.Registry.MustRegister(outbox.Collectors()...)
 }
 
 var (
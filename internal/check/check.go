@@ -0,0 +1,173 @@
+// Package check evaluates scan results against expiry and chain issue
+// thresholds, for one-shot scans that gate CI pipelines, and writes the
+// outcome in formats CI systems understand.
+package check
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// Statuses of a result, in increasing order of severity
+const (
+	StatusOK       = "ok"
+	StatusWarning  = "warning"
+	StatusCritical = "critical"
+)
+
+// Rules a finding can come from. Chain issues use RuleChainPrefix followed
+// by the issue type, e.g. "chain/pin_mismatch".
+const (
+	RuleScanFailure = "scan_failure"
+	RuleExpiry      = "expiry"
+	RuleChainPrefix = "chain/"
+)
+
+// severities orders chain issue severities from lowest to highest
+var severities = []string{scanner.SeverityLow, scanner.SeverityMedium, scanner.SeverityHigh}
+
+// Thresholds decide the status of a scan result
+type Thresholds struct {
+	// CriticalSeverity is the lowest chain issue severity that is critical.
+	// Less severe issues are warnings. Empty makes every issue a warning.
+	CriticalSeverity string
+	WarningDays      int // Warning within this many days of expiry
+	CriticalDays     int // Critical within this many days of expiry
+}
+
+// ValidSeverity returns true if s can be used as Thresholds.CriticalSeverity
+func ValidSeverity(s string) bool {
+	return s == "" || slices.Contains(severities, s)
+}
+
+// Finding is a threshold a scan result crossed
+type Finding struct {
+	Rule    string `json:"rule"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Result is an evaluated scan result
+// Fields are ordered for optimal memory alignment
+type Result struct {
+	Scan     *scanner.ScanResult
+	Findings []Finding
+	Target   string // hostname:port
+	Source   string // Config file the target was defined in, if any
+	Status   string
+}
+
+// Evaluate checks a scan result against the thresholds
+func Evaluate(r *scanner.ScanResult, t *Thresholds) Result {
+	result := Result{Scan: r, Target: fmt.Sprintf("%s:%d", r.Hostname, r.Port), Status: StatusOK}
+
+	if !r.Success {
+		result.add(Finding{Rule: RuleScanFailure, Status: StatusCritical, Message: "scan failed: " + r.Error})
+		return result
+	}
+
+	if f, ok := expiryFinding(r.Certificate, t); ok {
+		result.add(f)
+	}
+	if r.Chain != nil {
+		for _, issue := range r.Chain.Issues {
+			// An expired leaf is left to RuleExpiry
+			if issue.Type == "expired" && issue.CertificateIndex == 0 {
+				continue
+			}
+			status := StatusWarning
+			if t.CriticalSeverity != "" && severityRank(issue.Severity) >= severityRank(t.CriticalSeverity) {
+				status = StatusCritical
+			}
+			result.add(Finding{Rule: RuleChainPrefix + issue.Type, Status: status, Message: issue.Message})
+		}
+	}
+	return result
+}
+
+// Failed returns true if the status of a result is critical, or a warning
+// with failOnWarning
+func (r *Result) Failed(failOnWarning bool) bool {
+	return r.Status == StatusCritical || (failOnWarning && r.Status == StatusWarning)
+}
+
+func (r *Result) add(f Finding) {
+	r.Findings = append(r.Findings, f)
+	if statusRank(f.Status) > statusRank(r.Status) {
+		r.Status = f.Status
+	}
+}
+
+// Summary counts results by status
+type Summary struct {
+	OK       int `json:"ok"`
+	Warning  int `json:"warning"`
+	Critical int `json:"critical"`
+}
+
+// Summarize counts the results by status
+func Summarize(results []Result) Summary {
+	var s Summary
+	for i := range results {
+		switch results[i].Status {
+		case StatusCritical:
+			s.Critical++
+		case StatusWarning:
+			s.Warning++
+		default:
+			s.OK++
+		}
+	}
+	return s
+}
+
+// Status returns the most severe status of the summarized results
+func (s Summary) Status() string {
+	switch {
+	case s.Critical > 0:
+		return StatusCritical
+	case s.Warning > 0:
+		return StatusWarning
+	default:
+		return StatusOK
+	}
+}
+
+func expiryFinding(cert *scanner.CertificateInfo, t *Thresholds) (Finding, bool) {
+	if cert == nil {
+		return Finding{}, false
+	}
+
+	days := cert.DaysUntilExpiry
+	f := Finding{Rule: RuleExpiry}
+	switch {
+	case days <= t.CriticalDays:
+		f.Status = StatusCritical
+	case days <= t.WarningDays:
+		f.Status = StatusWarning
+	default:
+		return Finding{}, false
+	}
+
+	expiry := cert.NotAfter.Format(time.DateOnly)
+	switch {
+	case days < 0:
+		f.Message = fmt.Sprintf("certificate expired %d days ago (%s)", -days, expiry)
+	case days == 0:
+		f.Message = fmt.Sprintf("certificate expires today (%s)", expiry)
+	default:
+		f.Message = fmt.Sprintf("certificate expires in %d days (%s)", days, expiry)
+	}
+	return f, true
+}
+
+func severityRank(severity string) int {
+	return slices.Index(severities, severity)
+}
+
+func statusRank(status string) int {
+	return slices.Index([]string{StatusOK, StatusWarning, StatusCritical}, status)
+}
@@ -0,0 +1,247 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+var thresholds = Thresholds{CriticalSeverity: scanner.SeverityHigh, WarningDays: 30, CriticalDays: 7}
+
+func scanResult(hostname string, days int, issues ...scanner.ChainIssue) *scanner.ScanResult {
+	return &scanner.ScanResult{
+		Hostname: hostname,
+		Port:     443,
+		Success:  true,
+		Certificate: &scanner.CertificateInfo{
+			Subject:         hostname,
+			Issuer:          "Test CA",
+			NotAfter:        time.Now().AddDate(0, 0, days),
+			DaysUntilExpiry: days,
+		},
+		Chain: &scanner.ChainInfo{Issues: issues},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	pinMismatch := scanner.ChainIssue{Type: scanner.IssuePinMismatch, Severity: scanner.SeverityHigh, Message: "pin"}
+	weak := scanner.ChainIssue{Type: "weak_signature", Severity: scanner.SeverityMedium, Message: "weak"}
+	expired := scanner.ChainIssue{Type: "expired", Severity: scanner.SeverityHigh, Message: "expired"}
+
+	tests := []struct {
+		name       string
+		result     *scanner.ScanResult
+		thresholds Thresholds
+		wantStatus string
+		wantRules  []string
+	}{
+		{"healthy", scanResult("a.example.com", 90), thresholds, StatusOK, nil},
+		{"expires soon", scanResult("a.example.com", 30), thresholds, StatusWarning, []string{RuleExpiry}},
+		{"expires very soon", scanResult("a.example.com", 7), thresholds, StatusCritical, []string{RuleExpiry}},
+		{"expired leaf only counts once", scanResult("a.example.com", -1, expired), thresholds, StatusCritical, []string{RuleExpiry}},
+		{"high severity issue", scanResult("a.example.com", 90, pinMismatch), thresholds, StatusCritical, []string{"chain/pin_mismatch"}},
+		{"medium severity issue", scanResult("a.example.com", 90, weak), thresholds, StatusWarning, []string{"chain/weak_signature"}},
+		{
+			"medium severity issue, critical from medium",
+			scanResult("a.example.com", 90, weak),
+			Thresholds{CriticalSeverity: scanner.SeverityMedium, WarningDays: 30, CriticalDays: 7},
+			StatusCritical, []string{"chain/weak_signature"},
+		},
+		{
+			"issues are never critical without a severity",
+			scanResult("a.example.com", 90, pinMismatch),
+			Thresholds{WarningDays: 30, CriticalDays: 7},
+			StatusWarning, []string{"chain/pin_mismatch"},
+		},
+		{
+			"scan failure",
+			&scanner.ScanResult{Hostname: "a.example.com", Port: 443, Error: "connection refused"},
+			thresholds, StatusCritical, []string{RuleScanFailure},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Evaluate(tt.result, &tt.thresholds)
+			if got.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q (findings %v)", got.Status, tt.wantStatus, got.Findings)
+			}
+			var rules []string
+			for _, f := range got.Findings {
+				rules = append(rules, f.Rule)
+			}
+			if strings.Join(rules, ",") != strings.Join(tt.wantRules, ",") {
+				t.Errorf("rules = %v, want %v", rules, tt.wantRules)
+			}
+			if got.Target != "a.example.com:443" {
+				t.Errorf("Target = %q", got.Target)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	results := []Result{
+		Evaluate(scanResult("a.example.com", 90), &thresholds),
+		Evaluate(scanResult("b.example.com", 20), &thresholds),
+	}
+	s := Summarize(results)
+	if s.OK != 1 || s.Warning != 1 || s.Critical != 0 || s.Status() != StatusWarning {
+		t.Errorf("Summarize() = %+v, status %q", s, s.Status())
+	}
+	if results[1].Failed(false) || !results[1].Failed(true) {
+		t.Error("a warning should only fail with failOnWarning")
+	}
+}
+
+func testResults() []Result {
+	failed := Evaluate(&scanner.ScanResult{Hostname: "down.example.com", Port: 443, Error: "timeout"}, &thresholds)
+	failed.Source = "certwatch.yaml"
+	return []Result{
+		Evaluate(scanResult("ok.example.com", 90), &thresholds),
+		Evaluate(scanResult("soon.example.com", 20), &thresholds),
+		failed,
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, testResults()); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+
+	var got junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+	if got.Tests != 3 || got.Failures != 1 || len(got.Suites) != 1 {
+		t.Fatalf("tests = %d, failures = %d, suites = %d", got.Tests, got.Failures, len(got.Suites))
+	}
+	cases := got.Suites[0].Cases
+	if cases[0].Failure != nil || cases[1].Failure != nil {
+		t.Error("only critical results should fail")
+	}
+	if !strings.Contains(cases[1].SystemOut, "expires in 20 days") {
+		t.Errorf("warning missing from output: %q", cases[1].SystemOut)
+	}
+	if cases[2].Failure == nil || !strings.Contains(cases[2].Failure.Message, "timeout") {
+		t.Errorf("failure = %+v, want the scan error", cases[2].Failure)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, testResults()); err != nil {
+		t.Fatalf("WriteSARIF() error = %v", err)
+	}
+
+	var got sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Version != "2.1.0" || len(got.Runs) != 1 {
+		t.Fatalf("version = %q, runs = %d", got.Version, len(got.Runs))
+	}
+	run := got.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || len(run.Results) != 2 {
+		t.Fatalf("got %d rules and %d results, want 2 of each", len(run.Tool.Driver.Rules), len(run.Results))
+	}
+	if r := run.Results[0]; r.RuleID != RuleExpiry || r.Level != "warning" || r.Locations[0].PhysicalLocation != nil {
+		t.Errorf("first result = %+v", r)
+	}
+	r := run.Results[1]
+	if r.RuleID != RuleScanFailure || r.Level != "error" {
+		t.Errorf("second result = %+v", r)
+	}
+	if loc := r.Locations[0].PhysicalLocation; loc == nil || loc.ArtifactLocation.URI != "certwatch.yaml" {
+		t.Errorf("location = %+v, want the config file", loc)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatJSON, testResults()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var got jsonReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Status != StatusCritical || got.Summary != (Summary{OK: 1, Warning: 1, Critical: 1}) {
+		t.Errorf("status = %q, summary = %+v", got.Status, got.Summary)
+	}
+	if len(got.Results) != 3 || got.Results[0].DaysUntilExpiry == nil || *got.Results[0].DaysUntilExpiry != 90 {
+		t.Errorf("results = %+v", got.Results)
+	}
+
+	if err := Write(&buf, "yaml", nil); err == nil {
+		t.Error("Write() with an unknown format succeeded")
+	}
+}
+
+func TestNagios(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   *scanner.ScanResult
+		wantCode int
+		wantLine string
+	}{
+		{
+			"ok",
+			scanResult("ok.example.com", 90),
+			NagiosOK,
+			"CERTWATCH OK - ok.example.com:443: certificate for ok.example.com expires in 90 days",
+		},
+		{
+			"warning",
+			scanResult("soon.example.com", 20),
+			NagiosWarning,
+			"CERTWATCH WARNING - soon.example.com:443: certificate expires in 20 days",
+		},
+		{
+			"critical issue outranks a warning",
+			scanResult("pin.example.com", 20, scanner.ChainIssue{Type: scanner.IssuePinMismatch, Severity: scanner.SeverityHigh, Message: "pins | differ"}),
+			NagiosCritical,
+			"CERTWATCH CRITICAL - pin.example.com:443: pins / differ |",
+		},
+		{
+			"scan failure",
+			&scanner.ScanResult{Hostname: "down.example.com", Port: 443, Error: "timeout"},
+			NagiosCritical,
+			"CERTWATCH CRITICAL - down.example.com:443: scan failed: timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Evaluate(tt.result, &thresholds)
+			line, code := Nagios(&r, &thresholds)
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+			if !strings.HasPrefix(line, tt.wantLine) {
+				t.Errorf("line = %q, want prefix %q", line, tt.wantLine)
+			}
+			if strings.Count(line, "|") > 1 || strings.Contains(line, "\n") {
+				t.Errorf("line = %q, want a single line with one perfdata separator", line)
+			}
+			if tt.result.Success && !strings.Contains(line, ";31:;8: handshake_time=") {
+				t.Errorf("line = %q, want days_left perfdata with the thresholds", line)
+			}
+			if !tt.result.Success && strings.Contains(line, "|") {
+				t.Errorf("line = %q, want no perfdata for a failed scan", line)
+			}
+		})
+	}
+
+	if line, code := NagiosUsage(errors.New("target is required")); code != NagiosUnknown || line != "CERTWATCH UNKNOWN - target is required" {
+		t.Errorf("NagiosUsage() = %q, %d", line, code)
+	}
+}
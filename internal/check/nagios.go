@@ -0,0 +1,76 @@
+package check
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Nagios plugin exit codes
+const (
+	NagiosOK       = 0
+	NagiosWarning  = 1
+	NagiosCritical = 2
+	NagiosUnknown  = 3
+)
+
+// nagiosName prefixes the status line, naming the check
+const nagiosName = "CERTWATCH"
+
+// Nagios returns the output and exit code of a result following the Nagios
+// plugin conventions, as used by Icinga and compatible systems: a status
+// line with the most severe findings, and performance data for the days
+// left until expiry and the handshake time.
+func Nagios(r *Result, t *Thresholds) (string, int) {
+	code := NagiosOK
+	state := "OK"
+	switch r.Status {
+	case StatusWarning:
+		code, state = NagiosWarning, "WARNING"
+	case StatusCritical:
+		code, state = NagiosCritical, "CRITICAL"
+	}
+
+	// A "|" would start the performance data
+	summary := strings.ReplaceAll(nagiosSummary(r), "|", "/")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s - %s: %s", nagiosName, state, r.Target, summary)
+
+	if r.Scan.Success {
+		// A range "N:" alerts below N, while thresholds include their day
+		b.WriteString(" |")
+		if cert := r.Scan.Certificate; cert != nil {
+			fmt.Fprintf(&b, " days_left=%d;%d:;%d:", cert.DaysUntilExpiry, t.WarningDays+1, t.CriticalDays+1)
+		}
+		fmt.Fprintf(&b, " handshake_time=%.6fs;;;0", r.Scan.Timings.TLSHandshake.Seconds())
+	}
+	return b.String(), code
+}
+
+// NagiosUsage returns the output of a check that couldn't run, e.g. due to
+// invalid arguments
+func NagiosUsage(err error) (string, int) {
+	return fmt.Sprintf("%s UNKNOWN - %v", nagiosName, err), NagiosUnknown
+}
+
+// nagiosSummary lists the findings of the result's status, most severe
+// first, or describes the certificate if there are none
+func nagiosSummary(r *Result) string {
+	var messages []string
+	for _, f := range r.Findings {
+		if f.Status == r.Status {
+			messages = append(messages, f.Message)
+		}
+	}
+	if len(messages) > 0 {
+		return strings.Join(messages, "; ")
+	}
+
+	cert := r.Scan.Certificate
+	if cert == nil {
+		return "no certificate"
+	}
+	return fmt.Sprintf("certificate for %s expires in %d days (%s), issued by %s",
+		cert.Subject, cert.DaysUntilExpiry, cert.NotAfter.Format(time.DateOnly), cert.Issuer)
+}
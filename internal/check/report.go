@@ -0,0 +1,310 @@
+package check
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/version"
+)
+
+// Report formats written by Write
+const (
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+	FormatSARIF = "sarif"
+)
+
+// Write writes the results in one of the report formats
+func Write(w io.Writer, format string, results []Result) error {
+	switch format {
+	case FormatJSON:
+		return WriteJSON(w, results)
+	case FormatJUnit:
+		return WriteJUnit(w, results)
+	case FormatSARIF:
+		return WriteSARIF(w, results)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// jsonReport is the JSON report of a scan
+type jsonReport struct {
+	Status  string       `json:"status"`
+	Summary Summary      `json:"summary"`
+	Results []jsonResult `json:"results"`
+}
+
+// jsonResult is a result in the JSON report
+type jsonResult struct {
+	NotAfter          *time.Time           `json:"not_after,omitempty"`
+	DaysUntilExpiry   *int                 `json:"days_until_expiry,omitempty"`
+	Findings          []Finding            `json:"findings"`
+	Issues            []scanner.ChainIssue `json:"issues,omitempty"`
+	Target            string               `json:"target"`
+	Source            string               `json:"source,omitempty"`
+	Status            string               `json:"status"`
+	Error             string               `json:"error,omitempty"`
+	Subject           string               `json:"subject,omitempty"`
+	Issuer            string               `json:"issuer,omitempty"`
+	FingerprintSHA256 string               `json:"fingerprint_sha256,omitempty"`
+	TLSVersion        string               `json:"tls_version,omitempty"`
+	ScannedAt         time.Time            `json:"scanned_at"`
+	DurationMS        int64                `json:"duration_ms"`
+	Success           bool                 `json:"success"`
+}
+
+// WriteJSON writes the results as an indented JSON document
+func WriteJSON(w io.Writer, results []Result) error {
+	summary := Summarize(results)
+	report := jsonReport{Status: summary.Status(), Summary: summary, Results: make([]jsonResult, 0, len(results))}
+	for i := range results {
+		r := &results[i]
+		jr := jsonResult{
+			Findings:   r.Findings,
+			Target:     r.Target,
+			Source:     r.Source,
+			Status:     r.Status,
+			Error:      r.Scan.Error,
+			TLSVersion: r.Scan.TLSVersion,
+			ScannedAt:  r.Scan.ScannedAt,
+			DurationMS: r.Scan.Timings.Total.Milliseconds(),
+			Success:    r.Scan.Success,
+		}
+		if jr.Findings == nil {
+			jr.Findings = []Finding{}
+		}
+		if cert := r.Scan.Certificate; cert != nil {
+			jr.NotAfter = &cert.NotAfter
+			jr.DaysUntilExpiry = &cert.DaysUntilExpiry
+			jr.Subject = cert.Subject
+			jr.Issuer = cert.Issuer
+			jr.FingerprintSHA256 = cert.FingerprintSHA256
+		}
+		if r.Scan.Chain != nil {
+			jr.Issues = r.Scan.Chain.Issues
+		}
+		report.Results = append(report.Results, jr)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// JUnit XML elements, as understood by Jenkins, GitLab and GitHub Actions
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     float64      `xml:"time,attr"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      float64     `xml:"time,attr"`
+}
+
+type junitCase struct {
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	Time      float64       `xml:"time,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as a JUnit XML report with a test case per
+// target. Targets with a critical status fail; warnings are reported in
+// the output of their test case.
+func WriteJUnit(w io.Writer, results []Result) error {
+	suite := junitSuite{Name: "certificates", Tests: len(results), Cases: make([]junitCase, 0, len(results))}
+	for i := range results {
+		r := &results[i]
+		tc := junitCase{
+			Name:      r.Target,
+			ClassName: "certwatch",
+			Time:      r.Scan.Timings.Total.Seconds(),
+		}
+		var critical, warnings []string
+		for _, f := range r.Findings {
+			if f.Status == StatusCritical {
+				critical = append(critical, f.Message)
+			} else {
+				warnings = append(warnings, "warning: "+f.Message)
+			}
+		}
+		if len(critical) > 0 {
+			tc.Failure = &junitFailure{
+				Message: strings.Join(critical, "; "),
+				Type:    StatusCritical,
+				Text:    strings.Join(critical, "\n"),
+			}
+			suite.Failures++
+		}
+		tc.SystemOut = strings.Join(warnings, "\n")
+		suite.Time += tc.Time
+		suite.Cases = append(suite.Cases, tc)
+
+		if suite.Timestamp == "" && !r.Scan.ScannedAt.IsZero() {
+			suite.Timestamp = r.Scan.ScannedAt.UTC().Format("2006-01-02T15:04:05")
+		}
+	}
+
+	suites := junitSuites{
+		Name:     "cw-agent scan",
+		Suites:   []junitSuite{suite},
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// SARIF 2.1.0 elements, as understood by GitHub code scanning
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF writes the findings as a SARIF log. Critical findings are
+// errors and the others warnings; targets without findings aren't listed.
+func WriteSARIF(w io.Writer, results []Result) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "cw-agent",
+			Version:        version.GetVersion(),
+			InformationURI: "https://certwatch.app/docs/agent",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := make(map[string]bool)
+	for i := range results {
+		r := &results[i]
+		location := sarifLocation{
+			LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: r.Target, Kind: "resource"}},
+		}
+		if r.Source != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: r.Source},
+			}
+		}
+
+		for _, f := range r.Findings {
+			if !rules[f.Rule] {
+				rules[f.Rule] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:               f.Rule,
+					ShortDescription: sarifMessage{Text: ruleDescription(f.Rule)},
+				})
+			}
+			level := "warning"
+			if f.Status == StatusCritical {
+				level = "error"
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    f.Rule,
+				Level:     level,
+				Message:   sarifMessage{Text: r.Target + ": " + f.Message},
+				Locations: []sarifLocation{location},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+func ruleDescription(rule string) string {
+	switch rule {
+	case RuleScanFailure:
+		return "The TLS endpoint could not be scanned"
+	case RuleExpiry:
+		return "The certificate expires soon or has expired"
+	default:
+		return "Certificate chain issue: " + strings.TrimPrefix(rule, RuleChainPrefix)
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/check"
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+var (
+	checkTimeout      time.Duration
+	checkSeverity     string
+	checkWarningDays  int
+	checkCriticalDays int
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check <host[:port]>",
+	Short: "Check a target as a Nagios/Icinga plugin",
+	Long: `Scan a target once and report the result following the Nagios plugin
+conventions, for Nagios, Icinga and compatible monitoring systems.
+
+The exit status is 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN), and a
+single status line is printed with performance data for the days left
+until expiry (days_left) and the TLS handshake time (handshake_time).
+
+A target is critical if its scan fails, its certificate expires within
+--critical-days or its chain has an issue of --severity or higher. Other
+chain issues and certificates expiring within --warning-days are warnings.
+
+The port defaults to 443. No config file is needed.
+
+Example:
+  cw-agent check api.example.com
+  cw-agent check mail.example.com:993 --warning-days 21 --critical-days 7`,
+	RunE: runCheck,
+	// The status line is the only output
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().DurationVar(&checkTimeout, "timeout", 10*time.Second, "Connection timeout")
+	checkCmd.Flags().StringVar(&checkSeverity, "severity", scanner.SeverityHigh,
+		"Lowest chain issue severity that is critical: high, medium, low or none")
+	checkCmd.Flags().IntVar(&checkWarningDays, "warning-days", 30,
+		"Warn about certificates expiring within this many days")
+	checkCmd.Flags().IntVar(&checkCriticalDays, "critical-days", 7,
+		"Critical for certificates expiring within this many days")
+
+	// Invalid flags are UNKNOWN, like other plugin errors
+	checkCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return checkUnknown(err)
+	})
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return checkUnknown(fmt.Errorf("exactly one target is required, got %d", len(args)))
+	}
+	target, err := config.ParseTarget(args[0])
+	if err != nil {
+		return checkUnknown(err)
+	}
+
+	severity := checkSeverity
+	if severity == "none" {
+		severity = ""
+	}
+	if !check.ValidSeverity(severity) {
+		return checkUnknown(fmt.Errorf("unknown severity %q (supported: high, medium, low, none)", checkSeverity))
+	}
+	thresholds := &check.Thresholds{
+		CriticalSeverity: severity,
+		WarningDays:      checkWarningDays,
+		CriticalDays:     checkCriticalDays,
+	}
+
+	s := scanner.New(checkTimeout, 1, zap.NewNop())
+	scan := s.Scan(context.Background(), target.Hostname, target.Port)
+	result := check.Evaluate(&scan, thresholds)
+
+	line, code := check.Nagios(&result, thresholds)
+	fmt.Println(line)
+	if code != check.NagiosOK {
+		return &exitError{err: fmt.Errorf("%s", line), code: code}
+	}
+	return nil
+}
+
+// checkUnknown prints the UNKNOWN status line for a check that couldn't run
+func checkUnknown(err error) error {
+	line, code := check.NagiosUsage(err)
+	fmt.Println(line)
+	return &exitError{err: err, code: code}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	cmconfig "github.com/certwatch-app/cw-agent/internal/certmanager/config"
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Configuration file tools",
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration file",
+	Long: `Print a JSON Schema for certwatch.yaml, for validation in editors and CI.
+
+The schema includes the limits 'cw-agent validate' enforces and the default
+of every setting. Values may also be ${NAME} environment references. Minimum
+durations are published as the x-minimum-duration annotation, since JSON
+Schema can't compare duration strings.
+
+With --certmanager, the schema of the cw-agent-certmanager configuration
+is printed instead.
+
+Example:
+  cw-agent config schema > certwatch.schema.json
+  cw-agent config schema --certmanager > certmanager.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: runConfigSchema,
+}
+
+var configSchemaCertManager bool
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configSchemaCmd)
+
+	configSchemaCmd.Flags().BoolVar(&configSchemaCertManager, "certmanager", false,
+		"Print the schema of the cert-manager agent configuration")
+}
+
+func runConfigSchema(cmd *cobra.Command, args []string) error {
+	schema := config.Schema()
+	if configSchemaCertManager {
+		schema = cmconfig.Schema()
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(schema)
+}
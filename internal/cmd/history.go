@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/history"
+	"github.com/certwatch-app/cw-agent/internal/ui"
+)
+
+var (
+	historyLimit  int
+	historyFormat string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <host[:port]>",
+	Short: "Show the scan history of a target",
+	Long: `Show the recorded scan results of a target, oldest first: the
+certificate fingerprints it served, scan errors and chain issues.
+
+Consecutive scans with the same result are shown as one row with the time
+of the first and last of them. The history is read from the state directory
+of the agent using the same config file, so it is available while the agent
+is running and after restarts.
+
+The port defaults to 443.
+
+Example:
+  cw-agent history api.example.com
+  cw-agent history mail.example.com:993 --limit 10
+  cw-agent history api.example.com --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 50,
+		"Show at most this many of the latest rows (0 for all)")
+	historyCmd.Flags().StringVar(&historyFormat, "format", "text",
+		"Output format: text or json")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	if historyFormat != "text" && historyFormat != "json" {
+		return fmt.Errorf("unknown format %q (supported: text, json)", historyFormat)
+	}
+
+	target, err := config.ParseTarget(args[0])
+	if err != nil {
+		return err
+	}
+
+	dir := newStateManager().Dir()
+	entries, err := history.Read(dir, target.GetHostPort())
+	if err != nil {
+		return err
+	}
+	if historyLimit > 0 && len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+
+	if historyFormat == "json" {
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	fmt.Println()
+	fmt.Println(ui.RenderCommandHeader("Scan History"))
+	fmt.Println()
+	fmt.Println(ui.RenderKeyValue("Target", target.GetHostPort()))
+	fmt.Println(ui.RenderKeyValue("History", filepath.Join(dir, history.FileName)))
+	fmt.Println()
+
+	if len(entries) == 0 {
+		fmt.Println(ui.RenderWarning("No scans recorded for this target"))
+		fmt.Println()
+		return fmt.Errorf("no scan history for %s", target.GetHostPort())
+	}
+
+	fmt.Println(renderHistory(entries))
+	fmt.Println()
+	return nil
+}
+
+// renderHistory renders history entries as a table
+func renderHistory(entries []history.Entry) string {
+	rows := make([][]string, 0, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		result := ui.SuccessStyle.Render("OK")
+		details := formatIssues(e)
+		if !e.Success {
+			result = ui.ErrorStyle.Render("FAILED")
+			details = e.Error
+		}
+
+		fingerprint := e.Fingerprint
+		if len(fingerprint) > 16 {
+			fingerprint = fingerprint[:16] + "..."
+		}
+		if e.NotAfter != nil {
+			fingerprint += " (expires " + e.NotAfter.Format("2006-01-02") + ")"
+		}
+		if fingerprint == "" {
+			fingerprint = "-"
+		}
+
+		rows = append(rows, []string{
+			e.ScannedAt.Local().Format(time.DateTime),
+			e.LastScannedAt.Local().Format(time.DateTime),
+			fmt.Sprintf("%d", e.Scans),
+			result,
+			fingerprint,
+			details,
+		})
+	}
+	return ui.RenderTable([]string{"First Scan", "Last Scan", "Scans", "Result", "Certificate", "Details"}, rows)
+}
+
+// formatIssues lists the chain issues of an entry, or "-" without any
+func formatIssues(e *history.Entry) string {
+	if len(e.Issues) == 0 {
+		return "-"
+	}
+	issues := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		issues = append(issues, fmt.Sprintf("%s (%s)", issue.Type, issue.Severity))
+	}
+	return strings.Join(issues, ", ")
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/charmbracelet/huh"
 
+	"github.com/certwatch-app/cw-agent/internal/config"
 	"github.com/certwatch-app/cw-agent/internal/ui"
 )
 
@@ -189,7 +190,9 @@ func (w *Wizard) handleError(err error) error {
 func (w *Wizard) handleValidationError(err error) error {
 	fmt.Println()
 	fmt.Println(ui.RenderError("Configuration validation failed:"))
-	fmt.Println(ui.RenderError("  " + err.Error()))
+	for _, v := range config.Violations(err) {
+		fmt.Println(ui.RenderError("  " + v.Error()))
+	}
 	fmt.Println()
 	fmt.Println(ui.RenderInfo("Please run 'cw-agent init' again with corrected values."))
 	return err
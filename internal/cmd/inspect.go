@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // SHA-1 fingerprints are shown for comparison with other tools
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/ui"
+)
+
+var (
+	inspectTimeout time.Duration
+	inspectPEM     bool
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <host[:port]>",
+	Short: "Show the full certificate chain a target is serving",
+	Long: `Connect to a target and show every certificate of the chain it serves,
+leaf first: subject, issuer, validity, SANs, key, fingerprints and
+extensions, followed by the chain issues the agent would report.
+
+With --pem, the served chain is printed in PEM format instead, e.g. to pipe
+it into openssl.
+
+The port defaults to 443.
+
+Example:
+  cw-agent inspect api.example.com
+  cw-agent inspect mail.example.com:993
+  cw-agent inspect api.example.com --pem > chain.pem`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInspect,
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+
+	inspectCmd.Flags().DurationVar(&inspectTimeout, "timeout", 10*time.Second, "Connection timeout")
+	inspectCmd.Flags().BoolVar(&inspectPEM, "pem", false, "Print the served chain in PEM format")
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	target, err := config.ParseTarget(args[0])
+	if err != nil {
+		return err
+	}
+
+	s := scanner.New(inspectTimeout, 1, zap.NewNop())
+	result, certs := s.Inspect(context.Background(), target.Hostname, target.Port)
+
+	if inspectPEM {
+		if !result.Success {
+			return fmt.Errorf("scan of %s failed: %s", target.GetHostPort(), result.Error)
+		}
+		for _, cert := range certs {
+			if err := pem.Encode(os.Stdout, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println(ui.RenderCommandHeader("Certificate Chain"))
+	fmt.Println()
+
+	if !result.Success {
+		fmt.Println(ui.RenderError(result.Error))
+		fmt.Println()
+		return fmt.Errorf("scan of %s failed: %s", target.GetHostPort(), result.Error)
+	}
+
+	fmt.Println(ui.RenderKeyValue("Target", target.GetHostPort()))
+	fmt.Println(ui.RenderKeyValue("Protocol", result.TLSVersion))
+	if result.KeyExchange != "" {
+		keyExchange := result.KeyExchange
+		if result.PostQuantum {
+			keyExchange += " (post-quantum)"
+		}
+		fmt.Println(ui.RenderKeyValue("Key Exchange", keyExchange))
+	}
+	fmt.Println(ui.RenderKeyValue("Handshake", result.Timings.TLSHandshake.Round(time.Millisecond).String()))
+	fmt.Println(ui.RenderKeyValue("Chain", fmt.Sprintf("%d certificates", len(certs))))
+
+	for i, cert := range certs {
+		fmt.Println(ui.RenderSection(fmt.Sprintf("Certificate %d: %s", i, chainRole(i, cert))))
+		printCertificate(cert)
+	}
+
+	fmt.Println(ui.RenderSection("Chain Issues"))
+	if len(result.Chain.Issues) == 0 {
+		fmt.Println(ui.RenderSuccess("No issues found"))
+	}
+	for _, issue := range result.Chain.Issues {
+		msg := fmt.Sprintf("[%s] certificate %d: %s (%s)", issue.Severity, issue.CertificateIndex, issue.Message, issue.Type)
+		if issue.Severity == scanner.SeverityHigh {
+			fmt.Println(ui.RenderError(msg))
+		} else {
+			fmt.Println(ui.RenderWarning(msg))
+		}
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// chainRole describes the position of a certificate in the served chain
+func chainRole(i int, cert *x509.Certificate) string {
+	switch {
+	case i == 0:
+		return "leaf"
+	case cert.Subject.String() == cert.Issuer.String():
+		return "root"
+	default:
+		return "intermediate"
+	}
+}
+
+// printCertificate prints the details of a certificate as key-value lines
+func printCertificate(cert *x509.Certificate) {
+	fmt.Println(ui.RenderKeyValue("Subject", cert.Subject.String()))
+	fmt.Println(ui.RenderKeyValue("Issuer", cert.Issuer.String()))
+	fmt.Println(ui.RenderKeyValue("Serial", fmt.Sprintf("%X", cert.SerialNumber)))
+	fmt.Println(ui.RenderKeyValue("Not Before", cert.NotBefore.UTC().Format(time.DateTime+" MST")))
+	fmt.Println(ui.RenderKeyValue("Not After", cert.NotAfter.UTC().Format(time.DateTime+" MST")+" "+formatExpiry(cert.NotAfter)))
+
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses)+len(cert.EmailAddresses)+len(cert.URIs))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	printValues("SANs", sans)
+
+	fmt.Println(ui.RenderKeyValue("Key", scanner.KeyType(cert)))
+	fmt.Println(ui.RenderKeyValue("Signature", cert.SignatureAlgorithm.String()))
+	fmt.Println(ui.RenderKeyValue("SHA-256", scanner.Fingerprint(cert)))
+	sha1Sum := sha1.Sum(cert.Raw) //nolint:gosec // Not used for security
+	fmt.Println(ui.RenderKeyValue("SHA-1", hex.EncodeToString(sha1Sum[:])))
+	fmt.Println(ui.RenderKeyValue("SPKI SHA-256", scanner.SPKIHash(cert)))
+
+	// Extensions
+	if cert.BasicConstraintsValid {
+		ca := "CA:FALSE"
+		if cert.IsCA {
+			ca = "CA:TRUE"
+			if cert.MaxPathLen > 0 || cert.MaxPathLenZero {
+				ca += fmt.Sprintf(", pathlen:%d", cert.MaxPathLen)
+			}
+		}
+		fmt.Println(ui.RenderKeyValue("Constraints", ca))
+	}
+	printValues("Key Usage", keyUsages(cert.KeyUsage))
+	printValues("Ext Key Usage", extKeyUsages(cert))
+	if len(cert.SubjectKeyId) > 0 {
+		fmt.Println(ui.RenderKeyValue("Subject KID", hex.EncodeToString(cert.SubjectKeyId)))
+	}
+	if len(cert.AuthorityKeyId) > 0 {
+		fmt.Println(ui.RenderKeyValue("Authority KID", hex.EncodeToString(cert.AuthorityKeyId)))
+	}
+	printValues("OCSP", cert.OCSPServer)
+	printValues("CA Issuers", cert.IssuingCertificateURL)
+	printValues("CRL", cert.CRLDistributionPoints)
+	policies := make([]string, 0, len(cert.PolicyIdentifiers))
+	for _, oid := range cert.PolicyIdentifiers {
+		policies = append(policies, oid.String())
+	}
+	printValues("Policies", policies)
+
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidSCTList):
+			fmt.Println(ui.RenderKeyValue("SCTs", "embedded"))
+		case ext.Id.Equal(oidMustStaple):
+			fmt.Println(ui.RenderKeyValue("Must-Staple", "yes"))
+		}
+	}
+}
+
+// Extensions the x509 package doesn't parse
+var (
+	oidSCTList    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	oidMustStaple = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+)
+
+// printValues prints a label with the first value and the other values
+// aligned below it; nothing without values
+func printValues(label string, values []string) {
+	for i, v := range values {
+		if i > 0 {
+			label = ""
+		}
+		fmt.Println(ui.RenderKeyValue(label, v))
+	}
+}
+
+// formatExpiry describes how far away a date is, styled by urgency
+func formatExpiry(t time.Time) string {
+	days := int(time.Until(t).Hours() / 24)
+	switch {
+	case time.Now().After(t):
+		return ui.ErrorStyle.Render(fmt.Sprintf("(expired %d days ago)", -days))
+	case days <= 30:
+		return ui.WarningStyle.Render(fmt.Sprintf("(in %d days)", days))
+	default:
+		return ui.MutedStyle.Render(fmt.Sprintf("(in %d days)", days))
+	}
+}
+
+func keyUsages(usage x509.KeyUsage) []string {
+	names := []string{
+		"Digital Signature", "Content Commitment", "Key Encipherment", "Data Encipherment",
+		"Key Agreement", "Certificate Sign", "CRL Sign", "Encipher Only", "Decipher Only",
+	}
+	var usages []string
+	for i, name := range names {
+		if usage&(1<<i) != 0 {
+			usages = append(usages, name)
+		}
+	}
+	if len(usages) == 0 {
+		return nil
+	}
+	return []string{strings.Join(usages, ", ")}
+}
+
+func extKeyUsages(cert *x509.Certificate) []string {
+	names := map[x509.ExtKeyUsage]string{
+		x509.ExtKeyUsageAny:             "Any",
+		x509.ExtKeyUsageServerAuth:      "Server Auth",
+		x509.ExtKeyUsageClientAuth:      "Client Auth",
+		x509.ExtKeyUsageCodeSigning:     "Code Signing",
+		x509.ExtKeyUsageEmailProtection: "Email Protection",
+		x509.ExtKeyUsageTimeStamping:    "Time Stamping",
+		x509.ExtKeyUsageOCSPSigning:     "OCSP Signing",
+	}
+	usages := make([]string, 0, len(cert.ExtKeyUsage)+len(cert.UnknownExtKeyUsage))
+	for _, u := range cert.ExtKeyUsage {
+		if name, ok := names[u]; ok {
+			usages = append(usages, name)
+		} else {
+			usages = append(usages, fmt.Sprintf("%d", u))
+		}
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		usages = append(usages, oid.String())
+	}
+	if len(usages) == 0 {
+		return nil
+	}
+	return []string{strings.Join(usages, ", ")}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/ui"
+)
+
+var pinTimeout time.Duration
+
+var pinCmd = &cobra.Command{
+	Use:   "pin <host[:port]>",
+	Short: "Print pin values for the certificate a target is serving",
+	Long: `Connect to a target and print the values that can be used in the
+'pins' section of a certificate entry in certwatch.yaml.
+
+The port defaults to 443. All values are SHA-256 hashes in hex.
+
+Example:
+  cw-agent pin api.example.com
+  cw-agent pin mail.example.com:993`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPin,
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+
+	pinCmd.Flags().DurationVar(&pinTimeout, "timeout", 10*time.Second, "Connection timeout")
+}
+
+func runPin(cmd *cobra.Command, args []string) error {
+	target, err := config.ParseTarget(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println(ui.RenderCommandHeader("Certificate Pins"))
+	fmt.Println()
+
+	s := scanner.New(pinTimeout, 1, zap.NewNop())
+	result := s.Scan(context.Background(), target.Hostname, target.Port)
+	if !result.Success {
+		fmt.Println(ui.RenderError(result.Error))
+		return fmt.Errorf("scan of %s failed: %s", target.GetHostPort(), result.Error)
+	}
+
+	fmt.Println(ui.RenderSection("Leaf"))
+	fmt.Println()
+	fmt.Println(ui.RenderKeyValue("Subject", result.Certificate.Subject))
+	fmt.Println(ui.RenderKeyValue("Expires", result.Certificate.NotAfter.Format(time.RFC3339)))
+	fmt.Println(ui.RenderKeyValue("leaf_sha256", result.Certificate.FingerprintSHA256))
+	fmt.Println(ui.RenderKeyValue("spki_sha256", result.Certificate.SPKISHA256))
+
+	if len(result.Chain.Certificates) > 1 {
+		fmt.Println()
+		fmt.Println(ui.RenderSection("Issuing CAs"))
+		for _, ca := range result.Chain.Certificates[1:] {
+			fmt.Println()
+			fmt.Println(ui.RenderKeyValue("Subject", ca.Subject))
+			fmt.Println(ui.RenderKeyValue("ca_sha256", ca.SPKISHA256))
+		}
+	}
+
+	fmt.Println()
+	fmt.Println(ui.RenderSection("Config"))
+	fmt.Println()
+	fmt.Printf("  - hostname: %q\n", target.Hostname)
+	fmt.Printf("    port: %d\n", target.Port)
+	fmt.Println("    pins:")
+	fmt.Println("      spki_sha256:")
+	fmt.Printf("        - %q\n", result.Certificate.SPKISHA256)
+	if len(result.Chain.Certificates) > 1 {
+		fmt.Println("      ca_sha256:")
+		fmt.Printf("        - %q\n", result.Chain.Certificates[1].SPKISHA256)
+	}
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/report"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/ui"
+)
+
+var (
+	reportFrom    string
+	reportFormat  string
+	reportGroupBy string
+	reportTags    []string
+	reportOutput  string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate a certificate inventory report",
+	Long: `Generate an inventory of the configured targets for audits, as HTML,
+Markdown or CSV: owner, tags, subject, issuer, key type, expiry and chain
+issues of every target, grouped by expiry bucket, issuer or tag.
+
+The targets are scanned for the report, or with --from history the latest
+results in the scan history of the agent using the same config file are
+used. Targets with chain issues, expired certificates and failed scans are
+highlighted. No CertWatch API key or connection is needed.
+
+Example:
+  cw-agent report -c certwatch.yaml -o inventory.html
+  cw-agent report -c certwatch.yaml --from history --group-by issuer --format markdown
+  cw-agent report -c certwatch.yaml --tag prod --format csv -o prod.csv`,
+	Args: cobra.NoArgs,
+	RunE: runReport,
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().StringVar(&reportFrom, "from", report.SourceScan,
+		"Where results come from: scan or history")
+	reportCmd.Flags().StringVar(&reportFormat, "format", report.FormatHTML,
+		"Report format: html, markdown or csv")
+	reportCmd.Flags().StringVar(&reportGroupBy, "group-by", report.GroupByExpiry,
+		"Group targets by expiry, issuer or tag")
+	reportCmd.Flags().StringSliceVar(&reportTags, "tag", nil,
+		"Only report targets with any of these tags")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "",
+		"Write the report to this file instead of stdout")
+}
+
+func runReport(cmd *cobra.Command, _ []string) error {
+	if reportFrom != report.SourceScan && reportFrom != report.SourceHistory {
+		return fmt.Errorf("unknown source %q (supported: scan, history)", reportFrom)
+	}
+	if !slices.Contains(report.Formats, reportFormat) {
+		return fmt.Errorf("unknown format %q (supported: %s)", reportFormat, strings.Join(report.Formats, ", "))
+	}
+	if !slices.Contains(report.GroupBys, reportGroupBy) {
+		return fmt.Errorf("unknown grouping %q (supported: %s)", reportGroupBy, strings.Join(report.GroupBys, ", "))
+	}
+	cmd.SilenceUsage = true
+
+	cfg, err := config.Load(viper.GetViper())
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if len(cfg.Files()) == 0 {
+		return fmt.Errorf("no config file found; use --config")
+	}
+	// Reports never need the API
+	cfg.Agent.Offline = true
+	if err := cfg.Validate(); err != nil {
+		violations := config.Violations(err)
+		fmt.Fprintln(os.Stderr, ui.RenderError(fmt.Sprintf("Invalid configuration (%d errors):", len(violations))))
+		printViolationsTo(os.Stderr, violations)
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	targets := cfg.Certificates
+	if len(reportTags) > 0 {
+		targets = nil
+		for _, cert := range cfg.Certificates {
+			if slices.ContainsFunc(cert.Tags, func(tag string) bool { return slices.Contains(reportTags, tag) }) {
+				targets = append(targets, cert)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets to report")
+	}
+
+	now := time.Now()
+	var rows []report.Row
+	if reportFrom == report.SourceHistory {
+		rows, err = report.FromHistory(targets, newStateManager().Dir(), now)
+		if err != nil {
+			return err
+		}
+	} else {
+		if reportOutput != "" {
+			fmt.Println(ui.RenderInfo(fmt.Sprintf("Scanning %d targets...", len(targets))))
+		}
+		s := scanner.New(cfg.API.Timeout, cfg.Agent.Concurrency, zap.NewNop())
+		rows = report.FromScan(targets, s.ScanAll(context.Background(), targets), now)
+	}
+
+	r, err := report.New(rows, reportGroupBy, reportFrom, now)
+	if err != nil {
+		return err
+	}
+	if reportOutput == "" {
+		return r.Write(os.Stdout, reportFormat)
+	}
+	if err := writeReportFile(r); err != nil {
+		return err
+	}
+	fmt.Println(ui.RenderSuccess(fmt.Sprintf("Report of %d targets written to %s", r.Summary.Targets, reportOutput)))
+	return nil
+}
+
+// writeReportFile writes the report to --output
+func writeReportFile(r *report.Report) error {
+	f, err := os.Create(reportOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	err = r.Write(f, reportFormat)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -2,12 +2,14 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/certwatch-app/cw-agent/internal/secret"
 	"github.com/certwatch-app/cw-agent/internal/version"
 )
 
@@ -34,6 +36,30 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
+// exitError makes the process exit with a specific status, for commands
+// whose exit status has a meaning beyond success or failure
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// ExitCode returns the exit status for an error returned by Execute
+func ExitCode(err error) int {
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return 1
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -63,15 +89,24 @@ func initConfig() {
 	viper.SetEnvPrefix("CW")
 	viper.AutomaticEnv()
 
-	// Explicitly bind API key from environment variable
+	// Explicitly bind API key from environment variables
 	//nolint:errcheck // BindEnv always succeeds when args are valid
 	viper.BindEnv("api.key", "CW_API_KEY")
+	//nolint:errcheck // BindEnv always succeeds when args are valid
+	viper.BindEnv("api.key_file", "CW_API_KEY_FILE")
+	//nolint:errcheck // BindEnv always succeeds when args are valid
+	viper.BindEnv("agent.offline", "CW_OFFLINE")
+	//nolint:errcheck // BindEnv always succeeds when args are valid
+	viper.BindEnv("control_api.token", "CW_CONTROL_API_TOKEN")
 
-	// If a config file is found, read it in
-	if err := viper.ReadInConfig(); err == nil {
+	// If a config file is found, read it in, expanding ${VAR} references
+	if err := secret.ReadInConfig(viper.GetViper()); err == nil {
 		if verbose {
 			fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 		}
+	} else if _, statErr := os.Stat(viper.ConfigFileUsed()); statErr == nil {
+		// The file exists but is invalid, e.g. references an unset variable
+		fmt.Fprintln(os.Stderr, "Failed to read config file:", err)
 	}
 }
 
@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/check"
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/sync"
+	"github.com/certwatch-app/cw-agent/internal/ui"
+)
+
+var (
+	scanTags          []string
+	scanFormat        string
+	scanOutput        string
+	scanSeverity      string
+	scanWarningDays   int
+	scanCriticalDays  int
+	scanFailOnWarning bool
+	scanSync          bool
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [host[:port]...]",
+	Short: "Scan targets once and check them against thresholds",
+	Long: `Scan targets once, print the results and exit with a non-zero status
+if a target is critical, for gating deploys in CI pipelines.
+
+Without arguments, the targets in the config file are scanned, optionally
+only those with one of the --tag tags. Targets given as arguments are
+scanned with their settings from the config file if they are in it, so
+their pins are checked; no config file is needed to scan them.
+
+A target is critical if its scan fails, its certificate expires within
+--critical-days or its chain has an issue of --severity or higher. Other
+chain issues and certificates expiring within --warning-days are warnings.
+The day thresholds default to alerting.expiry_critical_days and
+alerting.expiry_warning_days.
+
+Reports are printed as a table, or with --format as JSON, JUnit XML or
+SARIF. With --output, the report is written to a file and the table is
+printed as well.
+
+With --sync, the results of all configured targets are synced to CertWatch
+as the agent in the config file.
+
+Example:
+  cw-agent scan -c certwatch.yaml
+  cw-agent scan -c certwatch.yaml --tag prod --format junit --output scan.xml
+  cw-agent scan api.example.com mail.example.com:993 --critical-days 14
+  cw-agent scan -c certwatch.yaml --format sarif --output scan.sarif --sync`,
+	RunE: runScan,
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+
+	scanCmd.Flags().StringSliceVar(&scanTags, "tag", nil,
+		"Only scan configured targets with any of these tags")
+	scanCmd.Flags().StringVar(&scanFormat, "format", "text",
+		"Output format: text, json, junit or sarif")
+	scanCmd.Flags().StringVarP(&scanOutput, "output", "o", "",
+		"Write the report to this file instead of stdout")
+	scanCmd.Flags().StringVar(&scanSeverity, "severity", scanner.SeverityHigh,
+		"Lowest chain issue severity that is critical: high, medium, low or none")
+	scanCmd.Flags().IntVar(&scanWarningDays, "warning-days", 0,
+		"Warn about certificates expiring within this many days (default alerting.expiry_warning_days)")
+	scanCmd.Flags().IntVar(&scanCriticalDays, "critical-days", 0,
+		"Fail on certificates expiring within this many days (default alerting.expiry_critical_days)")
+	scanCmd.Flags().BoolVar(&scanFailOnWarning, "fail-on-warning", false,
+		"Also exit with a non-zero status on warnings")
+	scanCmd.Flags().BoolVar(&scanSync, "sync", false,
+		"Sync the results to CertWatch")
+}
+
+func runScan(cmd *cobra.Command, args []string) error {
+	if scanFormat != "text" && scanFormat != check.FormatJSON && scanFormat != check.FormatJUnit && scanFormat != check.FormatSARIF {
+		return fmt.Errorf("unknown format %q (supported: text, json, junit, sarif)", scanFormat)
+	}
+	if scanFormat == "text" && scanOutput != "" {
+		return fmt.Errorf("--output requires --format json, junit or sarif")
+	}
+	if len(args) > 0 && len(scanTags) > 0 {
+		return fmt.Errorf("--tag can't be combined with targets")
+	}
+	if scanSync && (len(args) > 0 || len(scanTags) > 0) {
+		// A sync with a subset of the targets would orphan the others
+		return fmt.Errorf("--sync requires scanning all configured targets")
+	}
+
+	severity := scanSeverity
+	if severity == "none" {
+		severity = ""
+	}
+	if !check.ValidSeverity(severity) {
+		return fmt.Errorf("unknown severity %q (supported: high, medium, low, none)", scanSeverity)
+	}
+
+	// Failing targets aren't usage errors
+	cmd.SilenceUsage = true
+
+	cfg, err := loadScanConfig(len(args) > 0)
+	if err != nil {
+		return err
+	}
+
+	thresholds := &check.Thresholds{
+		CriticalSeverity: severity,
+		WarningDays:      cfg.Alerting.ExpiryWarningDays,
+		CriticalDays:     cfg.Alerting.ExpiryCriticalDays,
+	}
+	if cmd.Flags().Changed("warning-days") {
+		thresholds.WarningDays = scanWarningDays
+	}
+	if cmd.Flags().Changed("critical-days") {
+		thresholds.CriticalDays = scanCriticalDays
+	}
+
+	targets, err := scanTargets(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	// The report replaces the table on stdout unless written to a file
+	table := scanFormat == "text" || scanOutput != ""
+	if table {
+		fmt.Println()
+		fmt.Println(ui.RenderCommandHeader("Certificate Scan"))
+		fmt.Println()
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("Scanning %d targets...", len(targets))))
+		fmt.Println()
+	}
+
+	ctx := context.Background()
+	s := scanner.New(cfg.API.Timeout, cfg.Agent.Concurrency, zap.NewNop())
+	scans := s.ScanAll(ctx, targets)
+
+	// Results arrive in completion order; report them in target order
+	order := make(map[string]int, len(targets))
+	for i := range targets {
+		order[fmt.Sprintf("%s:%d", targets[i].Hostname, targets[i].Port)] = i
+	}
+	slices.SortFunc(scans, func(a, b scanner.ScanResult) int {
+		return order[fmt.Sprintf("%s:%d", a.Hostname, a.Port)] - order[fmt.Sprintf("%s:%d", b.Hostname, b.Port)]
+	})
+
+	results := make([]check.Result, 0, len(scans))
+	for i := range scans {
+		result := check.Evaluate(&scans[i], thresholds)
+		result.Source = targets[order[result.Target]].Source
+		results = append(results, result)
+	}
+	summary := check.Summarize(results)
+
+	if table {
+		fmt.Println(renderScanResults(results))
+		fmt.Println()
+		fmt.Println(ui.RenderKeyValue("Summary", fmt.Sprintf("%d ok, %d warning, %d critical",
+			summary.OK, summary.Warning, summary.Critical)))
+	}
+	if scanFormat != "text" {
+		if err := writeScanReport(results); err != nil {
+			return err
+		}
+		if scanOutput != "" {
+			fmt.Println(ui.RenderKeyValue("Report", scanOutput))
+		}
+	}
+
+	if scanSync {
+		if err := syncScanResults(ctx, cfg, scans); err != nil {
+			if table {
+				fmt.Println(ui.RenderError(err.Error()))
+			}
+			return err
+		}
+		if table {
+			fmt.Println(ui.RenderSuccess("Results synced to CertWatch"))
+		}
+	}
+	if table {
+		fmt.Println()
+	}
+
+	failed := 0
+	for i := range results {
+		if results[i].Failed(scanFailOnWarning) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed the scan thresholds", failed, len(results))
+	}
+	return nil
+}
+
+// loadScanConfig loads the configuration. Without --sync no API settings
+// are needed, and targets given as arguments need no config file at all.
+func loadScanConfig(adHoc bool) (*config.Config, error) {
+	cfg, err := config.Load(viper.GetViper())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if !scanSync {
+		cfg.Agent.Offline = true
+	}
+	if adHoc && len(cfg.Files()) == 0 {
+		return cfg, nil
+	}
+	if len(cfg.Files()) == 0 {
+		return nil, fmt.Errorf("no config file found; pass targets as arguments or use --config")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		violations := config.Violations(err)
+		fmt.Fprintln(os.Stderr, ui.RenderError(fmt.Sprintf("Invalid configuration (%d errors):", len(violations))))
+		printViolationsTo(os.Stderr, violations)
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if scanSync && cfg.Agent.RemoteTargets {
+		return nil, fmt.Errorf("--sync can't be used with agent.remote_targets")
+	}
+	return cfg, nil
+}
+
+// scanTargets returns the configured targets with any of --tag, or the
+// targets given as arguments, with their settings from the config file if
+// they are in it
+func scanTargets(cfg *config.Config, args []string) ([]config.CertificateConfig, error) {
+	if len(args) == 0 {
+		if len(cfg.Certificates) == 0 {
+			return nil, fmt.Errorf("no targets configured")
+		}
+		if len(scanTags) == 0 {
+			return cfg.Certificates, nil
+		}
+		var targets []config.CertificateConfig
+		for _, cert := range cfg.Certificates {
+			if slices.ContainsFunc(cert.Tags, func(tag string) bool { return slices.Contains(scanTags, tag) }) {
+				targets = append(targets, cert)
+			}
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no configured targets have any of the tags %s", strings.Join(scanTags, ", "))
+		}
+		return targets, nil
+	}
+
+	configured := make(map[string]config.CertificateConfig, len(cfg.Certificates))
+	for _, cert := range cfg.Certificates {
+		configured[fmt.Sprintf("%s:%d", cert.Hostname, cert.Port)] = cert
+	}
+	targets := make([]config.CertificateConfig, 0, len(args))
+	for _, arg := range args {
+		target, err := config.ParseTarget(arg)
+		if err != nil {
+			return nil, err
+		}
+		if cert, ok := configured[fmt.Sprintf("%s:%d", target.Hostname, target.Port)]; ok {
+			target = cert
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// writeScanReport writes the report to --output, or to stdout
+func writeScanReport(results []check.Result) error {
+	if scanOutput == "" {
+		return check.Write(os.Stdout, scanFormat, results)
+	}
+
+	f, err := os.Create(scanOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %w", err)
+	}
+	err = check.Write(f, scanFormat, results)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// syncScanResults syncs the results as the agent in the config file
+func syncScanResults(ctx context.Context, cfg *config.Config, scans []scanner.ScanResult) error {
+	stateManager := newStateManager()
+	if err := stateManager.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, ui.RenderWarning(err.Error()))
+	}
+	if stateManager.HasNameChanged(cfg.Agent.Name) {
+		return fmt.Errorf("agent name changed from %q; run 'cw-agent start --reset-agent' first",
+			stateManager.GetAgentName())
+	}
+
+	client := sync.New(cfg, zap.NewNop(), stateManager)
+	if _, err := client.Sync(ctx, cfg.Certificates, scans); err != nil {
+		return fmt.Errorf("failed to sync results: %w", err)
+	}
+	return nil
+}
+
+// renderScanResults renders evaluated scan results as a table
+func renderScanResults(results []check.Result) string {
+	rows := make([][]string, 0, len(results))
+	for i := range results {
+		r := &results[i]
+
+		status := ui.SuccessStyle.Render("OK")
+		switch r.Status {
+		case check.StatusWarning:
+			status = ui.WarningStyle.Render("WARNING")
+		case check.StatusCritical:
+			status = ui.ErrorStyle.Render("CRITICAL")
+		}
+
+		expires, days, tlsVersion := "-", "-", "-"
+		if cert := r.Scan.Certificate; cert != nil {
+			expires = cert.NotAfter.Format(time.DateOnly)
+			days = fmt.Sprintf("%d", cert.DaysUntilExpiry)
+		}
+		if r.Scan.TLSVersion != "" {
+			tlsVersion = r.Scan.TLSVersion
+		}
+
+		details := make([]string, 0, len(r.Findings))
+		for _, f := range r.Findings {
+			details = append(details, f.Message)
+		}
+		if len(details) == 0 {
+			details = append(details, "-")
+		}
+
+		rows = append(rows, []string{r.Target, status, expires, days, tlsVersion, strings.Join(details, "; ")})
+	}
+	return ui.RenderTable([]string{"Target", "Status", "Expires", "Days", "TLS", "Details"}, rows)
+}
@@ -14,13 +14,15 @@ import (
 
 	"github.com/certwatch-app/cw-agent/internal/agent"
 	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/secret"
 	"github.com/certwatch-app/cw-agent/internal/state"
 	"github.com/certwatch-app/cw-agent/internal/ui"
 )
 
 var (
-	resetAgent bool
-	yesFlag    bool
+	resetAgent    bool
+	yesFlag       bool
+	startDumpFile string
 )
 
 var startCmd = &cobra.Command{
@@ -43,6 +45,8 @@ func init() {
 		"Reset agent state and re-register (transfers certs to new agent, orphans removed certs)")
 	startCmd.Flags().BoolVarP(&yesFlag, "yes", "y", false,
 		"Skip confirmation prompts (for CI/automation)")
+	startCmd.Flags().StringVar(&startDumpFile, "dump-file", "",
+		"Write state dumps requested with SIGUSR2 to this file instead of the log")
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
@@ -55,24 +59,15 @@ func runStart(cmd *cobra.Command, args []string) error {
 	}
 
 	if validationErr := cfg.Validate(); validationErr != nil {
+		violations := config.Violations(validationErr)
 		fmt.Println()
-		fmt.Println(ui.RenderError("Invalid configuration: " + validationErr.Error()))
+		fmt.Println(ui.RenderError(fmt.Sprintf("Invalid configuration (%d errors):", len(violations))))
+		printViolationsTo(os.Stdout, violations)
 		return fmt.Errorf("invalid configuration: %w", validationErr)
 	}
 
 	// Initialize state manager
-	// Use dedicated state directory if it exists (for containers with read-only config mounts)
-	configPath := viper.ConfigFileUsed()
-	if configPath == "" {
-		configPath = "./certwatch.yaml" // fallback
-	}
-
-	var stateManager *state.Manager
-	if _, statErr := os.Stat(state.DefaultStateDir); statErr == nil {
-		stateManager = state.NewManagerWithStateDir(state.DefaultStateDir)
-	} else {
-		stateManager = state.NewManager(configPath)
-	}
+	stateManager := newStateManager()
 
 	if loadErr := stateManager.Load(); loadErr != nil {
 		// Log warning but continue - corrupted state is treated as first run
@@ -80,7 +75,8 @@ func runStart(cmd *cobra.Command, args []string) error {
 	}
 
 	// Check for name change (only if we have existing state and not using --reset-agent)
-	if !resetAgent && stateManager.HasNameChanged(cfg.Agent.Name) {
+	// Name changes only matter to the API
+	if !resetAgent && !cfg.Agent.Offline && stateManager.HasNameChanged(cfg.Agent.Name) {
 		return handleNameChangeWarning(stateManager, cfg)
 	}
 
@@ -112,6 +108,67 @@ func runStart(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	// Reload configuration on SIGHUP and when a config file changes
+	var watcher *config.Watcher
+	a.SetConfigLoader(func() (*config.Config, error) {
+		newCfg, loadErr := reloadConfig()
+		if loadErr == nil && watcher != nil {
+			// The include list may have changed
+			if watchErr := watcher.SetPaths(newCfg.WatchPaths()); watchErr != nil {
+				fmt.Println(ui.RenderWarning("Config file watcher error: " + watchErr.Error()))
+			}
+		}
+		return newCfg, loadErr
+	})
+
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupChan:
+				a.Reload("SIGHUP")
+			}
+		}
+	}()
+
+	// Scan immediately on SIGUSR1 and dump the agent's state on SIGUSR2
+	a.SetDumpFile(startDumpFile)
+	usrChan := make(chan os.Signal, 1)
+	signal.Notify(usrChan, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(usrChan)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-usrChan:
+				if sig == syscall.SIGUSR1 {
+					a.TriggerScan("SIGUSR1")
+				} else {
+					a.DumpState("SIGUSR2")
+				}
+			}
+		}
+	}()
+
+	if len(cfg.Files()) > 0 {
+		var watchErr error
+		watcher, watchErr = config.NewWatcher(cfg.WatchPaths(), config.DefaultWatchDebounce)
+		if watchErr != nil {
+			fmt.Println(ui.RenderWarning("Config file watching disabled: " + watchErr.Error()))
+		} else {
+			go watcher.Run(ctx, func() { a.Reload("file change") }, func(err error) {
+				fmt.Println(ui.RenderWarning("Config file watcher error: " + err.Error()))
+			})
+		}
+	}
+
 	// Display styled startup info
 	fmt.Println()
 	fmt.Println(ui.RenderAppHeader())
@@ -125,9 +182,18 @@ func runStart(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Println(ui.RenderKeyValue("Certificates", fmt.Sprintf("%d", len(cfg.Certificates))))
-	fmt.Println(ui.RenderKeyValue("Sync", cfg.Agent.SyncInterval.String()))
+	if cfg.Agent.RemoteTargets {
+		fmt.Println(ui.RenderKeyValue("Remote Targets", "enabled"))
+	}
+	if cfg.ControlAPI.Enabled() {
+		fmt.Println(ui.RenderKeyValue("Control API", cfg.ControlAPI.Listen))
+	}
+	printMode(cfg)
 	fmt.Println()
 
+	if cfg.Agent.Offline {
+		fmt.Println(ui.RenderWarning("Offline mode: results are not synced to CertWatch"))
+	}
 	fmt.Println(ui.RenderSuccess("Agent started"))
 	fmt.Println()
 
@@ -142,6 +208,49 @@ func runStart(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// newStateManager returns the state manager for the config file in use.
+// The dedicated state directory is used if it exists (for containers with
+// read-only config mounts), otherwise the directory of the config file.
+func newStateManager() *state.Manager {
+	if _, err := os.Stat(state.DefaultStateDir); err == nil {
+		return state.NewManagerWithStateDir(state.DefaultStateDir)
+	}
+
+	configPath := viper.ConfigFileUsed()
+	if configPath == "" {
+		configPath = "./certwatch.yaml" // fallback
+	}
+	return state.NewManager(configPath)
+}
+
+// reloadConfig re-reads the config file and validates it. On error the
+// previously loaded configuration stays in effect.
+func reloadConfig() (*config.Config, error) {
+	if err := secret.ReadInConfig(viper.GetViper()); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg, err := config.Load(viper.GetViper())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// printMode shows whether the agent syncs with CertWatch or runs offline
+func printMode(cfg *config.Config) {
+	if cfg.Agent.Offline {
+		fmt.Println(ui.RenderKeyValue("Mode", "offline (scans, metrics and health only)"))
+		return
+	}
+	fmt.Println(ui.RenderKeyValue("Sync", cfg.Agent.SyncInterval.String()))
+}
+
 // handleNameChangeWarning displays a warning when agent name has changed and exits
 func handleNameChangeWarning(sm *state.Manager, cfg *config.Config) error {
 	fmt.Println()
@@ -1,12 +1,23 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 
 	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/preflight"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/sync"
 	"github.com/certwatch-app/cw-agent/internal/ui"
 )
 
@@ -15,16 +26,55 @@ var validateCmd = &cobra.Command{
 	Short: "Validate the configuration file",
 	Long: `Validate the CertWatch Agent configuration file without starting the agent.
 
+Every invalid setting is reported with its path and, where it is set in a
+file, the file and line. With --format json, the result is printed as JSON
+for CI annotations.
+
+With --online, the configuration is also checked against the real
+environment: every hostname is resolved, a TLS handshake is attempted with
+every target using the configured timeout, the API key is checked against
+api.endpoint and the state directory must be writable.
+
+With --expand, the fully expanded target list is printed as YAML, with
+group defaults and included files applied.
+
 Example:
-  cw-agent validate -c /path/to/certwatch.yaml`,
+  cw-agent validate -c /path/to/certwatch.yaml
+  cw-agent validate -c /path/to/certwatch.yaml --expand
+  cw-agent validate -c /path/to/certwatch.yaml --online
+  cw-agent validate -c /path/to/certwatch.yaml --format json`,
 	RunE: runValidate,
 }
 
+var (
+	validateExpand bool
+	validateOnline bool
+	validateFormat string
+)
+
 func init() {
 	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().BoolVar(&validateExpand, "expand", false,
+		"Print every target with group defaults applied")
+	validateCmd.Flags().BoolVar(&validateOnline, "online", false,
+		"Also resolve and connect to every target, and check the API key and state directory")
+	validateCmd.Flags().StringVar(&validateFormat, "format", "text",
+		"Output format: text or json")
 }
 
 func runValidate(cmd *cobra.Command, args []string) error {
+	switch validateFormat {
+	case "text":
+	case "json":
+		if validateExpand {
+			return fmt.Errorf("--expand can't be combined with --format json")
+		}
+		return runValidateJSON()
+	default:
+		return fmt.Errorf("unknown format %q (supported: text, json)", validateFormat)
+	}
+
 	fmt.Println()
 	fmt.Println(ui.RenderCommandHeader("Config Validation"))
 	fmt.Println()
@@ -32,7 +82,10 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	// Load configuration
 	cfg, err := config.Load(viper.GetViper())
 	if err != nil {
+		// Groups are expanded while loading, so this may list several errors
 		fmt.Println(ui.RenderError("Failed to load configuration"))
+		printViolationsTo(os.Stdout, config.Violations(err))
+		fmt.Println()
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
@@ -40,12 +93,18 @@ func runValidate(cmd *cobra.Command, args []string) error {
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
-		fmt.Println(ui.RenderError("Configuration validation failed"))
-		fmt.Println(ui.RenderError("  " + err.Error()))
-		return fmt.Errorf("configuration validation failed: %w", err)
+		violations := config.Violations(err)
+		fmt.Println(ui.RenderError(fmt.Sprintf("Configuration validation failed (%d errors)", len(violations))))
+		printViolationsTo(os.Stdout, violations)
+		fmt.Println()
+		return fmt.Errorf("configuration validation failed: %d errors", len(violations))
 	}
 
-	fmt.Println(ui.RenderSuccess("API settings valid"))
+	if cfg.Agent.Offline {
+		fmt.Println(ui.RenderSuccess("Offline mode, no API settings required"))
+	} else {
+		fmt.Println(ui.RenderSuccess("API settings valid"))
+	}
 	fmt.Println(ui.RenderSuccess("Agent settings valid"))
 	fmt.Println(ui.RenderSuccess(fmt.Sprintf("%d certificates configured", len(cfg.Certificates))))
 
@@ -56,17 +115,240 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	fmt.Println(ui.RenderKeyValue("Agent", cfg.Agent.Name))
 
 	// Only show API endpoint if non-default
-	if cfg.API.Endpoint != ui.DefaultAPIEndpoint {
+	if !cfg.Agent.Offline && cfg.API.Endpoint != ui.DefaultAPIEndpoint {
 		fmt.Println(ui.RenderKeyValue("API Endpoint", cfg.API.Endpoint))
 	}
 
 	fmt.Println(ui.RenderKeyValue("Certificates", fmt.Sprintf("%d", len(cfg.Certificates))))
-	fmt.Println(ui.RenderKeyValue("Sync", cfg.Agent.SyncInterval.String()))
+	if cfg.Agent.RemoteTargets {
+		fmt.Println(ui.RenderKeyValue("Remote Targets", "enabled"))
+	}
+	if cfg.ControlAPI.Enabled() {
+		fmt.Println(ui.RenderKeyValue("Control API", cfg.ControlAPI.Listen))
+	}
+	fmt.Println(ui.RenderKeyValue("Files", fmt.Sprintf("%d", len(cfg.Files()))))
+	printMode(cfg)
 	fmt.Println(ui.RenderKeyValue("Scan", cfg.Agent.ScanInterval.String()))
+
+	printTargetSources(cfg)
 	fmt.Println()
 
+	if validateExpand {
+		fmt.Println(ui.RenderSection("Expanded Targets"))
+		expanded, err := expandedTargetsYAML(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to render expanded targets: %w", err)
+		}
+		fmt.Println(expanded)
+	}
+
+	if validateOnline {
+		fmt.Println(ui.RenderSection("Online Checks"))
+		fmt.Println()
+		checking := "API, state directory"
+		if cfg.Agent.Offline {
+			checking = "state directory"
+		}
+		fmt.Println(ui.RenderInfo(fmt.Sprintf("Checking %s and %d targets...", checking, len(cfg.Certificates))))
+		fmt.Println()
+
+		checks := runOnlineChecks(cfg)
+		fmt.Println(renderChecks(checks))
+		fmt.Println()
+
+		if failed := preflight.Failed(checks); failed > 0 {
+			fmt.Println(ui.RenderError(fmt.Sprintf("%d of %d online checks failed", failed, len(checks))))
+			fmt.Println()
+			return fmt.Errorf("online validation failed: %d checks failed", failed)
+		}
+		fmt.Println(ui.RenderSuccess(fmt.Sprintf("All %d online checks passed", len(checks))))
+	}
+
 	fmt.Println(ui.RenderSuccess("Configuration is valid!"))
 	fmt.Println()
 
 	return nil
 }
+
+// runOnlineChecks checks the configuration against the real environment
+func runOnlineChecks(cfg *config.Config) []preflight.Check {
+	ctx := context.Background()
+	logger := zap.NewNop()
+
+	stateManager := newStateManager()
+	s := scanner.New(cfg.API.Timeout, cfg.Agent.Concurrency, logger)
+
+	var checks []preflight.Check
+	// Offline agents never contact the API
+	if !cfg.Agent.Offline {
+		checks = append(checks, preflight.APIKey(ctx, sync.New(cfg, logger, stateManager), cfg.API.Endpoint))
+	}
+	checks = append(checks, preflight.StateDir(stateManager))
+	return append(checks, preflight.Targets(ctx, cfg.Certificates, net.DefaultResolver,
+		s, cfg.API.Timeout, cfg.Agent.Concurrency)...)
+}
+
+// renderChecks renders online checks as a pass/fail table
+func renderChecks(checks []preflight.Check) string {
+	rows := make([][]string, 0, len(checks))
+	for _, c := range checks {
+		rows = append(rows, []string{c.Name, c.Target, ui.RenderPassFail(c.Passed), c.Detail})
+	}
+	return ui.RenderTable([]string{"Check", "Target", "Result", "Details"}, rows)
+}
+
+// validateResult is the JSON output of validate
+type validateResult struct {
+	Files   []string                `json:"files"`
+	Errors  config.ValidationErrors `json:"errors"`
+	Checks  []preflight.Check       `json:"checks,omitempty"` // With --online
+	Offline bool                    `json:"offline"`
+	Valid   bool                    `json:"valid"`
+}
+
+// runValidateJSON prints the validation result as JSON. Errors loading the
+// configuration are reported like validation errors, without a path.
+func runValidateJSON() error {
+	result := validateResult{Files: []string{}, Errors: config.ValidationErrors{}}
+
+	cfg, err := config.Load(viper.GetViper())
+	if err == nil {
+		result.Files = append(result.Files, cfg.Files()...)
+		result.Offline = cfg.Agent.Offline
+		err = cfg.Validate()
+	}
+	if err != nil {
+		result.Errors = config.Violations(err)
+	}
+	if err == nil && validateOnline {
+		result.Checks = runOnlineChecks(cfg)
+	}
+	result.Valid = len(result.Errors) == 0 && preflight.Failed(result.Checks) == 0
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(result); encErr != nil {
+		return encErr
+	}
+
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("configuration validation failed: %d errors", len(result.Errors))
+	}
+	if failed := preflight.Failed(result.Checks); failed > 0 {
+		return fmt.Errorf("online validation failed: %d checks failed", failed)
+	}
+	return nil
+}
+
+// printViolationsTo lists validation errors on w, one per line
+func printViolationsTo(w io.Writer, violations config.ValidationErrors) {
+	for _, v := range violations {
+		fmt.Fprintln(w, ui.RenderError("  "+v.Error()))
+	}
+}
+
+// printTargetSources lists the targets grouped by the file they were defined in
+func printTargetSources(cfg *config.Config) {
+	bySource := make(map[string][]string)
+	for i := range cfg.Certificates {
+		cert := &cfg.Certificates[i]
+		bySource[cert.Source] = append(bySource[cert.Source], cert.GetHostPort())
+	}
+
+	fmt.Println()
+	fmt.Println(ui.RenderSection("Targets"))
+
+	// Files are listed in load order, main config file first
+	for _, source := range cfg.Files() {
+		targets := bySource[source]
+		if len(targets) == 0 {
+			continue
+		}
+
+		fmt.Println()
+		fmt.Println("  " + ui.ValueStyle.Render(source) + ui.MutedStyle.Render(fmt.Sprintf(" (%d)", len(targets))))
+		for _, target := range targets {
+			fmt.Println("    " + ui.RenderInfo(target))
+		}
+	}
+}
+
+// expandedTarget is the YAML view of a target after expansion.
+// Fields are in config file order rather than alignment order.
+type expandedTarget struct {
+	Hostname     string        `yaml:"hostname"`
+	Port         int           `yaml:"port"`
+	Tags         []string      `yaml:"tags,omitempty"`
+	Notes        string        `yaml:"notes,omitempty"`
+	Owner        string        `yaml:"owner,omitempty"`
+	ScanInterval string        `yaml:"scan_interval,omitempty"`
+	Priority     int           `yaml:"priority,omitempty"`
+	PQOnly       bool          `yaml:"pq_only,omitempty"`
+	Pins         *expandedPins `yaml:"pins,omitempty"`
+}
+
+type expandedPins struct {
+	LeafSHA256 []string `yaml:"leaf_sha256,omitempty"`
+	SPKISHA256 []string `yaml:"spki_sha256,omitempty"`
+	CASHA256   []string `yaml:"ca_sha256,omitempty"`
+}
+
+// expandedTargetsYAML renders the certificate list as YAML, annotating each
+// target with the group and file it came from
+func expandedTargetsYAML(cfg *config.Config) (string, error) {
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	for i := range cfg.Certificates {
+		cert := &cfg.Certificates[i]
+
+		target := expandedTarget{
+			Hostname: cert.Hostname,
+			Port:     cert.Port,
+			Tags:     cert.Tags,
+			Notes:    cert.Notes,
+			Owner:    cert.Owner,
+			Priority: cert.Priority,
+			PQOnly:   cert.PQOnly,
+		}
+		if cert.ScanInterval > 0 {
+			target.ScanInterval = cert.ScanInterval.String()
+		}
+		if !cert.Pins.IsEmpty() {
+			target.Pins = &expandedPins{
+				LeafSHA256: cert.Pins.LeafSHA256,
+				SPKISHA256: cert.Pins.SPKISHA256,
+				CASHA256:   cert.Pins.CASHA256,
+			}
+		}
+
+		var node yaml.Node
+		if err := node.Encode(target); err != nil {
+			return "", err
+		}
+
+		var origin []string
+		if cert.Group != "" {
+			origin = append(origin, "group: "+cert.Group)
+		}
+		if cert.Source != "" {
+			origin = append(origin, "file: "+cert.Source)
+		}
+		node.HeadComment = strings.Join(origin, ", ")
+
+		list.Content = append(list.Content, &node)
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "certificates"},
+		list,
+	}}
+	var out strings.Builder
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/alerting/tmpl"
+)
+
+// AlertingConfig configures alerts on scan results
+// Fields are ordered for optimal memory alignment
+type AlertingConfig struct {
+	Webhooks           []WebhookConfig    `mapstructure:"webhooks"`
+	Email              EmailConfig        `mapstructure:"email"`
+	Alertmanager       AlertmanagerConfig `mapstructure:"alertmanager"`
+	PagerDuty          PagerDutyConfig    `mapstructure:"pagerduty"`
+	RepeatInterval     time.Duration      `mapstructure:"repeat_interval"`      // 0 notifies each alert once
+	ExpiryWarningDays  int                `mapstructure:"expiry_warning_days"`  // Warning alert within this many days of expiry
+	ExpiryCriticalDays int                `mapstructure:"expiry_critical_days"` // Critical alert within this many days of expiry
+	FailureThreshold   int                `mapstructure:"failure_threshold"`    // Consecutive failed scans before alerting
+	SendResolved       bool               `mapstructure:"send_resolved"`
+}
+
+// WebhookConfig is an HTTP endpoint that receives alert notifications
+// Fields are ordered for optimal memory alignment
+type WebhookConfig struct {
+	Headers  map[string]string `mapstructure:"headers"`
+	Name     string            `mapstructure:"name"`
+	URL      string            `mapstructure:"url"`
+	Template string            `mapstructure:"template"` // Go text/template for the body; JSON if empty
+	Timeout  time.Duration     `mapstructure:"timeout"`  // 0 uses DefaultNotifierTimeout
+}
+
+// EmailConfig sends alert digests over SMTP. Alerts go to the recipients of
+// every route matching a tag of their target, or to To if none matches.
+// Fields are ordered for optimal memory alignment
+type EmailConfig struct {
+	To       []string      `mapstructure:"to"`
+	Routes   []EmailRoute  `mapstructure:"routes"`
+	Host     string        `mapstructure:"host"` // Email is disabled without a host
+	Username string        `mapstructure:"username"`
+	Password string        `mapstructure:"password"`
+	From     string        `mapstructure:"from"`
+	TLS      string        `mapstructure:"tls"` // One of EmailTLSModes
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Port     int           `mapstructure:"port"`
+}
+
+// EmailRoute sends the alerts of targets with any of Tags to To
+type EmailRoute struct {
+	Tags []string `mapstructure:"tags"`
+	To   []string `mapstructure:"to"`
+}
+
+// Enabled returns true if an SMTP server is configured
+func (e *EmailConfig) Enabled() bool {
+	return e.Host != ""
+}
+
+// AlertmanagerConfig posts alerts to the v2 API of Prometheus Alertmanager.
+// Every instance of an HA cluster should be listed, as Prometheus does.
+// Fields are ordered for optimal memory alignment
+type AlertmanagerConfig struct {
+	Headers map[string]string `mapstructure:"headers"`
+	URLs    []string          `mapstructure:"urls"` // Alertmanager is disabled without URLs
+	Timeout time.Duration     `mapstructure:"timeout"`
+}
+
+// Enabled returns true if any Alertmanager is configured
+func (a *AlertmanagerConfig) Enabled() bool {
+	return len(a.URLs) > 0
+}
+
+// PagerDutyConfig sends alerts as PagerDuty Events API v2 events
+// Fields are ordered for optimal memory alignment
+type PagerDutyConfig struct {
+	RoutingKey string        `mapstructure:"routing_key"` // PagerDuty is disabled without a routing key
+	URL        string        `mapstructure:"url"`         // Events API endpoint
+	Timeout    time.Duration `mapstructure:"timeout"`
+}
+
+// Enabled returns true if a PagerDuty routing key is configured
+func (p *PagerDutyConfig) Enabled() bool {
+	return p.RoutingKey != ""
+}
+
+// SMTP connection security
+const (
+	EmailTLSStartTLS = "starttls" // Upgrade a plain connection, usually on port 587
+	EmailTLSImplicit = "tls"      // TLS from the start, usually on port 465
+	EmailTLSNone     = "none"     // Unencrypted, for local relays
+)
+
+// EmailTLSModes lists the accepted values of alerting.email.tls
+var EmailTLSModes = []string{EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone}
+
+// DefaultNotifierTimeout applies to notifiers without a timeout
+const DefaultNotifierTimeout = 10 * time.Second
+
+// MinNotifierTimeout is the shortest allowed notifier timeout
+const MinNotifierTimeout = time.Second
+
+// PagerDutyRoutingKeyLength is the length of a PagerDuty integration key
+const PagerDutyRoutingKeyLength = 32
+
+// Enabled returns true if any notifier is configured
+func (a *AlertingConfig) Enabled() bool {
+	return len(a.Webhooks) > 0 || a.Email.Enabled() || a.Alertmanager.Enabled() || a.PagerDuty.Enabled()
+}
+
+// validateAlerting checks the alerting settings. They are only checked with
+// a notifier configured, since they have no effect otherwise.
+func (c *Config) validateAlerting(l *errorList) {
+	a := &c.Alerting
+	if !a.Enabled() {
+		return
+	}
+	file := c.mainFile()
+
+	if a.ExpiryWarningDays < 0 {
+		l.add(file, "alerting.expiry_warning_days", "must not be negative")
+	}
+	if a.ExpiryCriticalDays < 0 {
+		l.add(file, "alerting.expiry_critical_days", "must not be negative")
+	}
+	if a.FailureThreshold < 1 {
+		l.add(file, "alerting.failure_threshold", "must be at least 1")
+	}
+	if a.RepeatInterval < 0 {
+		l.add(file, "alerting.repeat_interval", "must not be negative (or 0 to notify once)")
+	}
+
+	names := make(map[string]bool, len(a.Webhooks))
+	for i := range a.Webhooks {
+		w := &a.Webhooks[i]
+		path := fmt.Sprintf("alerting.webhooks[%d]", i)
+
+		if w.Name == "" {
+			l.add(file, path+".name", "is required")
+		} else if names[w.Name] {
+			l.add(file, path+".name", "duplicate webhook name '%s'", w.Name)
+		}
+		names[w.Name] = true
+
+		if w.URL == "" {
+			l.add(file, path+".url", "is required")
+		} else {
+			validateHTTPURL(l, file, path+".url", w.URL)
+		}
+
+		if w.Template != "" {
+			if _, err := tmpl.Parse(w.Name, w.Template); err != nil {
+				l.add(file, path+".template", "invalid template: %v", err)
+			}
+		}
+
+		if w.Timeout != 0 && w.Timeout < MinNotifierTimeout {
+			l.add(file, path+".timeout", "must be at least %s (or 0 for the default)", MinNotifierTimeout)
+		}
+	}
+	if a.Email.Enabled() {
+		c.validateEmail(l)
+	}
+	if a.Alertmanager.Enabled() {
+		c.validateAlertmanager(l)
+	}
+	if a.PagerDuty.Enabled() {
+		c.validatePagerDuty(l)
+	}
+}
+
+// validateEmail checks the SMTP settings
+func (c *Config) validateEmail(l *errorList) {
+	e := &c.Alerting.Email
+	file := c.mainFile()
+
+	if e.Port < 1 || e.Port > MaxPort {
+		l.add(file, "alerting.email.port", "must be between 1 and %d", MaxPort)
+	}
+	if !slices.Contains(EmailTLSModes, e.TLS) {
+		l.add(file, "alerting.email.tls", "must be one of: %s", strings.Join(EmailTLSModes, ", "))
+	}
+	if e.Timeout < MinNotifierTimeout {
+		l.add(file, "alerting.email.timeout", "must be at least %s", MinNotifierTimeout)
+	}
+	if e.Username != "" && e.TLS == EmailTLSNone {
+		l.add(file, "alerting.email.username", "authentication requires tls to be starttls or tls")
+	}
+	if e.Password != "" && e.Username == "" {
+		l.add(file, "alerting.email.password", "requires a username")
+	}
+
+	if e.From == "" {
+		l.add(file, "alerting.email.from", "is required")
+	} else if _, err := mail.ParseAddress(e.From); err != nil {
+		l.add(file, "alerting.email.from", "invalid address '%s'", e.From)
+	}
+
+	if len(e.To) == 0 && len(e.Routes) == 0 {
+		l.add(file, "alerting.email.to", "is required without routes")
+	}
+	validateAddresses(l, file, "alerting.email.to", e.To)
+	for i, r := range e.Routes {
+		path := fmt.Sprintf("alerting.email.routes[%d]", i)
+		if len(r.Tags) == 0 {
+			l.add(file, path+".tags", "is required")
+		}
+		if len(r.To) == 0 {
+			l.add(file, path+".to", "is required")
+		}
+		validateAddresses(l, file, path+".to", r.To)
+	}
+}
+
+// validateAlertmanager checks the Alertmanager settings. Alertmanager
+// resolves alerts that aren't sent again in time, so firing alerts have to
+// be repeated.
+func (c *Config) validateAlertmanager(l *errorList) {
+	am := &c.Alerting.Alertmanager
+	file := c.mainFile()
+
+	if c.Alerting.RepeatInterval == 0 {
+		l.add(file, "alerting.repeat_interval", "must be set with alertmanager, which resolves alerts that are not repeated")
+	}
+	for i, u := range am.URLs {
+		validateHTTPURL(l, file, fmt.Sprintf("alerting.alertmanager.urls[%d]", i), u)
+	}
+	if am.Timeout < MinNotifierTimeout {
+		l.add(file, "alerting.alertmanager.timeout", "must be at least %s", MinNotifierTimeout)
+	}
+}
+
+// validatePagerDuty checks the PagerDuty settings
+func (c *Config) validatePagerDuty(l *errorList) {
+	pd := &c.Alerting.PagerDuty
+	file := c.mainFile()
+
+	if len(pd.RoutingKey) != PagerDutyRoutingKeyLength {
+		l.add(file, "alerting.pagerduty.routing_key", "must be %d characters", PagerDutyRoutingKeyLength)
+	}
+	if pd.URL == "" {
+		l.add(file, "alerting.pagerduty.url", "is required")
+	} else {
+		validateHTTPURL(l, file, "alerting.pagerduty.url", pd.URL)
+	}
+	if pd.Timeout < MinNotifierTimeout {
+		l.add(file, "alerting.pagerduty.timeout", "must be at least %s", MinNotifierTimeout)
+	}
+}
+
+// validateHTTPURL checks that a URL is valid and uses http or https
+func validateHTTPURL(l *errorList, file, path, rawURL string) {
+	if u, err := url.Parse(rawURL); err != nil {
+		l.add(file, path, "invalid URL: %v", err)
+	} else if u.Scheme != "https" && u.Scheme != "http" {
+		l.add(file, path, "must use http or https scheme")
+	}
+}
+
+// validateAddresses checks a list of email addresses
+func validateAddresses(l *errorList, file, path string, addresses []string) {
+	for i, addr := range addresses {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			l.add(file, fmt.Sprintf("%s[%d]", path, i), "invalid address '%s'", addr)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidate_Alerting(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Alerting = AlertingConfig{
+		Webhooks: []WebhookConfig{
+			{Name: "slack", URL: "https://hooks.slack.com/services/x", Template: `{"text": {{ .Agent | json }}}`},
+			{Name: "slack", URL: "ftp://example.com", Template: "{{ .Agent"},
+			{URL: "https://example.com", Timeout: time.Millisecond},
+		},
+		FailureThreshold: 0,
+	}
+
+	var got []string
+	for _, e := range Violations(cfg.Validate()) {
+		got = append(got, e.Path)
+	}
+	want := []string{
+		"alerting.failure_threshold",
+		"alerting.webhooks[1].name",
+		"alerting.webhooks[1].url",
+		"alerting.webhooks[1].template",
+		"alerting.webhooks[2].name",
+		"alerting.webhooks[2].timeout",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Validate() error paths = %v, want %v", got, want)
+	}
+
+	// Without a receiver, the settings have no effect
+	cfg.Alerting.Webhooks = nil
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v without receivers", err)
+	}
+}
+
+func TestValidate_Email(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Alerting = AlertingConfig{
+		Email: EmailConfig{
+			Routes: []EmailRoute{
+				{Tags: []string{"payments"}, To: []string{"payments@example.com"}},
+				{To: []string{"not an address"}},
+			},
+			Host:     "smtp.example.com",
+			Username: "certwatch",
+			From:     "CertWatch <certwatch@example.com>",
+			TLS:      EmailTLSNone,
+			Timeout:  30 * time.Second,
+			Port:     587,
+		},
+		FailureThreshold: 1,
+	}
+
+	var got []string
+	for _, e := range Violations(cfg.Validate()) {
+		got = append(got, e.Path)
+	}
+	want := []string{
+		"alerting.email.username",
+		"alerting.email.routes[1].tags",
+		"alerting.email.routes[1].to[0]",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Validate() error paths = %v, want %v", got, want)
+	}
+
+	cfg.Alerting.Email.TLS = EmailTLSStartTLS
+	cfg.Alerting.Email.Routes = nil
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() succeeded without recipients")
+	}
+	cfg.Alerting.Email.To = []string{"ops@example.com"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestValidate_AlertmanagerPagerDuty(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Alerting = AlertingConfig{
+		Alertmanager: AlertmanagerConfig{
+			URLs:    []string{"http://alertmanager-0:9093", "alertmanager-1:9093"},
+			Timeout: 10 * time.Second,
+		},
+		PagerDuty: PagerDutyConfig{
+			RoutingKey: "short",
+			URL:        "https://events.pagerduty.com/v2/enqueue",
+			Timeout:    10 * time.Second,
+		},
+		FailureThreshold: 1,
+	}
+
+	var got []string
+	for _, e := range Violations(cfg.Validate()) {
+		got = append(got, e.Path)
+	}
+	want := []string{
+		"alerting.repeat_interval",
+		"alerting.alertmanager.urls[1]",
+		"alerting.pagerduty.routing_key",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Validate() error paths = %v, want %v", got, want)
+	}
+
+	cfg.Alerting.RepeatInterval = 4 * time.Hour
+	cfg.Alerting.Alertmanager.URLs = cfg.Alerting.Alertmanager.URLs[:1]
+	cfg.Alerting.PagerDuty.RoutingKey = "0123456789abcdef0123456789abcdef"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
@@ -3,48 +3,134 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"net/url"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/certwatch-app/cw-agent/internal/secret"
 )
 
 // Config represents the complete agent configuration
 type Config struct {
 	API          APIConfig           `mapstructure:"api"`
 	Agent        AgentConfig         `mapstructure:"agent"`
+	Alerting     AlertingConfig      `mapstructure:"alerting"`
+	History      HistoryConfig       `mapstructure:"history"`
+	ControlAPI   ControlAPIConfig    `mapstructure:"control_api"`
 	Certificates []CertificateConfig `mapstructure:"certificates"`
+	Groups       []GroupConfig       `mapstructure:"groups"`
+	// Include lists files or glob patterns with additional certificates,
+	// relative to the main config file
+	Include []string `mapstructure:"include"`
+
+	files         []string              // Files loaded, main config file first
+	watchPatterns []string              // Include and conf.d patterns
+	nodes         map[string]*yaml.Node // Parsed files, for locating settings in errors
 }
 
 // APIConfig contains API connection settings
 type APIConfig struct {
 	Endpoint string        `mapstructure:"endpoint"`
 	Key      string        `mapstructure:"key"`
+	KeyFile  string        `mapstructure:"key_file"` // Takes precedence over key; re-read when it changes
 	Timeout  time.Duration `mapstructure:"timeout"`
+	// OutboxSizeMB bounds the payloads queued on disk while the API can't be
+	// reached; 0 disables the outbox
+	OutboxSizeMB int `mapstructure:"outbox_size_mb"`
 }
 
 // AgentConfig contains agent behavior settings
 // Fields are ordered for optimal memory alignment
 type AgentConfig struct {
-	Name              string        `mapstructure:"name"`
-	LogLevel          string        `mapstructure:"log_level"`
-	SyncInterval      time.Duration `mapstructure:"sync_interval"`
-	ScanInterval      time.Duration `mapstructure:"scan_interval"`
-	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
-	Concurrency       int           `mapstructure:"concurrency"`
-	MetricsPort       int           `mapstructure:"metrics_port"`
+	TagScanIntervals  map[string]time.Duration `mapstructure:"tag_scan_intervals"`
+	Name              string                   `mapstructure:"name"`
+	LogLevel          string                   `mapstructure:"log_level"`
+	SyncInterval      time.Duration            `mapstructure:"sync_interval"`
+	ScanInterval      time.Duration            `mapstructure:"scan_interval"`
+	ScanJitter        time.Duration            `mapstructure:"scan_jitter"`
+	HeartbeatInterval time.Duration            `mapstructure:"heartbeat_interval"`
+	Concurrency       int                      `mapstructure:"concurrency"`
+	MetricsPort       int                      `mapstructure:"metrics_port"`
+	AdaptiveScan      bool                     `mapstructure:"adaptive_scan"`  // Scan more often near expiry and after failures
+	RemoteTargets     bool                     `mapstructure:"remote_targets"` // Also monitor targets managed in CertWatch
+	Offline           bool                     `mapstructure:"offline"`        // Run without the CertWatch API, as a local exporter
 }
 
 // CertificateConfig represents a certificate to monitor
 // Fields are ordered for optimal memory alignment
 type CertificateConfig struct {
-	Hostname string   `mapstructure:"hostname"`
-	Notes    string   `mapstructure:"notes"`
-	Tags     []string `mapstructure:"tags"`
-	Port     int      `mapstructure:"port"`
+	Hostname     string        `mapstructure:"hostname"`
+	Source       string        `mapstructure:"-"` // File the certificate was defined in
+	Group        string        `mapstructure:"-"` // Group the certificate was expanded from
+	Notes        string        `mapstructure:"notes"`
+	Owner        string        `mapstructure:"owner"` // Team or person responsible, shown in reports
+	Tags         []string      `mapstructure:"tags"`
+	Pins         PinConfig     `mapstructure:"pins"`
+	ScanInterval time.Duration `mapstructure:"scan_interval"` // Overrides agent and tag scan intervals
+	Port         int           `mapstructure:"port"`
+	Priority     int           `mapstructure:"priority"` // Higher priorities are scanned first
+	PQOnly       bool          `mapstructure:"pq_only"`  // Offer only post-quantum key exchange groups
+	Remote       bool          `mapstructure:"-"`        // Fetched from the API instead of a config file
+
+	entry string // Path of the entry in Source if expanded from a group, e.g. groups[0].hostnames[1]
+}
+
+// GroupConfig defines shared defaults for a set of targets. Targets inherit
+// every field they don't set themselves; tags are combined.
+// Fields are ordered for optimal memory alignment
+type GroupConfig struct {
+	Name         string              `mapstructure:"name"`
+	Source       string              `mapstructure:"-"`            // File the group was defined in
+	Hostnames    []string            `mapstructure:"hostnames"`    // "host" or "host:port"
+	Certificates []CertificateConfig `mapstructure:"certificates"` // Targets that override single fields
+	Defaults     CertificateConfig   `mapstructure:"defaults"`
+}
+
+// PinConfig holds the expected identity of the certificate served by a target.
+// All values are SHA-256 hashes in hex; colons and case are ignored.
+// Each list matches if any of its entries match.
+type PinConfig struct {
+	// LeafSHA256 pins the fingerprint of the leaf certificate
+	LeafSHA256 []string `mapstructure:"leaf_sha256"`
+	// SPKISHA256 pins the leaf's public key, surviving renewals with the same key
+	SPKISHA256 []string `mapstructure:"spki_sha256"`
+	// CASHA256 pins the SPKI of an issuing CA anywhere in the served chain
+	CASHA256 []string `mapstructure:"ca_sha256"`
+}
+
+// IsEmpty returns true if no pins are configured
+func (p *PinConfig) IsEmpty() bool {
+	return len(p.LeafSHA256) == 0 && len(p.SPKISHA256) == 0 && len(p.CASHA256) == 0
 }
 
+// MinScanInterval is the shortest allowed interval between scans of a target
+const MinScanInterval = 10 * time.Second
+
+// MaxConcurrency is the largest allowed number of concurrent scans
+const MaxConcurrency = 500
+
+// Limits enforced by Validate and published in the JSON Schema
+const (
+	MinAPITimeout        = time.Second
+	MinSyncInterval      = 30 * time.Second
+	MinHeartbeatInterval = 10 * time.Second
+	MaxNameLength        = 100
+	MaxTagLength         = 50
+	MaxNotesLength       = 500
+	MaxOwnerLength       = 100
+	MaxPort              = 65535
+)
+
+// LogLevels lists the accepted values of agent.log_level
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
 // Load reads configuration from viper
 func Load(v *viper.Viper) (*Config, error) {
 	// Set defaults
@@ -55,159 +141,350 @@ func Load(v *viper.Viper) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
-	// Apply defaults for certificate ports
-	for i := range cfg.Certificates {
-		if cfg.Certificates[i].Port == 0 {
-			cfg.Certificates[i].Port = 443
+	// The key file is re-read by the sync client when it changes;
+	// loading it here lets validation check the current key
+	if cfg.API.KeyFile != "" {
+		key, err := secret.ReadKeyFile(cfg.API.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("api.key_file: %w", err)
+		}
+		cfg.API.Key = key
+	}
+	if cfg.ControlAPI.TokenFile != "" {
+		token, err := secret.ReadKeyFile(cfg.ControlAPI.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("control_api.token_file: %w", err)
+		}
+		cfg.ControlAPI.Token = token
+	}
+
+	// Merge certificates from included files and the conf.d directory
+	if configFile := v.ConfigFileUsed(); configFile != "" {
+		cfg.files = []string{configFile}
+		cfg.nodes = map[string]*yaml.Node{configFile: readNode(configFile)}
+		for i := range cfg.Certificates {
+			cfg.Certificates[i].Source = configFile
+		}
+		for i := range cfg.Groups {
+			cfg.Groups[i].Source = configFile
+		}
+		if err := cfg.loadIncludes(configFile); err != nil {
+			return nil, err
 		}
 	}
 
+	if err := cfg.expandGroups(); err != nil {
+		return nil, err
+	}
+
+	cfg.setDefaultPorts()
+
 	return cfg, nil
 }
 
+// setDefaultPorts applies the default port to certificates without one
+func (c *Config) setDefaultPorts() {
+	for i := range c.Certificates {
+		if c.Certificates[i].Port == 0 {
+			c.Certificates[i].Port = 443
+		}
+	}
+}
+
 // setDefaults sets default configuration values
 func setDefaults(v *viper.Viper) {
 	// API defaults
 	v.SetDefault("api.endpoint", "https://api.certwatch.app")
 	v.SetDefault("api.timeout", "30s")
+	v.SetDefault("api.outbox_size_mb", 50)
 
 	// Agent defaults
 	v.SetDefault("agent.name", "default-agent")
 	v.SetDefault("agent.sync_interval", "5m")
 	v.SetDefault("agent.scan_interval", "1m")
+	v.SetDefault("agent.scan_jitter", "10s")
+	v.SetDefault("agent.adaptive_scan", true)
+	v.SetDefault("agent.remote_targets", false)
+	v.SetDefault("agent.offline", false)
 	v.SetDefault("agent.heartbeat_interval", "30s")
 	v.SetDefault("agent.concurrency", 10)
 	v.SetDefault("agent.log_level", "info")
 	v.SetDefault("agent.metrics_port", 8080)
+
+	// Alerting defaults
+	v.SetDefault("alerting.expiry_warning_days", 30)
+	v.SetDefault("alerting.expiry_critical_days", 7)
+	v.SetDefault("alerting.failure_threshold", 2)
+	v.SetDefault("alerting.repeat_interval", "4h")
+	v.SetDefault("alerting.send_resolved", true)
+	v.SetDefault("alerting.email.port", 587)
+	v.SetDefault("alerting.email.tls", EmailTLSStartTLS)
+	v.SetDefault("alerting.email.timeout", "30s")
+	v.SetDefault("alerting.alertmanager.timeout", "10s")
+	v.SetDefault("alerting.pagerduty.url", "https://events.pagerduty.com/v2/enqueue")
+	v.SetDefault("alerting.pagerduty.timeout", "10s")
+
+	// History defaults
+	v.SetDefault("history.retention", "720h")
+	v.SetDefault("history.max_entries", 1000)
 }
 
-// Validate validates the configuration
+// Validate validates the configuration. It reports every invalid setting
+// as a ValidationErrors with the path and, if known, file and line.
 func (c *Config) Validate() error {
-	// Validate API config
-	if err := c.validateAPI(); err != nil {
-		return fmt.Errorf("api: %w", err)
-	}
+	l := &errorList{nodes: c.nodes}
 
-	// Validate agent config
-	if err := c.validateAgent(); err != nil {
-		return fmt.Errorf("agent: %w", err)
-	}
-
-	// Validate certificates
-	if err := c.validateCertificates(); err != nil {
-		return fmt.Errorf("certificates: %w", err)
-	}
+	c.validateAPI(l)
+	c.validateAgent(l)
+	c.validateGroups(l)
+	c.validateCertificates(l)
+	c.validateAlerting(l)
+	c.validateHistory(l)
+	c.validateControlAPI(l)
 
-	return nil
+	return l.err()
 }
 
-func (c *Config) validateAPI() error {
-	if c.API.Endpoint == "" {
-		return fmt.Errorf("endpoint is required")
+// mainFile returns the main config file, or "" if the config wasn't loaded
+// from a file
+func (c *Config) mainFile() string {
+	if len(c.files) == 0 {
+		return ""
 	}
+	return c.files[0]
+}
 
-	u, err := url.Parse(c.API.Endpoint)
-	if err != nil {
-		return fmt.Errorf("invalid endpoint URL: %w", err)
-	}
+func (c *Config) validateAPI(l *errorList) {
+	file := c.mainFile()
 
-	if u.Scheme != "https" && u.Scheme != "http" {
-		return fmt.Errorf("endpoint must use http or https scheme")
+	// The timeout also applies to scans
+	if c.API.Timeout < MinAPITimeout {
+		l.add(file, "api.timeout", "must be at least %s", MinAPITimeout)
 	}
 
-	if c.API.Key == "" {
-		return fmt.Errorf("key is required")
+	// No endpoint or key is needed without the API
+	if c.Agent.Offline {
+		return
 	}
 
-	if !strings.HasPrefix(c.API.Key, "cw_") {
-		return fmt.Errorf("key must start with 'cw_' prefix")
+	if c.API.Endpoint == "" {
+		l.add(file, "api.endpoint", "is required")
+	} else if u, err := url.Parse(c.API.Endpoint); err != nil {
+		l.add(file, "api.endpoint", "invalid URL: %v", err)
+	} else if u.Scheme != "https" && u.Scheme != "http" {
+		l.add(file, "api.endpoint", "must use http or https scheme")
 	}
 
-	if c.API.Timeout < time.Second {
-		return fmt.Errorf("timeout must be at least 1 second")
+	switch {
+	case c.API.Key == "":
+		l.add(file, "api.key", "key or key_file is required")
+	case !strings.HasPrefix(c.API.Key, "cw_"):
+		l.add(file, "api.key", "must start with 'cw_' prefix")
 	}
 
-	return nil
+	if c.API.OutboxSizeMB < 0 {
+		l.add(file, "api.outbox_size_mb", "must not be negative (or 0 to disable)")
+	}
 }
 
-func (c *Config) validateAgent() error {
+func (c *Config) validateAgent(l *errorList) {
+	file := c.mainFile()
+
 	if c.Agent.Name == "" {
-		return fmt.Errorf("name is required")
+		l.add(file, "agent.name", "is required")
+	} else if len(c.Agent.Name) > MaxNameLength {
+		l.add(file, "agent.name", "must be at most %d characters", MaxNameLength)
 	}
 
-	if len(c.Agent.Name) > 100 {
-		return fmt.Errorf("name must be at most 100 characters")
+	if c.Agent.SyncInterval < MinSyncInterval {
+		l.add(file, "agent.sync_interval", "must be at least %s", MinSyncInterval)
 	}
 
-	if c.Agent.SyncInterval < 30*time.Second {
-		return fmt.Errorf("sync_interval must be at least 30 seconds")
+	if c.Agent.ScanInterval < MinScanInterval {
+		l.add(file, "agent.scan_interval", "must be at least %s", MinScanInterval)
 	}
 
-	if c.Agent.ScanInterval < 10*time.Second {
-		return fmt.Errorf("scan_interval must be at least 10 seconds")
+	if c.Agent.ScanJitter < 0 {
+		l.add(file, "agent.scan_jitter", "must not be negative")
+	}
+
+	// Sorted so errors are reported in a stable order
+	tags := make([]string, 0, len(c.Agent.TagScanIntervals))
+	for tag := range c.Agent.TagScanIntervals {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if c.Agent.TagScanIntervals[tag] < MinScanInterval {
+			l.add(file, "agent.tag_scan_intervals."+tag, "must be at least %s", MinScanInterval)
+		}
 	}
 
-	if c.Agent.Concurrency < 1 || c.Agent.Concurrency > 50 {
-		return fmt.Errorf("concurrency must be between 1 and 50")
+	if c.Agent.Concurrency < 1 || c.Agent.Concurrency > MaxConcurrency {
+		l.add(file, "agent.concurrency", "must be between 1 and %d", MaxConcurrency)
 	}
 
-	validLogLevels := map[string]bool{
-		"debug": true, "info": true, "warn": true, "error": true,
+	if !slices.Contains(LogLevels, c.Agent.LogLevel) {
+		l.add(file, "agent.log_level", "must be one of: %s", strings.Join(LogLevels, ", "))
 	}
-	if !validLogLevels[c.Agent.LogLevel] {
-		return fmt.Errorf("log_level must be one of: debug, info, warn, error")
+
+	if c.Agent.Offline && c.Agent.RemoteTargets {
+		l.add(file, "agent.remote_targets", "requires the CertWatch API, which agent.offline disables")
 	}
 
-	// HeartbeatInterval of 0 means disabled, otherwise must be at least 10s
-	if c.Agent.HeartbeatInterval != 0 && c.Agent.HeartbeatInterval < 10*time.Second {
-		return fmt.Errorf("heartbeat_interval must be at least 10 seconds (or 0 to disable)")
+	// HeartbeatInterval of 0 means disabled
+	if c.Agent.HeartbeatInterval != 0 && c.Agent.HeartbeatInterval < MinHeartbeatInterval {
+		l.add(file, "agent.heartbeat_interval", "must be at least %s (or 0 to disable)", MinHeartbeatInterval)
 	}
 
 	// MetricsPort of 0 means disabled, otherwise must be valid port
-	if c.Agent.MetricsPort != 0 && (c.Agent.MetricsPort < 1 || c.Agent.MetricsPort > 65535) {
-		return fmt.Errorf("metrics_port must be between 1 and 65535 (or 0 to disable)")
+	if c.Agent.MetricsPort != 0 && (c.Agent.MetricsPort < 1 || c.Agent.MetricsPort > MaxPort) {
+		l.add(file, "agent.metrics_port", "must be between 1 and %d (or 0 to disable)", MaxPort)
 	}
-
-	return nil
 }
 
-func (c *Config) validateCertificates() error {
+func (c *Config) validateCertificates(l *errorList) {
 	if len(c.Certificates) == 0 {
-		return fmt.Errorf("at least one certificate is required")
+		// Targets managed in CertWatch are fetched at startup
+		if !c.Agent.RemoteTargets {
+			l.add(c.mainFile(), "certificates", "at least one certificate is required (or enable agent.remote_targets)")
+		}
+		return
 	}
 
-	if len(c.Certificates) > 1000 {
-		return fmt.Errorf("maximum 1000 certificates allowed")
-	}
+	seen := make(map[string]string) // Where each hostname:port was first defined
+	indexes := make(map[string]int) // Entry index within each source file
+	for i := range c.Certificates {
+		cert := &c.Certificates[i]
+		entry := cert.entry
+		if entry == "" {
+			entry = fmt.Sprintf("certificates[%d]", indexes[cert.Source])
+			indexes[cert.Source]++
+		}
+		add := func(field, format string, args ...any) {
+			path := entry + "." + field
+			// Point at the group default if the target inherited the value
+			if cert.Group != "" && !l.defined(cert.Source, path) {
+				group, _, _ := strings.Cut(entry, ".")
+				if l.defined(cert.Source, group+".defaults."+field) {
+					path = group + ".defaults." + field
+				}
+			}
+			l.add(cert.Source, path, format, args...)
+		}
 
-	seen := make(map[string]bool)
-	for i, cert := range c.Certificates {
+		key := cert.GetHostPort()
 		if cert.Hostname == "" {
-			return fmt.Errorf("[%d]: hostname is required", i)
+			add("hostname", "is required")
+		} else if first, ok := seen[key]; ok {
+			add("hostname", "duplicate hostname:port '%s' (first defined in %s)", key, first)
+		} else {
+			seen[key] = l.locate(cert.Source, entry)
+		}
+
+		if cert.Port < 1 || cert.Port > MaxPort {
+			add("port", "must be between 1 and %d", MaxPort)
 		}
 
-		if cert.Port < 1 || cert.Port > 65535 {
-			return fmt.Errorf("[%d]: port must be between 1 and 65535", i)
+		for j, tag := range cert.Tags {
+			if len(tag) > MaxTagLength {
+				add(fmt.Sprintf("tags[%d]", j), "must be at most %d characters", MaxTagLength)
+			}
 		}
 
-		key := fmt.Sprintf("%s:%d", cert.Hostname, cert.Port)
-		if seen[key] {
-			return fmt.Errorf("[%d]: duplicate hostname:port '%s'", i, key)
+		if len(cert.Notes) > MaxNotesLength {
+			add("notes", "must be at most %d characters", MaxNotesLength)
 		}
-		seen[key] = true
 
-		for j, tag := range cert.Tags {
-			if len(tag) > 50 {
-				return fmt.Errorf("[%d]: tag[%d] must be at most 50 characters", i, j)
+		if len(cert.Owner) > MaxOwnerLength {
+			add("owner", "must be at most %d characters", MaxOwnerLength)
+		}
+
+		if cert.ScanInterval != 0 && cert.ScanInterval < MinScanInterval {
+			add("scan_interval", "must be at least %s", MinScanInterval)
+		}
+
+		cert.Pins.validate(func(field, msg string) {
+			add("pins."+field, "%s", msg)
+		})
+	}
+}
+
+func (p *PinConfig) validate(add func(field, msg string)) {
+	lists := []struct {
+		name   string
+		values []string
+	}{
+		{"leaf_sha256", p.LeafSHA256},
+		{"spki_sha256", p.SPKISHA256},
+		{"ca_sha256", p.CASHA256},
+	}
+	for _, list := range lists {
+		for j, v := range list.values {
+			if len(NormalizePin(v)) != 64 {
+				add(fmt.Sprintf("%s[%d]", list.name, j), "must be a SHA-256 hash (64 hex characters)")
 			}
 		}
+	}
+}
+
+// NormalizePin converts a pin to lowercase hex without separators,
+// so values copied from openssl output (AB:CD:...) compare equal to ours.
+func NormalizePin(pin string) string {
+	pin = strings.ToLower(strings.TrimSpace(pin))
+	pin = strings.ReplaceAll(pin, ":", "")
+	for _, c := range pin {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return ""
+		}
+	}
+	return pin
+}
+
+// ParseTarget parses a "host" or "host:port" argument into a certificate config.
+// The port defaults to 443.
+func ParseTarget(target string) (CertificateConfig, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return CertificateConfig{}, fmt.Errorf("target is required")
+	}
+
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		// No port given (or a bare IPv6 address)
+		return CertificateConfig{Hostname: strings.Trim(target, "[]"), Port: 443}, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return CertificateConfig{}, fmt.Errorf("invalid port %q in target %q", portStr, target)
+	}
+	if host == "" {
+		return CertificateConfig{}, fmt.Errorf("hostname is required in target %q", target)
+	}
 
-		if len(cert.Notes) > 500 {
-			return fmt.Errorf("[%d]: notes must be at most 500 characters", i)
+	return CertificateConfig{Hostname: host, Port: port}, nil
+}
+
+// EffectiveScanInterval returns the base scan interval for a certificate:
+// its own scan_interval, else the shortest matching tag interval, else the agent default.
+func (c *Config) EffectiveScanInterval(cert *CertificateConfig) time.Duration {
+	if cert.ScanInterval > 0 {
+		return cert.ScanInterval
+	}
+
+	interval := time.Duration(0)
+	for _, tag := range cert.Tags {
+		// Viper lowercases map keys, so tags are matched case-insensitively
+		if d, ok := c.Agent.TagScanIntervals[strings.ToLower(tag)]; ok && (interval == 0 || d < interval) {
+			interval = d
 		}
 	}
+	if interval > 0 {
+		return interval
+	}
 
-	return nil
+	return c.Agent.ScanInterval
 }
 
 // GetHostPort returns the hostname:port string for a certificate config
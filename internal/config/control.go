@@ -0,0 +1,38 @@
+package config
+
+import "net"
+
+// ControlAPIConfig serves a local REST API to read scan results and trigger
+// scans and syncs, e.g. from runbooks after a certificate is deployed
+type ControlAPIConfig struct {
+	Listen    string `mapstructure:"listen"` // host:port; empty disables the API
+	Token     string `mapstructure:"token"`
+	TokenFile string `mapstructure:"token_file"` // Takes precedence over token
+}
+
+// Enabled returns true if the control API is served
+func (c *ControlAPIConfig) Enabled() bool {
+	return c.Listen != ""
+}
+
+// MinControlTokenLength is the shortest accepted control API token
+const MinControlTokenLength = 16
+
+// validateControlAPI checks the control API settings, unless it is disabled
+func (c *Config) validateControlAPI(l *errorList) {
+	api := &c.ControlAPI
+	if !api.Enabled() {
+		return
+	}
+	file := c.mainFile()
+
+	if _, port, err := net.SplitHostPort(api.Listen); err != nil || port == "" {
+		l.add(file, "control_api.listen", "must be host:port, e.g. 127.0.0.1:9403")
+	}
+	switch {
+	case api.Token == "":
+		l.add(file, "control_api.token", "token or token_file is required")
+	case len(api.Token) < MinControlTokenLength:
+		l.add(file, "control_api.token", "must be at least %d characters", MinControlTokenLength)
+	}
+}
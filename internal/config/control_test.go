@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidate_ControlAPI(t *testing.T) {
+	token := "0123456789abcdef"
+	tests := []struct {
+		name string
+		api  ControlAPIConfig
+		want []string
+	}{
+		{"disabled", ControlAPIConfig{}, nil},
+		{"enabled", ControlAPIConfig{Listen: "127.0.0.1:9403", Token: token}, nil},
+		{"all interfaces", ControlAPIConfig{Listen: ":9403", Token: token}, nil},
+		{"no port", ControlAPIConfig{Listen: "localhost", Token: token}, []string{"control_api.listen"}},
+		{"no token", ControlAPIConfig{Listen: "127.0.0.1:9403"}, []string{"control_api.token"}},
+		{"short token", ControlAPIConfig{Listen: "127.0.0.1:9403", Token: "secret"}, []string{"control_api.token"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.ControlAPI = tt.api
+
+			var got []string
+			for _, e := range Violations(cfg.Validate()) {
+				got = append(got, e.Path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Validate() error paths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_ControlAPITokenFile(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("0123456789abcdef\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	v.Set("control_api.listen", "127.0.0.1:9403")
+	v.Set("control_api.token", "ignored-because-of-the-file")
+	v.Set("control_api.token_file", tokenFile)
+	cfg, err := Load(v)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ControlAPI.Token != "0123456789abcdef" {
+		t.Errorf("Token = %q, want the token file contents", cfg.ControlAPI.Token)
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a single invalid setting
+type ValidationError struct {
+	Path    string `json:"path,omitempty"` // e.g. certificates[37].port
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"` // 0 if the setting isn't in a file
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		if e.Line > 0 {
+			b.WriteString(":" + strconv.Itoa(e.Line))
+		}
+		b.WriteString(": ")
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path + ": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// ValidationErrors holds every violation found by Validate, in the order
+// of the configuration
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	if len(msgs) == 1 {
+		return msgs[0]
+	}
+	return fmt.Sprintf("%d errors: %s", len(msgs), strings.Join(msgs, "; "))
+}
+
+// Violations returns the individual errors of a Validate error. Any other
+// error is returned as a single violation without a path.
+func Violations(err error) ValidationErrors {
+	if err == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return ValidationErrors{{Message: err.Error()}}
+}
+
+// errorList collects validation errors, locating each setting in the file
+// it was defined in
+type errorList struct {
+	nodes map[string]*yaml.Node
+	seen  map[ValidationError]bool
+	errs  ValidationErrors
+}
+
+// add records an error. A group default shared by several targets is
+// reported once.
+func (l *errorList) add(file, path, format string, args ...any) {
+	line, _ := lineOf(l.nodes[file], path)
+	e := ValidationError{Path: path, Message: fmt.Sprintf(format, args...), File: file, Line: line}
+	if l.seen[e] {
+		return
+	}
+	if l.seen == nil {
+		l.seen = make(map[ValidationError]bool)
+	}
+	l.seen[e] = true
+	l.errs = append(l.errs, &e)
+}
+
+// locate describes where a setting is defined, as file:line if known
+func (l *errorList) locate(file, path string) string {
+	if file == "" {
+		return path
+	}
+	if line, _ := lineOf(l.nodes[file], path); line > 0 {
+		return file + ":" + strconv.Itoa(line)
+	}
+	return file
+}
+
+// defined reports whether the setting at path is set in file
+func (l *errorList) defined(file, path string) bool {
+	_, found := lineOf(l.nodes[file], path)
+	return found
+}
+
+// err returns the collected errors, or nil if there are none
+func (l *errorList) err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+	return l.errs
+}
+
+// readNode parses a config file for locating settings in errors.
+// Returns nil if the file can't be parsed.
+func readNode(path string) *yaml.Node {
+	data, err := os.ReadFile(path) //nolint:gosec // Path is a config file that was just loaded
+	if err != nil {
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	return &doc
+}
+
+// lineOf returns the line of the setting at path, e.g. certificates[2].port,
+// and whether the setting is in the file. For a setting that isn't, such as
+// a default, the line of the closest enclosing entry is returned, or 0 if
+// there is none.
+func lineOf(root *yaml.Node, path string) (int, bool) {
+	if root == nil || path == "" {
+		return 0, false
+	}
+
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	line := 0
+	for _, seg := range splitPath(path) {
+		next, segLine := child(node, seg)
+		if next == nil {
+			return line, false
+		}
+		node, line = next, segLine
+	}
+	return line, true
+}
+
+// child returns the value of a mapping key or a sequence item, and the line
+// the key or item starts on
+func child(node *yaml.Node, seg string) (*yaml.Node, int) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 || i >= len(node.Content) {
+			return nil, 0
+		}
+		return node.Content[i], node.Content[i].Line
+
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			// Viper matches keys case-insensitively
+			if strings.EqualFold(node.Content[i].Value, seg) {
+				return node.Content[i+1], node.Content[i].Line
+			}
+		}
+	}
+	return nil, 0
+}
+
+// splitPath splits certificates[2].port into certificates, 2 and port
+func splitPath(path string) []string {
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	return strings.Split(path, ".")
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidate_CollectsAllErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"certwatch.yaml": `api:
+  key: "cw_test"
+agent:
+  name: test
+  concurrency: 0
+certificates:
+  - hostname: a.example.com
+  - hostname: b.example.com
+    port: 70000
+groups:
+  - name: web
+    hostnames: [c.example.com, d.example.com]
+    defaults:
+      scan_interval: 5s
+`,
+		"certwatch.d/team.yaml": `certificates:
+  - hostname: a.example.com
+`,
+	})
+
+	cfg, err := loadFile(t, filepath.Join(dir, "certwatch.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var errs ValidationErrors
+	if err := cfg.Validate(); !errors.As(err, &errs) {
+		t.Fatalf("Validate() error = %v, want ValidationErrors", err)
+	}
+
+	main := filepath.Join(dir, "certwatch.yaml")
+	fragment := filepath.Join(dir, "certwatch.d", "team.yaml")
+	want := []ValidationError{
+		{Path: "agent.concurrency", Message: "must be between 1 and 500", File: main, Line: 5},
+		{Path: "certificates[1].port", Message: "must be between 1 and 65535", File: main, Line: 9},
+		{
+			Path:    "certificates[0].hostname",
+			Message: "duplicate hostname:port 'a.example.com:443' (first defined in " + main + ":7)",
+			File:    fragment, Line: 2,
+		},
+		// Reported once for both targets of the group
+		{Path: "groups[0].defaults.scan_interval", Message: "must be at least 10s", File: main, Line: 14},
+	}
+
+	got := make([]ValidationError, len(errs))
+	for i, e := range errs {
+		got[i] = *e
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() errors:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestValidate_Offline(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"certwatch.yaml": `agent:
+  name: test
+  offline: true
+  remote_targets: true
+certificates:
+  - hostname: a.example.com
+`,
+	})
+
+	cfg, err := loadFile(t, filepath.Join(dir, "certwatch.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// No API key is required, but remote targets need the API
+	errs := Violations(cfg.Validate())
+	if len(errs) != 1 || errs[0].Path != "agent.remote_targets" {
+		t.Fatalf("Validate() errors = %v, want only agent.remote_targets", errs)
+	}
+
+	cfg.Agent.RemoteTargets = false
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v for an offline config without a key", err)
+	}
+}
+
+func TestLineOf(t *testing.T) {
+	var doc yaml.Node
+	src := `agent:
+  name: test
+  tag_scan_intervals:
+    "2024": 1m
+certificates:
+  - hostname: a.example.com
+  - hostname: b.example.com
+    tags:
+      - prod
+`
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	tests := []struct {
+		path      string
+		wantLine  int
+		wantFound bool
+	}{
+		{"agent.name", 2, true},
+		{"agent.tag_scan_intervals.2024", 4, true},
+		{"certificates[1]", 7, true},
+		{"certificates[1].tags[0]", 9, true},
+		{"certificates[0].port", 6, false}, // Closest entry
+		{"certificates[5]", 5, false},
+		{"api.key", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			line, found := lineOf(&doc, tt.path)
+			if line != tt.wantLine || found != tt.wantFound {
+				t.Errorf("lineOf() = %d, %v, want %d, %v", line, found, tt.wantLine, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestViolations(t *testing.T) {
+	if got := Violations(nil); got != nil {
+		t.Errorf("Violations(nil) = %v, want nil", got)
+	}
+
+	got := Violations(errors.New("failed to unmarshal config"))
+	if len(got) != 1 || got[0].Message != "failed to unmarshal config" || got[0].Path != "" {
+		t.Errorf("Violations() = %+v, want the error as a single violation", got)
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// expandGroups appends the targets of every group to the certificate list,
+// with the group's defaults applied
+func (c *Config) expandGroups() error {
+	l := &errorList{nodes: c.nodes}
+	indexes := make(map[string]int) // Group index within each source file
+	for i := range c.Groups {
+		g := &c.Groups[i]
+		entry := fmt.Sprintf("groups[%d]", indexes[g.Source])
+		indexes[g.Source]++
+
+		for j, hostname := range g.Hostnames {
+			path := fmt.Sprintf("%s.hostnames[%d]", entry, j)
+			cert, err := ParseTarget(hostname)
+			if err != nil {
+				l.add(g.Source, path, "%v", err)
+				continue
+			}
+			// Without an explicit port the group default applies
+			if _, _, splitErr := net.SplitHostPort(hostname); splitErr != nil {
+				cert.Port = 0
+			}
+			c.Certificates = append(c.Certificates, g.expand(cert, path, nil))
+		}
+
+		for j := range g.Certificates {
+			path := fmt.Sprintf("%s.certificates[%d]", entry, j)
+			set := func(field string) bool { return l.defined(g.Source, path+"."+field) }
+			c.Certificates = append(c.Certificates, g.expand(g.Certificates[j], path, set))
+		}
+	}
+	return l.err()
+}
+
+// expand applies the group's defaults to one of its targets. set reports
+// whether the target sets a field itself; nil if it sets none.
+func (g *GroupConfig) expand(cert CertificateConfig, entry string, set func(field string) bool) CertificateConfig {
+	cert.Source = g.Source
+	cert.Group = g.Name
+	cert.entry = entry
+	if set == nil {
+		set = func(string) bool { return false }
+	}
+	cert.inherit(&g.Defaults, set)
+	return cert
+}
+
+// inherit fills the fields a certificate doesn't set from defaults. A field
+// set to its zero value, e.g. priority: 0 or pq_only: false, overrides the
+// default; a zero port still means the default port. Tags are combined
+// rather than replaced, unless the certificate sets tags: [] to drop them.
+func (c *CertificateConfig) inherit(d *CertificateConfig, set func(field string) bool) {
+	if c.Port == 0 {
+		c.Port = d.Port
+	}
+	if c.Notes == "" && !set("notes") {
+		c.Notes = d.Notes
+	}
+	if c.Owner == "" && !set("owner") {
+		c.Owner = d.Owner
+	}
+	if c.ScanInterval == 0 && !set("scan_interval") {
+		c.ScanInterval = d.ScanInterval
+	}
+	if c.Priority == 0 && !set("priority") {
+		c.Priority = d.Priority
+	}
+	if c.Pins.IsEmpty() && !set("pins") {
+		c.Pins = d.Pins
+	}
+	if !set("pq_only") {
+		c.PQOnly = c.PQOnly || d.PQOnly
+	}
+	if len(c.Tags) > 0 || !set("tags") {
+		c.Tags = mergeTags(d.Tags, c.Tags)
+	}
+}
+
+// mergeTags returns the union of two tag lists, keeping their order
+func mergeTags(a, b []string) []string {
+	if len(a) == 0 {
+		return b
+	}
+
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := make([]string, 0, len(a)+len(b))
+	for _, tags := range [][]string{a, b} {
+		for _, tag := range tags {
+			if !seen[tag] {
+				seen[tag] = true
+				merged = append(merged, tag)
+			}
+		}
+	}
+	return merged
+}
+
+func (c *Config) validateGroups(l *errorList) {
+	seen := make(map[string]bool, len(c.Groups))
+	indexes := make(map[string]int)
+	for i := range c.Groups {
+		g := &c.Groups[i]
+		entry := fmt.Sprintf("groups[%d]", indexes[g.Source])
+		indexes[g.Source]++
+
+		if g.Name == "" {
+			l.add(g.Source, entry+".name", "is required")
+		} else if seen[g.Name] {
+			l.add(g.Source, entry+".name", "duplicate group name '%s'", g.Name)
+		}
+		seen[g.Name] = true
+
+		if len(g.Hostnames) == 0 && len(g.Certificates) == 0 {
+			l.add(g.Source, entry, "at least one hostname or certificate is required")
+		}
+
+		if g.Defaults.Hostname != "" {
+			l.add(g.Source, entry+".defaults.hostname", "is not allowed")
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_ExpandsGroups(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"certwatch.yaml": testMainConfig + `groups:
+  - name: payments
+    defaults:
+      port: 8443
+      tags: [payments, prod]
+      notes: Payments team
+      owner: payments-team
+      scan_interval: 5m
+      priority: 3
+    hostnames:
+      - pay.example.com
+      - api.pay.example.com:9443
+    certificates:
+      - hostname: legacy.pay.example.com
+        port: 443
+        tags: [legacy, prod]
+        notes: Old stack
+`,
+		"certwatch.d/web.yaml": `groups:
+  - name: web
+    hostnames: [web.example.com]
+`,
+	})
+
+	cfg, err := loadFile(t, filepath.Join(dir, "certwatch.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	byHost := make(map[string]CertificateConfig)
+	for _, c := range cfg.Certificates {
+		byHost[c.Hostname] = c
+	}
+
+	tests := []struct {
+		hostname string
+		want     CertificateConfig
+	}{
+		{"main.example.com", CertificateConfig{Port: 443}},
+		{"pay.example.com", CertificateConfig{
+			Group: "payments", Port: 8443, Tags: []string{"payments", "prod"},
+			Notes: "Payments team", Owner: "payments-team", ScanInterval: 5 * time.Minute, Priority: 3,
+			entry: "groups[0].hostnames[0]",
+		}},
+		{"api.pay.example.com", CertificateConfig{
+			Group: "payments", Port: 9443, Tags: []string{"payments", "prod"},
+			Notes: "Payments team", Owner: "payments-team", ScanInterval: 5 * time.Minute, Priority: 3,
+			entry: "groups[0].hostnames[1]",
+		}},
+		{"legacy.pay.example.com", CertificateConfig{
+			Group: "payments", Port: 443, Tags: []string{"payments", "prod", "legacy"},
+			Notes: "Old stack", Owner: "payments-team", ScanInterval: 5 * time.Minute, Priority: 3,
+			entry: "groups[0].certificates[0]",
+		}},
+		{"web.example.com", CertificateConfig{Group: "web", Port: 443, entry: "groups[0].hostnames[0]"}},
+	}
+
+	if len(byHost) != len(tests) {
+		t.Fatalf("got %d targets, want %d", len(byHost), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			got, ok := byHost[tt.hostname]
+			if !ok {
+				t.Fatal("target missing")
+			}
+			got.Hostname, got.Source = "", ""
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if src := byHost["web.example.com"].Source; src != filepath.Join(dir, "certwatch.d", "web.yaml") {
+		t.Errorf("web.example.com source = %s, want the fragment", src)
+	}
+}
+
+func TestLoad_GroupTargetsOverrideWithZeroValues(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"certwatch.yaml": testMainConfig + `groups:
+  - name: payments
+    defaults:
+      tags: [payments]
+      notes: Payments team
+      priority: 3
+      pq_only: true
+    certificates:
+      - hostname: a.pay.example.com
+        priority: 0
+        pq_only: false
+        notes: ""
+        tags: []
+      - hostname: b.pay.example.com
+        tags: [legacy]
+`,
+	})
+
+	cfg, err := loadFile(t, filepath.Join(dir, "certwatch.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	byHost := make(map[string]CertificateConfig)
+	for _, c := range cfg.Certificates {
+		byHost[c.Hostname] = c
+	}
+	if a := byHost["a.pay.example.com"]; a.Priority != 0 || a.PQOnly || a.Notes != "" || len(a.Tags) != 0 {
+		t.Errorf("a.pay.example.com = %+v, want every default overridden", a)
+	}
+	if b := byHost["b.pay.example.com"]; b.Priority != 3 || !b.PQOnly || b.Notes != "Payments team" || !reflect.DeepEqual(b.Tags, []string{"payments", "legacy"}) {
+		t.Errorf("b.pay.example.com = %+v, want the defaults inherited", b)
+	}
+}
+
+func TestValidate_Groups(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  []GroupConfig
+		wantErr string
+	}{
+		{
+			name:    "missing name",
+			groups:  []GroupConfig{{Hostnames: []string{"a.example.com"}}},
+			wantErr: "groups[0].name: is required",
+		},
+		{
+			name: "duplicate name",
+			groups: []GroupConfig{
+				{Name: "web", Hostnames: []string{"a.example.com"}},
+				{Name: "web", Hostnames: []string{"b.example.com"}},
+			},
+			wantErr: "groups[1].name: duplicate group name 'web'",
+		},
+		{
+			name:    "empty group",
+			groups:  []GroupConfig{{Name: "web"}},
+			wantErr: "at least one hostname or certificate is required",
+		},
+		{
+			name:    "hostname in defaults",
+			groups:  []GroupConfig{{Name: "web", Hostnames: []string{"a.example.com"}, Defaults: CertificateConfig{Hostname: "x"}}},
+			wantErr: "groups[0].defaults.hostname: is not allowed",
+		},
+		{
+			name: "duplicate across groups",
+			groups: []GroupConfig{
+				{Name: "web", Hostnames: []string{"a.example.com"}},
+				{Name: "api", Hostnames: []string{"a.example.com"}},
+			},
+			wantErr: "groups[1].hostnames[0].hostname: duplicate hostname:port 'a.example.com:443' (first defined in groups[0].hostnames[0])",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.Groups = tt.groups
+			if err := cfg.expandGroups(); err != nil {
+				t.Fatalf("expandGroups() error = %v", err)
+			}
+			for i := range cfg.Certificates {
+				if cfg.Certificates[i].Port == 0 {
+					cfg.Certificates[i].Port = 443
+				}
+			}
+
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func validTestConfig() *Config {
+	return &Config{
+		API: APIConfig{Endpoint: "https://api.example.com", Key: "cw_test", Timeout: time.Second},
+		Agent: AgentConfig{
+			Name:         "test",
+			SyncInterval: time.Minute,
+			ScanInterval: time.Minute,
+			Concurrency:  1,
+			LogLevel:     "info",
+		},
+		Certificates: []CertificateConfig{{Hostname: "main.example.com", Port: 443}},
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import "time"
+
+// HistoryConfig keeps a record of every scan result in the state directory,
+// for `cw-agent history`
+type HistoryConfig struct {
+	Retention  time.Duration `mapstructure:"retention"`   // 0 disables the history
+	MaxEntries int           `mapstructure:"max_entries"` // Per target, after merging unchanged results
+}
+
+// Enabled returns true if scan results are recorded
+func (h *HistoryConfig) Enabled() bool {
+	return h.Retention > 0
+}
+
+// MinHistoryRetention is the shortest allowed history retention
+const MinHistoryRetention = time.Hour
+
+// validateHistory checks the history settings, unless the history is disabled
+func (c *Config) validateHistory(l *errorList) {
+	h := &c.History
+	file := c.mainFile()
+
+	if h.Retention < 0 {
+		l.add(file, "history.retention", "must not be negative (or 0 to disable)")
+		return
+	}
+	if !h.Enabled() {
+		return
+	}
+	if h.Retention < MinHistoryRetention {
+		l.add(file, "history.retention", "must be at least %s (or 0 to disable)", MinHistoryRetention)
+	}
+	if h.MaxEntries < 1 {
+		l.add(file, "history.max_entries", "must be at least 1")
+	}
+}
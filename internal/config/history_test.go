@@ -0,0 +1,34 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidate_History(t *testing.T) {
+	tests := []struct {
+		name    string
+		history HistoryConfig
+		want    []string
+	}{
+		{"enabled", HistoryConfig{Retention: 720 * time.Hour, MaxEntries: 1000}, nil},
+		{"disabled", HistoryConfig{}, nil},
+		{"negative retention", HistoryConfig{Retention: -time.Hour, MaxEntries: 1000}, []string{"history.retention"}},
+		{"short retention", HistoryConfig{Retention: time.Minute, MaxEntries: 0}, []string{"history.retention", "history.max_entries"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			cfg.History = tt.history
+
+			var got []string
+			for _, e := range Violations(cfg.Validate()) {
+				got = append(got, e.Path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Validate() error paths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/certwatch-app/cw-agent/internal/secret"
+)
+
+// fragmentExtensions are the file extensions loaded from the conf.d directory
+var fragmentExtensions = []string{"*.yaml", "*.yml"}
+
+// ConfDir returns the fragment directory that belongs to a config file,
+// e.g. certwatch.d next to certwatch.yaml
+func ConfDir(configFile string) string {
+	base := filepath.Base(configFile)
+	return filepath.Join(filepath.Dir(configFile), strings.TrimSuffix(base, filepath.Ext(base))+".d")
+}
+
+// loadIncludes appends the certificates of every included file and of the
+// conf.d directory next to the main config file. Files are loaded in
+// include order, then conf.d in lexical order; a file is loaded only once.
+func (c *Config) loadIncludes(configFile string) error {
+	baseDir := filepath.Dir(configFile)
+
+	var patterns []string
+	for _, inc := range c.Include {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(baseDir, inc)
+		}
+		patterns = append(patterns, inc)
+	}
+	explicit := len(patterns)
+
+	confDir := ConfDir(configFile)
+	for _, ext := range fragmentExtensions {
+		patterns = append(patterns, filepath.Join(confDir, ext))
+	}
+	c.watchPatterns = patterns
+
+	loaded := map[string]bool{filepath.Clean(configFile): true}
+	for i, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("include %q: %w", pattern, err)
+		}
+
+		// A literal include that doesn't exist is most likely a typo
+		if len(matches) == 0 && i < explicit && !hasGlobMeta(pattern) {
+			return fmt.Errorf("include %q: %w", pattern, os.ErrNotExist)
+		}
+
+		for _, path := range matches {
+			if loaded[filepath.Clean(path)] {
+				continue
+			}
+			loaded[filepath.Clean(path)] = true
+
+			certs, groups, err := loadFragment(path)
+			if err != nil {
+				return err
+			}
+			c.Certificates = append(c.Certificates, certs...)
+			c.Groups = append(c.Groups, groups...)
+			c.files = append(c.files, path)
+			c.nodes[path] = readNode(path)
+		}
+	}
+
+	return nil
+}
+
+// loadFragment reads the certificates and groups from an included file.
+// Fragments may only contain certificates and groups lists.
+func loadFragment(path string) ([]CertificateConfig, []GroupConfig, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := secret.ReadInConfig(v); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, key := range v.AllKeys() {
+		if key != "certificates" && key != "groups" {
+			return nil, nil, fmt.Errorf("%s: only certificates and groups can be defined in included files (found %q)", path, key)
+		}
+	}
+
+	var fragment struct {
+		Certificates []CertificateConfig `mapstructure:"certificates"`
+		Groups       []GroupConfig       `mapstructure:"groups"`
+	}
+	if err := v.Unmarshal(&fragment); err != nil {
+		return nil, nil, fmt.Errorf("%s: failed to unmarshal: %w", path, err)
+	}
+	for i := range fragment.Certificates {
+		fragment.Certificates[i].Source = path
+	}
+	for i := range fragment.Groups {
+		fragment.Groups[i].Source = path
+	}
+
+	return fragment.Certificates, fragment.Groups, nil
+}
+
+// Files returns every file the configuration was loaded from, main file first
+func (c *Config) Files() []string {
+	return c.files
+}
+
+// WatchPaths returns the files and glob patterns whose changes affect the
+// configuration, so new files matching an include are picked up as well
+func (c *Config) WatchPaths() []string {
+	paths := make([]string, 0, len(c.files)+len(c.watchPatterns))
+	paths = append(paths, c.files...)
+	return append(paths, c.watchPatterns...)
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testMainConfig = `api:
+  key: cw_test_key
+agent:
+  name: test-agent
+certificates:
+  - hostname: main.example.com
+`
+
+// writeFiles creates files relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func loadFile(t *testing.T, path string) (*Config, error) {
+	t.Helper()
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	return Load(v)
+}
+
+func TestLoad_MergesIncludesAndConfDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"certwatch.yaml": testMainConfig + "include:\n  - teams/*.yaml\n",
+		"teams/payments.yaml": `certificates:
+  - hostname: pay.example.com
+    tags: [payments]
+`,
+		"certwatch.d/10-web.yaml": `certificates:
+  - hostname: web.example.com
+    port: 8443
+`,
+		"certwatch.d/README.md": "not a fragment",
+	})
+
+	cfg, err := loadFile(t, filepath.Join(dir, "certwatch.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []struct {
+		hostport string
+		source   string
+	}{
+		{"main.example.com:443", "certwatch.yaml"},
+		{"pay.example.com:443", filepath.Join("teams", "payments.yaml")},
+		{"web.example.com:8443", filepath.Join("certwatch.d", "10-web.yaml")},
+	}
+	if len(cfg.Certificates) != len(want) {
+		t.Fatalf("got %d certificates, want %d", len(cfg.Certificates), len(want))
+	}
+	for i, w := range want {
+		cert := cfg.Certificates[i]
+		if cert.GetHostPort() != w.hostport {
+			t.Errorf("[%d] = %s, want %s", i, cert.GetHostPort(), w.hostport)
+		}
+		if cert.Source != filepath.Join(dir, w.source) {
+			t.Errorf("[%d] source = %s, want %s", i, cert.Source, filepath.Join(dir, w.source))
+		}
+	}
+
+	if len(cfg.Files()) != 3 {
+		t.Errorf("Files() = %v, want 3 files", cfg.Files())
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestLoad_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing literal include",
+			files:   map[string]string{"certwatch.yaml": testMainConfig + "include: [missing.yaml]\n"},
+			wantErr: "missing.yaml",
+		},
+		{
+			name: "fragment with agent settings",
+			files: map[string]string{
+				"certwatch.yaml":          testMainConfig,
+				"certwatch.d/broken.yaml": "agent:\n  name: other\n",
+			},
+			wantErr: "only certificates and groups can be defined in included files",
+		},
+		{
+			name: "invalid yaml",
+			files: map[string]string{
+				"certwatch.yaml":          testMainConfig,
+				"certwatch.d/broken.yaml": "certificates: [",
+			},
+			wantErr: "broken.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			_, err := loadFile(t, filepath.Join(dir, "certwatch.yaml"))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Glob includes may match nothing
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"certwatch.yaml": testMainConfig + "include: [teams/*.yaml]\n"})
+	if _, err := loadFile(t, filepath.Join(dir, "certwatch.yaml")); err != nil {
+		t.Errorf("Load() error = %v, want empty glob to be allowed", err)
+	}
+}
+
+func TestValidate_DuplicateAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"certwatch.yaml": testMainConfig,
+		"certwatch.d/team.yaml": `certificates:
+  - hostname: other.example.com
+  - hostname: main.example.com
+`,
+	})
+
+	cfg, err := loadFile(t, filepath.Join(dir, "certwatch.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected duplicate error")
+	}
+
+	// The entry index is relative to the file that defines it
+	fragment := filepath.Join(dir, "certwatch.d", "team.yaml")
+	wantPrefix := fragment + ":3: certificates[1].hostname: duplicate hostname:port 'main.example.com:443'"
+	if !strings.HasPrefix(err.Error(), wantPrefix) {
+		t.Errorf("error = %q, want prefix %q", err, wantPrefix)
+	}
+	if !strings.Contains(err.Error(), "first defined in "+filepath.Join(dir, "certwatch.yaml")+":") {
+		t.Errorf("error = %q, want it to name the first definition", err)
+	}
+}
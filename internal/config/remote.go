@@ -0,0 +1,76 @@
+package config
+
+import "fmt"
+
+// RemoteTarget is a target managed in CertWatch and fetched by agents
+// with agent.remote_targets enabled
+// Fields are ordered for optimal memory alignment
+type RemoteTarget struct {
+	Hostname string   `json:"hostname"`
+	Notes    string   `json:"notes,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Port     int      `json:"port,omitempty"` // 0 uses 443
+}
+
+// MergeRemoteTargets appends remote targets to the configured certificates.
+// A configured certificate wins over a remote target with the same
+// hostname:port. Remote targets that would fail validation are skipped and
+// returned as errors, so one bad entry doesn't stop the agent.
+func MergeRemoteTargets(certs []CertificateConfig, remote []RemoteTarget) ([]CertificateConfig, []error) {
+	merged := make([]CertificateConfig, 0, len(certs)+len(remote))
+	seen := make(map[string]bool, len(certs)+len(remote))
+	for i := range certs {
+		if certs[i].Remote {
+			continue
+		}
+		merged = append(merged, certs[i])
+		seen[certs[i].GetHostPort()] = true
+	}
+
+	var errs []error
+	for i := range remote {
+		cert := CertificateConfig{
+			Hostname: remote[i].Hostname,
+			Port:     remote[i].Port,
+			Notes:    remote[i].Notes,
+			Tags:     remote[i].Tags,
+			Remote:   true,
+		}
+		if cert.Port == 0 {
+			cert.Port = 443
+		}
+		if err := cert.validateRemote(); err != nil {
+			errs = append(errs, fmt.Errorf("remote target %q: %w", cert.GetHostPort(), err))
+			continue
+		}
+
+		key := cert.GetHostPort()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, cert)
+	}
+
+	return merged, errs
+}
+
+// validateRemote applies the limits Validate enforces on configured
+// certificates to a remote target
+func (c *CertificateConfig) validateRemote() error {
+	if c.Hostname == "" {
+		return fmt.Errorf("hostname is required")
+	}
+	if c.Port < 1 || c.Port > MaxPort {
+		return fmt.Errorf("port must be between 1 and %d", MaxPort)
+	}
+	for _, tag := range c.Tags {
+		if len(tag) > MaxTagLength {
+			return fmt.Errorf("tag %q must be at most %d characters", tag, MaxTagLength)
+		}
+	}
+	if len(c.Notes) > MaxNotesLength {
+		return fmt.Errorf("notes must be at most %d characters", MaxNotesLength)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeRemoteTargets(t *testing.T) {
+	local := []CertificateConfig{
+		{Hostname: "local.example.com", Port: 443, Notes: "from yaml"},
+		{Hostname: "stale.example.com", Port: 443, Remote: true}, // From a previous merge
+	}
+	remote := []RemoteTarget{
+		{Hostname: "local.example.com", Notes: "from api"},
+		{Hostname: "api.example.com", Port: 8443, Tags: []string{"ui"}},
+		{Hostname: ""},
+		{Hostname: "bad-port.example.com", Port: 70000},
+		{Hostname: "long-tag.example.com", Tags: []string{strings.Repeat("t", MaxTagLength+1)}},
+		{Hostname: "api.example.com", Port: 8443},
+	}
+
+	merged, errs := MergeRemoteTargets(local, remote)
+
+	var got []string
+	for _, c := range merged {
+		source := "local"
+		if c.Remote {
+			source = "remote"
+		}
+		got = append(got, c.GetHostPort()+" "+source+" "+c.Notes)
+	}
+	want := []string{
+		"local.example.com:443 local from yaml",
+		"api.example.com:8443 remote ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("merged =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("errors = %v, want 3", errs)
+	}
+	for i, want := range []string{"hostname is required", "port must be between", "must be at most"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("errors[%d] = %q, want it to contain %q", i, errs[i], want)
+		}
+	}
+}
+
+func TestValidate_RemoteTargetsWithoutCertificates(t *testing.T) {
+	cfg := &Config{
+		API:   APIConfig{Endpoint: "https://api.certwatch.app", Key: "cw_test", Timeout: MinAPITimeout},
+		Agent: AgentConfig{Name: "agent", SyncInterval: MinSyncInterval, ScanInterval: MinScanInterval, Concurrency: 1, LogLevel: "info"},
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("Validate() without certificates succeeded, want error")
+	}
+
+	cfg.Agent.RemoteTargets = true
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() with remote_targets error = %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/certwatch-app/cw-agent/internal/jsonschema"
+	"github.com/certwatch-app/cw-agent/internal/secret"
+)
+
+// pinPattern matches a SHA-256 hash in hex, as accepted by NormalizePin
+const pinPattern = `^\s*(:*[0-9A-Fa-f]){64}:*\s*$`
+
+// Schema returns the JSON Schema of the agent configuration file, with the
+// limits Validate enforces and the defaults Load applies. Values may also
+// be ${NAME} environment references.
+func Schema() *jsonschema.Schema {
+	s := schema()
+	s.Schema = jsonschema.Draft
+	s.ID = "https://certwatch.app/schemas/cw-agent.json"
+	s.Title = "CertWatch Agent configuration"
+	s.AllowAlternative(&jsonschema.Schema{Type: "string", Pattern: secret.ReferencePattern})
+	return s
+}
+
+// schema builds the configuration schema before environment references are
+// allowed, so its constraints can be checked against Validate
+func schema() *jsonschema.Schema {
+	s := jsonschema.Reflect(Config{})
+
+	api := s.Field("api")
+	api.Field("endpoint").Pattern = "^https?://"
+	api.Field("key").Pattern = "^(cw_.*)?$" // Empty when key_file is set
+	api.Field("timeout").MinimumDuration = MinAPITimeout.String()
+	api.Field("outbox_size_mb").Minimum = jsonschema.Int(0)
+	api.Field("outbox_size_mb").Description = "0 disables the outbox"
+
+	agent := s.Field("agent")
+	agent.Field("name").MinLength = jsonschema.Int(1)
+	agent.Field("name").MaxLength = jsonschema.Int(MaxNameLength)
+	agent.Field("log_level").Enum = LogLevels
+	agent.Field("sync_interval").MinimumDuration = MinSyncInterval.String()
+	agent.Field("scan_interval").MinimumDuration = MinScanInterval.String()
+	agent.Field("tag_scan_intervals").AdditionalProperties.MinimumDuration = MinScanInterval.String()
+	agent.Field("heartbeat_interval").MinimumDuration = MinHeartbeatInterval.String()
+	agent.Field("heartbeat_interval").Description = "0 disables heartbeats"
+	agent.Field("concurrency").Minimum = jsonschema.Int(1)
+	agent.Field("concurrency").Maximum = jsonschema.Int(MaxConcurrency)
+	agent.Field("metrics_port").Minimum = jsonschema.Int(0)
+	agent.Field("metrics_port").Maximum = jsonschema.Int(MaxPort)
+	agent.Field("metrics_port").Description = "0 disables the metrics server"
+
+	constrainCertificate(s.Field("certificates").Items)
+
+	group := s.Field("groups").Items
+	group.Required = []string{"name"}
+	group.Field("name").MinLength = jsonschema.Int(1)
+	group.Field("hostnames").Items.MinLength = jsonschema.Int(1)
+	group.Field("hostnames").Items.Description = `"host" or "host:port"`
+	constrainCertificate(group.Field("certificates").Items)
+	constrainCertificate(group.Field("defaults"))
+	// Defaults apply to several targets, so they can't name one
+	delete(group.Field("defaults").Properties, "hostname")
+	group.Field("defaults").Required = nil
+
+	alerting := s.Field("alerting")
+	alerting.Field("expiry_warning_days").Minimum = jsonschema.Int(0)
+	alerting.Field("expiry_critical_days").Minimum = jsonschema.Int(0)
+	alerting.Field("failure_threshold").Minimum = jsonschema.Int(1)
+	alerting.Field("repeat_interval").Description = "0 notifies each alert once"
+	webhook := alerting.Field("webhooks").Items
+	webhook.Required = []string{"name", "url"}
+	webhook.Field("name").MinLength = jsonschema.Int(1)
+	webhook.Field("url").Pattern = "^https?://"
+	webhook.Field("template").Description = "Go text/template for the request body; JSON if empty"
+	webhook.Field("timeout").MinimumDuration = MinNotifierTimeout.String()
+	webhook.Field("timeout").Default = DefaultNotifierTimeout.String()
+	email := alerting.Field("email")
+	email.Field("host").Description = "SMTP server; email is disabled without a host"
+	email.Field("port").Minimum = jsonschema.Int(1)
+	email.Field("port").Maximum = jsonschema.Int(MaxPort)
+	email.Field("tls").Enum = EmailTLSModes
+	email.Field("timeout").MinimumDuration = MinNotifierTimeout.String()
+	email.Field("routes").Items.Required = []string{"tags", "to"}
+	alertmanager := alerting.Field("alertmanager")
+	alertmanager.Field("urls").Description = "Alertmanager instances; alertmanager is disabled without URLs"
+	alertmanager.Field("urls").Items.Pattern = "^https?://"
+	alertmanager.Field("timeout").MinimumDuration = MinNotifierTimeout.String()
+	pagerduty := alerting.Field("pagerduty")
+	pagerduty.Field("routing_key").Description = "Events API v2 integration key; pagerduty is disabled without a key"
+	pagerduty.Field("routing_key").Pattern = fmt.Sprintf("^(.{%d})?$", PagerDutyRoutingKeyLength)
+	pagerduty.Field("url").Pattern = "^https?://"
+	pagerduty.Field("timeout").MinimumDuration = MinNotifierTimeout.String()
+
+	history := s.Field("history")
+	history.Field("retention").MinimumDuration = MinHistoryRetention.String()
+	history.Field("retention").Description = "0 disables the scan history"
+	history.Field("max_entries").Minimum = jsonschema.Int(1)
+	history.Field("max_entries").Description = "Entries kept per target; unchanged results share an entry"
+
+	control := s.Field("control_api")
+	control.Field("listen").Description = "host:port to serve the control API on; empty disables it"
+	control.Field("token").Pattern = fmt.Sprintf("^(.{%d,})?$", MinControlTokenLength) // Empty when token_file is set
+	control.Field("token").Description = "Bearer token required by every request"
+
+	// Document the defaults Load applies
+	v := viper.New()
+	setDefaults(v)
+	for _, key := range v.AllKeys() {
+		s.Field(key).Default = v.Get(key)
+	}
+
+	return s
+}
+
+// constrainCertificate adds the limits of a certificate entry
+func constrainCertificate(s *jsonschema.Schema) {
+	s.Required = []string{"hostname"}
+	s.Field("hostname").MinLength = jsonschema.Int(1)
+	s.Field("port").Minimum = jsonschema.Int(0) // 0 uses the default
+	s.Field("port").Maximum = jsonschema.Int(MaxPort)
+	s.Field("port").Default = 443
+	s.Field("tags").Items.MaxLength = jsonschema.Int(MaxTagLength)
+	s.Field("notes").MaxLength = jsonschema.Int(MaxNotesLength)
+	s.Field("owner").MaxLength = jsonschema.Int(MaxOwnerLength)
+	s.Field("scan_interval").MinimumDuration = MinScanInterval.String()
+	for _, pins := range []string{"leaf_sha256", "spki_sha256", "ca_sha256"} {
+		s.Field("pins." + pins).Items.Pattern = pinPattern
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/jsonschema"
+)
+
+// schemaTestConfig is a valid config with a group whose targets inherit
+// every default, so constraints on group defaults reach Validate, and with
+// every notifier, so the alerting settings are validated, and with the
+// history enabled
+func schemaTestConfig() *Config {
+	cfg := validTestConfig()
+	cfg.Groups = []GroupConfig{{
+		Name:         "web",
+		Hostnames:    []string{"a.example.com"},
+		Certificates: []CertificateConfig{{Hostname: "b.example.com"}},
+		Defaults:     CertificateConfig{Port: 443},
+	}}
+	cfg.Alerting = AlertingConfig{
+		Webhooks: []WebhookConfig{{Name: "ops", URL: "https://hooks.example.com/alerts"}},
+		Email: EmailConfig{
+			To:      []string{"ops@example.com"},
+			Host:    "smtp.example.com",
+			From:    "certwatch@example.com",
+			TLS:     EmailTLSStartTLS,
+			Timeout: 30 * time.Second,
+			Port:    587,
+		},
+		Alertmanager: AlertmanagerConfig{
+			URLs:    []string{"http://alertmanager:9093"},
+			Timeout: 10 * time.Second,
+		},
+		PagerDuty: PagerDutyConfig{
+			RoutingKey: "0123456789abcdef0123456789abcdef",
+			URL:        "https://events.pagerduty.com/v2/enqueue",
+			Timeout:    10 * time.Second,
+		},
+		RepeatInterval:     4 * time.Hour,
+		ExpiryWarningDays:  30,
+		ExpiryCriticalDays: 7,
+		FailureThreshold:   2,
+	}
+	cfg.History = HistoryConfig{Retention: 720 * time.Hour, MaxEntries: 1000}
+	return cfg
+}
+
+// TestSchema_MatchesValidate checks every limit in the schema against
+// Validate, so the two can't drift apart
+func TestSchema_MatchesValidate(t *testing.T) {
+	cases := jsonschema.Cases(schema())
+	if len(cases) == 0 {
+		t.Fatal("expected the schema to have constraints")
+	}
+
+	for _, c := range cases {
+		t.Run(c.String(), func(t *testing.T) {
+			cfg := schemaTestConfig()
+			if err := jsonschema.Set(cfg, c.Path, c.Value); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			// The steps of Load that follow unmarshaling
+			err := cfg.expandGroups()
+			if err == nil {
+				cfg.setDefaultPorts()
+				err = cfg.Validate()
+			}
+			if c.Valid && err != nil {
+				t.Errorf("schema allows the value, but Validate() error = %v", err)
+			}
+			if !c.Valid && err == nil {
+				t.Error("schema rejects the value, but Validate() accepts it")
+			}
+		})
+	}
+}
+
+func TestSchema_PinPattern(t *testing.T) {
+	re := regexp.MustCompile(pinPattern)
+	pins := []string{
+		"5c0ee1b8a0d8b0b9a1f2c3d4e5f60718293a4b5c6d7e8f901234567890abcdef",
+		"5C:0E:E1:B8:A0:D8:B0:B9:A1:F2:C3:D4:E5:F6:07:18:29:3A:4B:5C:6D:7E:8F:90:12:34:56:78:90:AB:CD:EF",
+		"5c0ee1b8a0d8b0b9a1f2c3d4e5f60718293a4b5c6d7e8f901234567890abcde",
+		"zz0ee1b8a0d8b0b9a1f2c3d4e5f60718293a4b5c6d7e8f901234567890abcdef",
+		"",
+	}
+	for _, pin := range pins {
+		want := len(NormalizePin(pin)) == 64
+		if got := re.MatchString(pin); got != want {
+			t.Errorf("pattern match of %q = %v, NormalizePin accepts it: %v", pin, got, want)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	s := Schema()
+
+	if got := s.Field("agent.sync_interval").Default; got != "5m" {
+		t.Errorf("agent.sync_interval default = %v, want 5m", got)
+	}
+	if got := s.Field("agent.concurrency"); len(got.AnyOf) != 2 {
+		t.Errorf("agent.concurrency = %+v, want environment references allowed", got)
+	}
+	if _, ok := s.Field("groups").Items.Field("defaults").Properties["hostname"]; ok {
+		t.Error("expected defaults.hostname to be disallowed")
+	}
+	if _, ok := s.Field("certificates").Items.Properties["source"]; ok {
+		t.Error("expected fields set by the loader to be left out")
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Errorf("Marshal() error = %v", err)
+	}
+}
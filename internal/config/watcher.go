@@ -0,0 +1,222 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultWatchDebounce is how long the watcher waits for writes to settle
+// before reporting a change. Editors often save a file in several steps.
+const DefaultWatchDebounce = 500 * time.Millisecond
+
+// Watcher reports changes to configuration files.
+//
+// The parent directories are watched rather than the files themselves, so
+// files replaced by atomic renames (editors, Kubernetes ConfigMap symlink
+// swaps) keep being tracked. Glob patterns match files created later, also
+// in directories created later, such as a new certwatch.d.
+type Watcher struct {
+	watcher  *fsnotify.Watcher
+	files    map[string]string // Watched path -> resolved path at last check
+	dirs     map[string]bool
+	missing  map[string]bool // Pattern directories that don't exist yet
+	patterns []string
+	debounce time.Duration
+	mu       sync.Mutex
+}
+
+// NewWatcher creates a watcher for the given configuration files and glob patterns
+func NewWatcher(paths []string, debounce time.Duration) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	w := &Watcher{
+		watcher:  fw,
+		dirs:     make(map[string]bool),
+		debounce: debounce,
+	}
+	if err := w.SetPaths(paths); err != nil {
+		fw.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// SetPaths replaces the watched files and patterns, e.g. after a reload
+// changed the include list
+func (w *Watcher) SetPaths(paths []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	files := make(map[string]string, len(paths))
+	var patterns []string
+	dirs := make(map[string]bool)
+	missing := make(map[string]bool)
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", p, err)
+		}
+
+		dir := filepath.Dir(abs)
+		if hasGlobMeta(abs) {
+			// The directory of a pattern may not exist yet, so the
+			// closest existing ancestor is watched for its creation
+			patterns = append(patterns, abs)
+			if !isDir(dir) {
+				missing[dir] = true
+				dir = existingAncestor(dir)
+			}
+		} else {
+			files[abs] = resolve(abs)
+		}
+		dirs[dir] = true
+	}
+
+	for dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			_ = w.watcher.Remove(dir)
+		}
+	}
+
+	w.files = files
+	w.patterns = patterns
+	w.dirs = dirs
+	w.missing = missing
+	return nil
+}
+
+// watchCreated watches the missing pattern directories, or their closest
+// ancestors, that were created since they were last checked. It reports
+// whether a pattern directory appeared, as files may already be in it.
+// Must be called with w.mu held.
+func (w *Watcher) watchCreated() bool {
+	appeared := false
+	for dir := range w.missing {
+		watch := existingAncestor(dir)
+		if !w.dirs[watch] {
+			if err := w.watcher.Add(watch); err != nil {
+				continue
+			}
+			w.dirs[watch] = true
+		}
+		if watch == dir {
+			delete(w.missing, dir)
+			appeared = true
+		}
+	}
+	return appeared
+}
+
+// Run calls onChange after the watched files change, until ctx is canceled.
+// Watcher errors are passed to onError. The watcher is closed on return.
+func (w *Watcher) Run(ctx context.Context, onChange func(), onError func(error)) {
+	defer w.watcher.Close()
+
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.affects(event) {
+				timer.Reset(w.debounce)
+			}
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			if onError != nil {
+				onError(err)
+			}
+
+		case <-timer.C:
+			onChange()
+		}
+	}
+}
+
+// affects reports whether an event touches a watched file, either directly,
+// through a matching pattern or by changing the target of a symlink
+func (w *Watcher) affects(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	name := filepath.Clean(event.Name)
+	changed := false
+	if event.Has(fsnotify.Create) && len(w.missing) > 0 {
+		changed = w.watchCreated()
+	}
+	for _, pattern := range w.patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			changed = true
+		}
+	}
+	for path, resolved := range w.files {
+		if path == name {
+			changed = true
+		}
+		if current := resolve(path); current != resolved {
+			w.files[path] = current
+			changed = true
+		}
+	}
+	return changed
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// existingAncestor returns dir if it exists, or its closest existing ancestor
+func existingAncestor(dir string) string {
+	for !isDir(dir) {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return dir
+}
+
+// resolve returns the real path of a file, or the path itself if it
+// can't be resolved (e.g. it was removed mid-rename)
+func resolve(path string) string {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+	return target
+}
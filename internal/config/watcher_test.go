@@ -0,0 +1,189 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher_DetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "certwatch.yaml")
+	if err := os.WriteFile(path, []byte("agent:\n  name: one\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	w, err := NewWatcher([]string{path}, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go w.Run(ctx, func() { changes <- struct{}{} }, nil)
+
+	// Unrelated files in the same directory are ignored
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	select {
+	case <-changes:
+		t.Fatal("unexpected change for unrelated file")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Atomic replace, as done by most editors
+	tmp := filepath.Join(dir, ".certwatch.yaml.tmp")
+	if err := os.WriteFile(tmp, []byte("agent:\n  name: two\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("failed to replace config: %v", err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change notification")
+	}
+
+	// Several writes in quick succession are reported once
+	time.Sleep(50 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte("agent:\n  name: three\n"), 0o600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change notification")
+	}
+	select {
+	case <-changes:
+		t.Error("expected writes to be debounced into one notification")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatcher_SymlinkSwap(t *testing.T) {
+	// Mimics a Kubernetes ConfigMap mount: the config file is a symlink
+	// through ..data, which is swapped to a new directory on update
+	dir := t.TempDir()
+	for _, v := range []string{"v1", "v2"} {
+		if err := os.Mkdir(filepath.Join(dir, v), 0o750); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, v, "certwatch.yaml"), []byte(v), 0o600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+	if err := os.Symlink("v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	path := filepath.Join(dir, "certwatch.yaml")
+	if err := os.Symlink(filepath.Join("..data", "certwatch.yaml"), path); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	w, err := NewWatcher([]string{path}, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go w.Run(ctx, func() { changes <- struct{}{} }, nil)
+
+	if err := os.Symlink("v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("failed to swap symlink: %v", err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change notification after the symlink swap")
+	}
+}
+
+func TestWatcher_Patterns(t *testing.T) {
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "certwatch.d")
+	if err := os.Mkdir(confDir, 0o750); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	w, err := NewWatcher([]string{filepath.Join(confDir, "*.yaml")}, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go w.Run(ctx, func() { changes <- struct{}{} }, nil)
+
+	if err := os.WriteFile(filepath.Join(confDir, "notes.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	select {
+	case <-changes:
+		t.Fatal("unexpected change for a file not matching the pattern")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// New fragments are picked up
+	if err := os.WriteFile(filepath.Join(confDir, "team.yaml"), []byte("certificates: []\n"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change notification for a new fragment")
+	}
+}
+
+func TestWatcher_PatternDirectoryCreatedLater(t *testing.T) {
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, "certwatch.d")
+
+	w, err := NewWatcher([]string{filepath.Join(confDir, "*.yaml")}, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go w.Run(ctx, func() { changes <- struct{}{} }, nil)
+
+	if err := os.Mkdir(confDir, 0o750); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change notification for the new directory")
+	}
+
+	// Fragments added to the new directory are picked up
+	if err := os.WriteFile(filepath.Join(confDir, "web.yaml"), []byte("certificates: []\n"), 0o600); err != nil {
+		t.Fatalf("failed to write fragment: %v", err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change notification for the new fragment")
+	}
+}
@@ -0,0 +1,309 @@
+// Package history keeps a local record of scan results in the state
+// directory, so the state of a target can be traced back across restarts.
+//
+// Results are appended to a JSON Lines file. Consecutive results of a target
+// that only differ in their scan time are merged into one entry when the
+// file is compacted, so the file grows with the number of changes rather
+// than the number of scans.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// FileName is the name of the history file in the state directory
+const FileName = ".certwatch-history.jsonl"
+
+// minCompactEntries is the number of appended entries that always triggers
+// a compaction, so a small history isn't rewritten on every few scans
+const minCompactEntries = 1000
+
+// Entry is the outcome of one or more consecutive scans of a target with
+// the same result
+// Fields are ordered for optimal memory alignment
+type Entry struct {
+	Issues        []scanner.ChainIssue `json:"issues,omitempty"`
+	NotAfter      *time.Time           `json:"not_after,omitempty"`
+	Target        string               `json:"target"`                // hostname:port
+	Fingerprint   string               `json:"fingerprint,omitempty"` // SHA-256 of the leaf certificate
+	Subject       string               `json:"subject,omitempty"`
+	Issuer        string               `json:"issuer,omitempty"`
+	KeyType       string               `json:"key_type,omitempty"`
+	Error         string               `json:"error,omitempty"`
+	TLSVersion    string               `json:"tls_version,omitempty"`
+	ScannedAt     time.Time            `json:"scanned_at"`      // First scan with this result
+	LastScannedAt time.Time            `json:"last_scanned_at"` // Last scan with this result
+	Scans         int                  `json:"scans"`
+	Success       bool                 `json:"success"`
+}
+
+// newEntry records a single scan result
+func newEntry(r *scanner.ScanResult) Entry {
+	e := Entry{
+		Target:        fmt.Sprintf("%s:%d", r.Hostname, r.Port),
+		Error:         r.Error,
+		TLSVersion:    r.TLSVersion,
+		ScannedAt:     r.ScannedAt,
+		LastScannedAt: r.ScannedAt,
+		Scans:         1,
+		Success:       r.Success,
+	}
+	if r.Certificate != nil {
+		notAfter := r.Certificate.NotAfter
+		e.NotAfter = &notAfter
+		e.Fingerprint = r.Certificate.FingerprintSHA256
+		e.Subject = r.Certificate.Subject
+		e.Issuer = r.Certificate.Issuer
+		e.KeyType = r.Certificate.KeyType
+	}
+	if r.Chain != nil {
+		e.Issues = r.Chain.Issues
+	}
+	return e
+}
+
+// sameResult returns true if two entries of a target only differ in when
+// and how often they were seen
+func sameResult(a, b *Entry) bool {
+	return a.Target == b.Target &&
+		a.Success == b.Success &&
+		a.Fingerprint == b.Fingerprint &&
+		a.Error == b.Error &&
+		a.TLSVersion == b.TLSVersion &&
+		slices.Equal(a.Issues, b.Issues)
+}
+
+// Store appends scan results to the history file and compacts it
+type Store struct {
+	file       *os.File
+	path       string
+	retention  time.Duration
+	maxEntries int
+	kept       int // Entries kept by the last compaction
+	appended   int // Entries appended since the last compaction
+	mu         sync.Mutex
+}
+
+// Open opens the history file in dir, creating it if needed. Entries older
+// than retention are dropped and at most maxEntries are kept per target.
+func Open(dir string, retention time.Duration, maxEntries int) (*Store, error) {
+	s := &Store{
+		path:       filepath.Join(dir, FileName),
+		retention:  retention,
+		maxEntries: maxEntries,
+	}
+	if err := s.compact(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetLimits changes the retention and the entries kept per target. They
+// apply from the next compaction on.
+func (s *Store) SetLimits(retention time.Duration, maxEntries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = retention
+	s.maxEntries = maxEntries
+}
+
+// Record appends a scan result. The file is compacted once it has grown by
+// as many entries as the last compaction kept.
+func (s *Store) Record(r *scanner.ScanResult) error {
+	line, err := json.Marshal(newEntry(r))
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("history file is closed")
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	s.appended++
+	if s.appended >= max(s.kept, minCompactEntries) {
+		return s.compact(time.Now())
+	}
+	return nil
+}
+
+// Close closes the history file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// compact rewrites the history file with merged and pruned entries and
+// reopens it for appending. If it fails, the old file stays open for
+// appending and compaction is retried later. The caller must hold s.mu,
+// except in Open.
+func (s *Store) compact(now time.Time) error {
+	// Not retried on every Record while the disk is full
+	s.appended = 0
+
+	entries, err := readFile(s.path)
+	if err != nil {
+		return err
+	}
+	entries = prune(merge(entries), now.Add(-s.retention), s.maxEntries)
+
+	// Written to a temporary file first, so a crash keeps the old history
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), FileName+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to write history file: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = f
+	s.kept = len(entries)
+	return nil
+}
+
+// Read returns the history of a target (hostname:port) in dir, oldest
+// first, with consecutive scans with the same result merged. It doesn't
+// need the agent to be stopped.
+func Read(dir, target string) ([]Entry, error) {
+	entries, err := readFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []Entry
+	for i := range entries {
+		if entries[i].Target == target {
+			matching = append(matching, entries[i])
+		}
+	}
+	return merge(matching), nil
+}
+
+// Latest returns the latest entry of every target in the history in dir,
+// by target
+func Latest(dir string) (map[string]Entry, error) {
+	entries, err := readFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]Entry)
+	for i := range entries {
+		e := &entries[i]
+		if cur, ok := latest[e.Target]; !ok || !e.LastScannedAt.Before(cur.LastScannedAt) {
+			latest[e.Target] = *e
+		}
+	}
+	return latest, nil
+}
+
+// readFile reads every entry of a history file. A missing file is an empty
+// history; lines that can't be parsed, like one cut short by a crash, are
+// skipped.
+func readFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) != nil || e.Target == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return entries, nil
+}
+
+// merge combines consecutive entries of each target with the same result,
+// keeping the order of the entries
+func merge(entries []Entry) []Entry {
+	merged := make([]Entry, 0, len(entries))
+	last := make(map[string]int) // Index in merged of each target's latest entry
+	for i := range entries {
+		e := entries[i]
+		if j, ok := last[e.Target]; ok && sameResult(&merged[j], &e) {
+			merged[j].LastScannedAt = e.LastScannedAt
+			merged[j].Scans += e.Scans
+			continue
+		}
+		last[e.Target] = len(merged)
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// prune drops entries last seen before cutoff and all but the newest
+// maxEntries entries of each target
+func prune(entries []Entry, cutoff time.Time, maxEntries int) []Entry {
+	kept := make(map[string]int)
+	pruned := make([]Entry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		if e.LastScannedAt.Before(cutoff) || kept[e.Target] >= maxEntries {
+			continue
+		}
+		kept[e.Target]++
+		pruned = append(pruned, *e)
+	}
+	slices.Reverse(pruned)
+	return pruned
+}
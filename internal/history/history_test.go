@@ -0,0 +1,197 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+func result(host string, at time.Time, fingerprint, scanErr string) *scanner.ScanResult {
+	r := &scanner.ScanResult{Hostname: host, Port: 443, ScannedAt: at, Success: scanErr == "", Error: scanErr}
+	if r.Success {
+		r.Certificate = &scanner.CertificateInfo{FingerprintSHA256: fingerprint, NotAfter: at.Add(90 * 24 * time.Hour)}
+		r.Chain = &scanner.ChainInfo{Valid: true}
+	}
+	return r
+}
+
+func TestStore_RecordAndRead(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, time.Hour, 100)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	start := time.Now().Add(-10 * time.Minute)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	results := []*scanner.ScanResult{
+		result("a.example.com", at(0), "aaaa", ""),
+		result("b.example.com", at(0), "bbbb", ""),
+		result("a.example.com", at(1), "aaaa", ""),
+		result("a.example.com", at(2), "", "connection refused"),
+		result("a.example.com", at(3), "", "connection refused"),
+		result("a.example.com", at(4), "cccc", ""),
+	}
+	for _, r := range results {
+		if err := s.Record(r); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	got, err := Read(dir, "a.example.com:443")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := []struct {
+		fingerprint string
+		err         string
+		first, last time.Time
+		scans       int
+	}{
+		{"aaaa", "", at(0), at(1), 2},
+		{"", "connection refused", at(2), at(3), 2},
+		{"cccc", "", at(4), at(4), 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Read() = %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		if e.Fingerprint != w.fingerprint || e.Error != w.err || e.Scans != w.scans ||
+			!e.ScannedAt.Equal(w.first) || !e.LastScannedAt.Equal(w.last) {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+		}
+	}
+
+	latest, err := Latest(dir)
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if len(latest) != 2 || latest["a.example.com:443"].Fingerprint != "cccc" || latest["b.example.com:443"].Fingerprint != "bbbb" {
+		t.Errorf("Latest() = %+v, want the last entry of both targets", latest)
+	}
+
+	// The history survives reopening, which compacts the file
+	s, err = Open(dir, time.Hour, 100)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	if got, _ := Read(dir, "a.example.com:443"); len(got) != 3 {
+		t.Errorf("after reopening, Read() = %d entries, want 3", len(got))
+	}
+	if got, _ := Read(dir, "b.example.com:443"); len(got) != 1 {
+		t.Errorf("Read() of another target = %d entries, want 1", len(got))
+	}
+}
+
+func TestOpen_Prunes(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 24*time.Hour, 100)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	now := time.Now()
+	fingerprints := []string{"old", "aaaa", "bbbb", "cccc"}
+	for i, fp := range fingerprints {
+		at := now.Add(-time.Duration(len(fingerprints)-i) * time.Hour)
+		if i == 0 {
+			at = now.Add(-48 * time.Hour)
+		}
+		if err := s.Record(result("a.example.com", at, fp, "")); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	s.Close()
+
+	// Older than the retention, then beyond the entries per target
+	s, err = Open(dir, 24*time.Hour, 2)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	got, err := Read(dir, "a.example.com:443")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Fingerprint != "bbbb" || got[1].Fingerprint != "cccc" {
+		t.Errorf("Read() = %+v, want the two latest entries", got)
+	}
+}
+
+func TestStore_RecordsAfterFailedCompaction(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(dir, time.Hour, 100)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+
+	// The compacted file can't be written
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	err = s.compact(time.Now())
+	s.mu.Unlock()
+	if err == nil {
+		t.Fatal("compact() succeeded without a state directory")
+	}
+	now := time.Now()
+	if err := s.Record(result("a.example.com", now, "aaaa", "")); err != nil {
+		t.Fatalf("Record() after a failed compaction error = %v", err)
+	}
+
+	// The next compaction picks the history up again
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	err = s.compact(time.Now())
+	s.mu.Unlock()
+	if err != nil {
+		t.Fatalf("compact() error = %v", err)
+	}
+	if err := s.Record(result("a.example.com", now.Add(time.Minute), "bbbb", "")); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	got, err := Read(dir, "a.example.com:443")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Fingerprint != "bbbb" {
+		t.Errorf("Read() = %+v, want the entry recorded after the compaction", got)
+	}
+}
+
+func TestRead_SkipsDamagedLines(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"target":"a.example.com:443","fingerprint":"aaaa","success":true,"scans":1}
+not json
+{"target":"a.example.com:443","fingerp`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Read(dir, "a.example.com:443")
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Fingerprint != "aaaa" {
+		t.Errorf("Read() = %+v, want the intact entry", got)
+	}
+
+	if got, err := Read(t.TempDir(), "a.example.com:443"); err != nil || len(got) != 0 {
+		t.Errorf("Read() without a history file = %v, %v, want no entries", got, err)
+	}
+}
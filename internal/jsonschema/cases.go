@@ -0,0 +1,135 @@
+package jsonschema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Case is a value for the field at Path that a validator enforcing the
+// schema must accept (Valid) or reject
+type Case struct {
+	Value any
+	Path  []string // Property names; "[]" is an array item, "*" a map entry
+	Valid bool
+}
+
+func (c Case) String() string {
+	verdict := "invalid"
+	if c.Valid {
+		verdict = "valid"
+	}
+	return fmt.Sprintf("%s=%v (%s)", strings.Join(c.Path, "."), c.Value, verdict)
+}
+
+// Cases derives boundary cases from the numeric, length, enum and duration
+// constraints of a schema: values at a bound are valid, values just beyond
+// it are not. Together with Set, they check that a Go validator and the
+// schema agree.
+func Cases(s *Schema) []Case {
+	var cases []Case
+	collectCases(s, nil, &cases)
+	return cases
+}
+
+func collectCases(s *Schema, path []string, cases *[]Case) {
+	add := func(valid, invalid any) {
+		p := append([]string(nil), path...)
+		*cases = append(*cases, Case{Path: p, Value: valid, Valid: true}, Case{Path: p, Value: invalid})
+	}
+
+	if s.Minimum != nil {
+		add(*s.Minimum, *s.Minimum-1)
+	}
+	if s.Maximum != nil {
+		add(*s.Maximum, *s.Maximum+1)
+	}
+	if s.MinLength != nil && *s.MinLength > 0 {
+		add(strings.Repeat("a", *s.MinLength), strings.Repeat("a", *s.MinLength-1))
+	}
+	if s.MaxLength != nil {
+		add(strings.Repeat("a", *s.MaxLength), strings.Repeat("a", *s.MaxLength+1))
+	}
+	if s.MinimumDuration != "" {
+		d, err := time.ParseDuration(s.MinimumDuration)
+		if err != nil {
+			panic(fmt.Sprintf("jsonschema: invalid minimum duration %q", s.MinimumDuration))
+		}
+		add(d, d-time.Nanosecond)
+	}
+	for _, value := range s.Enum {
+		*cases = append(*cases, Case{Path: append([]string(nil), path...), Value: value, Valid: true})
+	}
+	if len(s.Enum) > 0 {
+		*cases = append(*cases, Case{Path: append([]string(nil), path...), Value: "not-" + s.Enum[0]})
+	}
+
+	// Sorted so cases come in a stable order
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		collectCases(s.Properties[name], append(path, name), cases)
+	}
+	if s.Items != nil {
+		collectCases(s.Items, append(path, "[]"), cases)
+	}
+	if s.AdditionalProperties != nil {
+		collectCases(s.AdditionalProperties, append(path, "*"), cases)
+	}
+}
+
+// Set assigns value to the field at path in the struct v points to,
+// matching property names to mapstructure tags. "[]" selects the first
+// element of a slice and "*" an entry of a map, creating them if needed.
+func Set(v any, path []string, value any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return fmt.Errorf("jsonschema: Set requires a pointer, got %T", v)
+	}
+	return set(rv.Elem(), path, reflect.ValueOf(value))
+}
+
+func set(v reflect.Value, path []string, value reflect.Value) error {
+	if len(path) == 0 {
+		if !value.Type().ConvertibleTo(v.Type()) {
+			return fmt.Errorf("jsonschema: can't assign %s to %s", value.Type(), v.Type())
+		}
+		v.Set(value.Convert(v.Type()))
+		return nil
+	}
+
+	switch name := path[0]; {
+	case name == "[]" && v.Kind() == reflect.Slice:
+		if v.Len() == 0 {
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		}
+		return set(v.Index(0), path[1:], value)
+
+	case name == "*" && v.Kind() == reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		// Map entries aren't addressable, so the entry is set on a copy
+		entry := reflect.New(v.Type().Elem()).Elem()
+		if err := set(entry, path[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf("entry").Convert(v.Type().Key()), entry)
+		return nil
+
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("mapstructure"), ",")
+			if tag == name {
+				return set(v.Field(i), path[1:], value)
+			}
+		}
+	}
+
+	return fmt.Errorf("jsonschema: no field %q in %s", path[0], v.Type())
+}
@@ -0,0 +1,175 @@
+// Package jsonschema generates JSON Schemas for the agents' configuration
+// structs from their mapstructure tags.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft is the JSON Schema dialect of generated schemas
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// DurationPattern matches durations as accepted by time.ParseDuration,
+// without a sign
+const DurationPattern = `^(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h))+)$`
+
+// Schema is a JSON Schema document or subschema. Fields are in the order
+// they are rendered.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is a type name, or a list of type names
+	Type                 any                `json:"type,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Maximum              *int               `json:"maximum,omitempty"`
+	// MinimumDuration is the shortest duration allowed. JSON Schema can't
+	// compare duration strings, so validators only see it as an annotation.
+	MinimumDuration string    `json:"x-minimum-duration,omitempty"`
+	AnyOf           []*Schema `json:"anyOf,omitempty"`
+	// Closed disallows properties that aren't listed
+	Closed bool `json:"-"`
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Reflect builds the schema of a configuration struct. Properties are named
+// after the mapstructure tags of its fields; fields tagged "-" are skipped.
+// Objects built from structs don't allow unknown properties.
+func Reflect(v any) *Schema {
+	return reflectType(reflect.TypeOf(v))
+}
+
+func reflectType(t reflect.Type) *Schema {
+	if t == durationType {
+		return &Schema{Type: []string{"string", "integer"}, Pattern: DurationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return reflectType(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: reflectType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: reflectType(t.Elem())}
+	case reflect.Struct:
+		return reflectStruct(t)
+	default:
+		panic(fmt.Sprintf("jsonschema: unsupported type %s", t))
+	}
+}
+
+func reflectStruct(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), Closed: true}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		s.Properties[name] = reflectType(f.Type)
+	}
+	return s
+}
+
+// Field returns the property at a dotted path of property names. It panics
+// if the path doesn't exist, as schemas are built from static types.
+func (s *Schema) Field(path string) *Schema {
+	node := s
+	for _, name := range strings.Split(path, ".") {
+		child, ok := node.Properties[name]
+		if !ok {
+			panic(fmt.Sprintf("jsonschema: no property %q in path %q", name, path))
+		}
+		node = child
+	}
+	return node
+}
+
+// AllowAlternative lets every constrained scalar in the schema also match
+// alt, e.g. a reference that is only resolved when the config is loaded.
+// Plain strings already match anything alt would.
+func (s *Schema) AllowAlternative(alt *Schema) {
+	s.walk(func(n *Schema) {
+		if !n.isScalar() || n.isPlainString() {
+			return
+		}
+		// Annotations stay on the outer schema where editors show them
+		inner := *n
+		inner.Description, inner.Default, inner.MinimumDuration = "", nil, ""
+		*n = Schema{
+			Description:     n.Description,
+			Default:         n.Default,
+			MinimumDuration: n.MinimumDuration,
+			AnyOf:           []*Schema{&inner, alt},
+		}
+	})
+}
+
+// walk calls fn for every subschema, children before their parents
+func (s *Schema) walk(fn func(*Schema)) {
+	for _, child := range s.Properties {
+		child.walk(fn)
+	}
+	if s.Items != nil {
+		s.Items.walk(fn)
+	}
+	if s.AdditionalProperties != nil {
+		s.AdditionalProperties.walk(fn)
+	}
+	fn(s)
+}
+
+func (s *Schema) isScalar() bool {
+	return s.Type != nil && s.Type != "object" && s.Type != "array"
+}
+
+func (s *Schema) isPlainString() bool {
+	return s.Type == "string" && s.Pattern == "" && s.Enum == nil && s.MinLength == nil && s.MaxLength == nil
+}
+
+// MarshalJSON renders closed objects with "additionalProperties": false
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Closed {
+		return json.Marshal((*plain)(s))
+	}
+	return json.Marshal(struct {
+		*plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{(*plain)(s), false})
+}
+
+// Int returns a pointer to n, for use in numeric constraints
+func Int(n int) *int {
+	return &n
+}
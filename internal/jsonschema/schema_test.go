@@ -0,0 +1,178 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name      string                   `mapstructure:"name"`
+	Internal  string                   `mapstructure:"-"`
+	Tags      []string                 `mapstructure:"tags"`
+	Intervals map[string]time.Duration `mapstructure:"intervals"`
+	Nested    testNested               `mapstructure:"nested"`
+	Timeout   time.Duration            `mapstructure:"timeout"`
+	Port      int                      `mapstructure:"port"`
+	Enabled   bool                     `mapstructure:"enabled"`
+}
+
+type testNested struct {
+	Level string `mapstructure:"level"`
+}
+
+func TestReflect(t *testing.T) {
+	s := Reflect(testConfig{})
+
+	want := map[string]any{
+		"name":      "string",
+		"tags":      "array",
+		"intervals": "object",
+		"nested":    "object",
+		"timeout":   []string{"string", "integer"},
+		"port":      "integer",
+		"enabled":   "boolean",
+	}
+	if len(s.Properties) != len(want) {
+		t.Errorf("got %d properties, want %d", len(s.Properties), len(want))
+	}
+	for name, typ := range want {
+		p, ok := s.Properties[name]
+		if !ok {
+			t.Errorf("missing property %q", name)
+			continue
+		}
+		if !reflect.DeepEqual(p.Type, typ) {
+			t.Errorf("%s: type = %v, want %v", name, p.Type, typ)
+		}
+	}
+
+	if got := s.Field("tags").Items.Type; got != "string" {
+		t.Errorf("tags items type = %v, want string", got)
+	}
+	if got := s.Field("intervals").AdditionalProperties.Pattern; got != DurationPattern {
+		t.Errorf("intervals values pattern = %q, want the duration pattern", got)
+	}
+	if got := s.Field("nested.level").Type; got != "string" {
+		t.Errorf("nested.level type = %v, want string", got)
+	}
+
+	out, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(out), `"additionalProperties":false`) {
+		t.Errorf("expected structs to be closed, got %s", out)
+	}
+}
+
+func TestDurationPattern(t *testing.T) {
+	re := regexp.MustCompile(DurationPattern)
+	for _, d := range []string{"0", "10s", "1.5m", "1h30m", "500ms", "2us", ".5h"} {
+		if !re.MatchString(d) {
+			t.Errorf("%q should match", d)
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			t.Errorf("%q is not a Go duration: %v", d, err)
+		}
+	}
+	for _, d := range []string{"", "10", "-10s", "10 s", "1d", "s"} {
+		if re.MatchString(d) {
+			t.Errorf("%q should not match", d)
+		}
+	}
+}
+
+func TestAllowAlternative(t *testing.T) {
+	s := Reflect(testConfig{})
+	s.Field("port").Maximum = Int(65535)
+	s.Field("port").Default = 443
+	s.Field("name").MaxLength = Int(10)
+
+	alt := &Schema{Type: "string", Pattern: `^\$\{\w+\}$`}
+	s.AllowAlternative(alt)
+
+	port := s.Field("port")
+	if len(port.AnyOf) != 2 || port.AnyOf[1] != alt || port.Default != 443 {
+		t.Fatalf("port = %+v, want the alternative and the default kept", port)
+	}
+	if port.AnyOf[0].Type != "integer" || *port.AnyOf[0].Maximum != 65535 || port.AnyOf[0].Default != nil {
+		t.Errorf("port constraints = %+v", port.AnyOf[0])
+	}
+	if len(s.Field("name").AnyOf) != 2 {
+		t.Error("expected a constrained string to allow the alternative")
+	}
+	if got := s.Field("nested.level"); got.AnyOf != nil || got.Type != "string" {
+		t.Errorf("expected plain strings to be left alone, got %+v", got)
+	}
+	if got := s.Field("tags"); got.Type != "array" || got.Items.AnyOf != nil {
+		t.Errorf("expected arrays to be left alone, got %+v", got)
+	}
+}
+
+func TestCases(t *testing.T) {
+	s := Reflect(testConfig{})
+	s.Field("port").Minimum = Int(1)
+	s.Field("tags").Items.MaxLength = Int(3)
+	s.Field("intervals").AdditionalProperties.MinimumDuration = "10s"
+	s.Field("nested.level").Enum = []string{"low", "high"}
+
+	var got []string
+	for _, c := range Cases(s) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"intervals.*=10s (valid)",
+		"intervals.*=9.999999999s (invalid)",
+		"nested.level=low (valid)",
+		"nested.level=high (valid)",
+		"nested.level=not-low (invalid)",
+		"port=1 (valid)",
+		"port=0 (invalid)",
+		"tags.[]=aaa (valid)",
+		"tags.[]=aaaa (invalid)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Cases() = %v, want %v", got, want)
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := Reflect(testConfig{})
+	s.Field("port").Minimum = Int(1)
+	s.Field("tags").Items.MaxLength = Int(3)
+	s.Field("intervals").AdditionalProperties.MinimumDuration = "10s"
+	s.Field("nested.level").Enum = []string{"low"}
+
+	var cfg testConfig
+	for _, c := range Cases(s) {
+		if !c.Valid {
+			continue
+		}
+		if err := Set(&cfg, c.Path, c.Value); err != nil {
+			t.Fatalf("Set(%v) error = %v", c, err)
+		}
+	}
+
+	if cfg.Port != 1 || cfg.Nested.Level != "low" || !reflect.DeepEqual(cfg.Tags, []string{"aaa"}) {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if len(cfg.Intervals) != 1 {
+		t.Errorf("intervals = %v, want one entry", cfg.Intervals)
+	}
+	for _, d := range cfg.Intervals {
+		if d != 10*time.Second {
+			t.Errorf("interval = %v, want 10s", d)
+		}
+	}
+
+	if err := Set(&cfg, []string{"missing"}, 1); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+	if err := Set(cfg, []string{"port"}, 1); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+}
@@ -4,8 +4,15 @@ package metrics
 import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/certwatch-app/cw-agent/internal/outbox"
 )
 
+func init() {
+	// The outbox metrics are shared with the cert-manager agent
+	prometheus.MustRegister(outbox.Collectors()...)
+}
+
 var (
 	// Certificate metrics
 	CertDaysUntilExpiry = promauto.NewGaugeVec(
@@ -48,6 +55,26 @@ var (
 		[]string{"hostname", "port"},
 	)
 
+	CertTLSInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "certwatch",
+			Subsystem: "certificate",
+			Name:      "tls_info",
+			Help:      "Negotiated TLS version and key exchange group (always 1)",
+		},
+		[]string{"hostname", "port", "tls_version", "key_exchange"},
+	)
+
+	CertPostQuantum = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "certwatch",
+			Subsystem: "certificate",
+			Name:      "post_quantum",
+			Help:      "Whether the endpoint negotiated a post-quantum key exchange (1=yes, 0=no)",
+		},
+		[]string{"hostname", "port"},
+	)
+
 	// Scan metrics
 	ScanTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -70,6 +97,17 @@ var (
 		[]string{"hostname"},
 	)
 
+	ScanPhaseDurationSeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "certwatch",
+			Subsystem: "scan",
+			Name:      "phase_duration_seconds",
+			Help:      "Duration of each scan phase (dns, connect, tls_handshake) in seconds",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+		},
+		[]string{"hostname", "phase"},
+	)
+
 	// Sync metrics
 	SyncTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -167,6 +205,37 @@ var (
 			Help:      "Total uptime of the agent in seconds",
 		},
 	)
+
+	ConfigReloadTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "certwatch",
+			Subsystem: "agent",
+			Name:      "config_reloads_total",
+			Help:      "Total number of configuration reload attempts",
+		},
+		[]string{"status"}, // "success" or "failure"
+	)
+
+	// Alerting metrics
+	AlertsFiring = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "certwatch",
+			Subsystem: "alerting",
+			Name:      "alerts_firing",
+			Help:      "Number of firing alerts",
+		},
+		[]string{"rule", "severity"},
+	)
+
+	NotificationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "certwatch",
+			Subsystem: "alerting",
+			Name:      "notifications_total",
+			Help:      "Total number of alert notifications sent",
+		},
+		[]string{"notifier", "status"}, // status is "success" or "failure"
+	)
 )
 
 // RecordCertificateMetrics updates all certificate-related metrics for a single certificate.
@@ -187,6 +256,40 @@ func RecordCertificateMetrics(hostname, port string, daysUntilExpiry, expiryTime
 	}
 }
 
+// RecordTLSMetrics updates the negotiated protocol metrics for a single endpoint.
+// Previous label combinations for the endpoint are removed so a change of
+// TLS version or key exchange group doesn't leave stale series behind.
+func RecordTLSMetrics(hostname, port, tlsVersion, keyExchange string, postQuantum bool) {
+	CertTLSInfo.DeletePartialMatch(prometheus.Labels{"hostname": hostname, "port": port})
+	CertTLSInfo.WithLabelValues(hostname, port, tlsVersion, keyExchange).Set(1)
+
+	if postQuantum {
+		CertPostQuantum.WithLabelValues(hostname, port).Set(1)
+	} else {
+		CertPostQuantum.WithLabelValues(hostname, port).Set(0)
+	}
+}
+
+// DeleteCertificateMetrics removes the per-endpoint series of a target that
+// is no longer monitored.
+func DeleteCertificateMetrics(hostname, port string) {
+	labels := prometheus.Labels{"hostname": hostname, "port": port}
+	CertDaysUntilExpiry.Delete(labels)
+	CertExpiryTimestamp.Delete(labels)
+	CertValid.Delete(labels)
+	CertChainValid.Delete(labels)
+	CertPostQuantum.Delete(labels)
+	CertTLSInfo.DeletePartialMatch(labels)
+}
+
+// DeleteHostMetrics removes the per-hostname scan series of a hostname that
+// no monitored target uses any longer.
+func DeleteHostMetrics(hostname string) {
+	labels := prometheus.Labels{"hostname": hostname}
+	ScanDurationSeconds.Delete(labels)
+	ScanPhaseDurationSeconds.DeletePartialMatch(labels)
+}
+
 // RecordScanSuccess records a successful scan operation.
 func RecordScanSuccess(hostname string, duration float64) {
 	ScanTotal.WithLabelValues("success").Inc()
@@ -199,6 +302,24 @@ func RecordScanFailure(hostname string, duration float64) {
 	ScanDurationSeconds.WithLabelValues(hostname).Observe(duration)
 }
 
+// RecordScanPhases records the duration of each phase of a single scan.
+// Phases that did not run (zero duration) are skipped.
+func RecordScanPhases(hostname string, dns, connect, tlsHandshake float64) {
+	phases := []struct {
+		name     string
+		duration float64
+	}{
+		{"dns", dns},
+		{"connect", connect},
+		{"tls_handshake", tlsHandshake},
+	}
+	for _, p := range phases {
+		if p.duration > 0 {
+			ScanPhaseDurationSeconds.WithLabelValues(hostname, p.name).Observe(p.duration)
+		}
+	}
+}
+
 // RecordSyncSuccess records a successful sync operation.
 func RecordSyncSuccess(duration float64, created, updated, orphaned int) {
 	SyncTotal.WithLabelValues("success").Inc()
@@ -226,6 +347,15 @@ func RecordHeartbeatFailure(duration float64) {
 	HeartbeatDurationSeconds.Observe(duration)
 }
 
+// RecordConfigReload records a configuration reload attempt.
+func RecordConfigReload(success bool) {
+	if success {
+		ConfigReloadTotal.WithLabelValues("success").Inc()
+	} else {
+		ConfigReloadTotal.WithLabelValues("failure").Inc()
+	}
+}
+
 // SetAgentInfo sets the agent info metric.
 func SetAgentInfo(version, name, agentID string) {
 	AgentInfo.WithLabelValues(version, name, agentID).Set(1)
@@ -235,3 +365,20 @@ func SetAgentInfo(version, name, agentID string) {
 func SetCertificatesConfigured(count int) {
 	CertificatesConfigured.Set(float64(count))
 }
+
+// RecordNotification records an alert notification sent to a receiver.
+func RecordNotification(notifier string, success bool) {
+	if success {
+		NotificationsTotal.WithLabelValues(notifier, "success").Inc()
+	} else {
+		NotificationsTotal.WithLabelValues(notifier, "failure").Inc()
+	}
+}
+
+// SetAlertsFiring replaces the number of firing alerts, keyed by rule and severity.
+func SetAlertsFiring(counts map[[2]string]int) {
+	AlertsFiring.Reset()
+	for labels, count := range counts {
+		AlertsFiring.WithLabelValues(labels[0], labels[1]).Set(float64(count))
+	}
+}
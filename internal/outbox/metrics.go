@@ -0,0 +1,60 @@
+package outbox
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Outbox metrics. They aren't registered here, since the agents serve
+// metrics from different registries; see Collectors.
+var (
+	Messages = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "certwatch",
+		Subsystem: "outbox",
+		Name:      "messages",
+		Help:      "Payloads queued for delivery to the CertWatch API",
+	})
+
+	Bytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "certwatch",
+		Subsystem: "outbox",
+		Name:      "bytes",
+		Help:      "Size of the payloads queued for delivery",
+	})
+
+	OldestTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "certwatch",
+		Subsystem: "outbox",
+		Name:      "oldest_message_timestamp_seconds",
+		Help:      "Unix timestamp of the oldest queued payload (0 if none is queued)",
+	})
+
+	DeliveredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "certwatch",
+			Subsystem: "outbox",
+			Name:      "delivered_total",
+			Help:      "Queued payloads delivered to the CertWatch API",
+		},
+		[]string{"kind"},
+	)
+
+	DroppedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "certwatch",
+			Subsystem: "outbox",
+			Name:      "dropped_total",
+			Help:      "Queued payloads dropped without being delivered",
+		},
+		[]string{"kind", "reason"}, // reason: "overflow", "rejected", "superseded" or "corrupt"
+	)
+
+	RetriesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "certwatch",
+		Subsystem: "outbox",
+		Name:      "retries_total",
+		Help:      "Failed deliveries that were scheduled for a retry",
+	})
+)
+
+// Collectors returns the outbox metrics for registration
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{Messages, Bytes, OldestTimestamp, DeliveredTotal, DroppedTotal, RetriesTotal}
+}
@@ -0,0 +1,269 @@
+// Package outbox queues API payloads on disk, so payloads that can't be
+// delivered while the API is unreachable are sent later, in order, also
+// after a restart.
+//
+// Every message is a file in the outbox directory named after its sequence
+// number. Files are written to a temporary name first, so a crash never
+// leaves a partial message behind.
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirName is the name of the outbox directory in the state directory
+const DirName = ".certwatch-outbox"
+
+// Reasons a message is dropped without being delivered
+const (
+	DropOverflow   = "overflow"   // The outbox was full
+	DropRejected   = "rejected"   // The API rejected the payload
+	DropSuperseded = "superseded" // A newer payload of the same kind replaced it
+	DropCorrupt    = "corrupt"    // The message file couldn't be read
+)
+
+// Message is a queued API request
+type Message struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Kind      string          `json:"kind"` // E.g. "sync" or "heartbeat"
+	Path      string          `json:"path"` // API path the body is posted to
+	Body      json.RawMessage `json:"body"`
+
+	seq  uint64
+	size int64
+}
+
+// Seq returns the sequence number of the message; later messages have
+// higher numbers
+func (m *Message) Seq() uint64 {
+	return m.seq
+}
+
+// entry is a queued message without its body, which stays on disk
+type entry struct {
+	createdAt time.Time
+	kind      string
+	seq       uint64
+	size      int64
+}
+
+// Outbox is a bounded on-disk FIFO queue of messages
+type Outbox struct {
+	dir      string
+	entries  []entry // Oldest first
+	maxBytes int64
+	bytes    int64
+	nextSeq  uint64
+	mu       sync.Mutex
+}
+
+// Open opens the outbox in dir, creating the directory if needed, with the
+// messages queued before a restart. Once the messages take up more than
+// maxBytes, the oldest are dropped.
+func Open(dir string, maxBytes int64) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox directory: %w", err)
+	}
+
+	o := &Outbox{dir: dir, maxBytes: maxBytes, nextSeq: 1}
+	for _, f := range files {
+		name := f.Name()
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, ".json"), 10, 64)
+		if err != nil || !strings.HasSuffix(name, ".json") {
+			// Left behind by a crash while writing
+			if strings.HasPrefix(name, ".tmp-") {
+				os.Remove(filepath.Join(dir, name))
+			}
+			continue
+		}
+		m, err := o.read(seq)
+		if err != nil {
+			// Unreadable messages can never be delivered
+			os.Remove(filepath.Join(dir, name))
+			DroppedTotal.WithLabelValues("", DropCorrupt).Inc()
+			continue
+		}
+		o.entries = append(o.entries, entry{createdAt: m.CreatedAt, kind: m.Kind, seq: seq, size: m.size})
+		o.bytes += m.size
+		o.nextSeq = max(o.nextSeq, seq+1)
+	}
+	sort.Slice(o.entries, func(i, j int) bool { return o.entries[i].seq < o.entries[j].seq })
+
+	o.updateMetrics()
+	return o, nil
+}
+
+// Push queues a message. With supersede, queued messages of the same kind
+// are dropped, for payloads that only report the current status. The
+// oldest messages are dropped to make room if needed.
+func (o *Outbox) Push(kind, path string, body []byte, supersede bool) (*Message, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	m := &Message{CreatedAt: time.Now().UTC(), Kind: kind, Path: path, Body: body, seq: o.nextSeq}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox message: %w", err)
+	}
+	m.size = int64(len(data))
+	if m.size > o.maxBytes {
+		DroppedTotal.WithLabelValues(kind, DropOverflow).Inc()
+		return nil, fmt.Errorf("%s payload of %d bytes exceeds the outbox size of %d bytes", kind, m.size, o.maxBytes)
+	}
+
+	if err := o.write(m.seq, data); err != nil {
+		return nil, err
+	}
+	o.nextSeq++
+
+	if supersede {
+		for i := len(o.entries) - 1; i >= 0; i-- {
+			if o.entries[i].kind == kind {
+				o.remove(i, DropSuperseded)
+			}
+		}
+	}
+	for len(o.entries) > 0 && o.bytes+m.size > o.maxBytes {
+		o.remove(0, DropOverflow)
+	}
+	o.entries = append(o.entries, entry{createdAt: m.CreatedAt, kind: kind, seq: m.seq, size: m.size})
+	o.bytes += m.size
+
+	o.updateMetrics()
+	return m, nil
+}
+
+// Peek returns the oldest message, or nil if the outbox is empty
+func (o *Outbox) Peek() *Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for len(o.entries) > 0 {
+		m, err := o.read(o.entries[0].seq)
+		if err == nil {
+			return m
+		}
+		// Removed or damaged behind our back; skip it
+		o.remove(0, DropCorrupt)
+		o.updateMetrics()
+	}
+	return nil
+}
+
+// Delivered removes a message the API accepted
+func (o *Outbox) Delivered(m *Message) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if i := o.index(m.seq); i >= 0 {
+		o.entries = append(o.entries[:i], o.entries[i+1:]...)
+		o.bytes -= m.size
+		os.Remove(o.path(m.seq))
+		DeliveredTotal.WithLabelValues(m.Kind).Inc()
+		o.updateMetrics()
+	}
+}
+
+// Drop removes a message that can't be delivered
+func (o *Outbox) Drop(m *Message, reason string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if i := o.index(m.seq); i >= 0 {
+		o.remove(i, reason)
+		o.updateMetrics()
+	}
+}
+
+// Len returns the number of queued messages
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// Bytes returns the size of the queued messages
+func (o *Outbox) Bytes() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.bytes
+}
+
+// remove drops the entry at index i. The caller must hold o.mu.
+func (o *Outbox) remove(i int, reason string) {
+	e := o.entries[i]
+	o.entries = append(o.entries[:i], o.entries[i+1:]...)
+	o.bytes -= e.size
+	os.Remove(o.path(e.seq))
+	DroppedTotal.WithLabelValues(e.kind, reason).Inc()
+}
+
+// index returns the index of the entry with seq, or -1 if it isn't queued.
+// The caller must hold o.mu.
+func (o *Outbox) index(seq uint64) int {
+	i := sort.Search(len(o.entries), func(i int) bool { return o.entries[i].seq >= seq })
+	if i < len(o.entries) && o.entries[i].seq == seq {
+		return i
+	}
+	return -1
+}
+
+func (o *Outbox) path(seq uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d.json", seq))
+}
+
+func (o *Outbox) read(seq uint64) (*Message, error) {
+	data, err := os.ReadFile(o.path(seq))
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	m.seq = seq
+	m.size = int64(len(data))
+	return m, nil
+}
+
+func (o *Outbox) write(seq uint64, data []byte) error {
+	tmp, err := os.CreateTemp(o.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write outbox message: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), o.path(seq))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write outbox message: %w", err)
+	}
+	return nil
+}
+
+// updateMetrics publishes the queue size. The caller must hold o.mu.
+func (o *Outbox) updateMetrics() {
+	Messages.Set(float64(len(o.entries)))
+	Bytes.Set(float64(o.bytes))
+	if len(o.entries) == 0 {
+		OldestTimestamp.Set(0)
+	} else {
+		OldestTimestamp.Set(float64(o.entries[0].createdAt.Unix()))
+	}
+}
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func push(t *testing.T, o *Outbox, kind, body string, supersede bool) *Message {
+	t.Helper()
+	m, err := o.Push(kind, "/api/v1/"+kind, []byte(body), supersede)
+	if err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	return m
+}
+
+// drain delivers every queued message and returns their bodies in order
+func drain(o *Outbox) []string {
+	var bodies []string
+	for m := o.Peek(); m != nil; m = o.Peek() {
+		bodies = append(bodies, string(m.Body))
+		o.Delivered(m)
+	}
+	return bodies
+}
+
+func TestOutbox_SurvivesRestart(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), DirName)
+	o, err := Open(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	push(t, o, "sync", `{"n":1}`, false)
+	push(t, o, "events", `{"n":2}`, false)
+
+	// A crash while writing leaves a temporary file behind
+	if err := os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte(`{"n":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err = Open(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if o.Len() != 2 {
+		t.Fatalf("Len() = %d after reopening, want 2", o.Len())
+	}
+	push(t, o, "sync", `{"n":3}`, false)
+
+	got := drain(o)
+	want := []string{`{"n":1}`, `{"n":2}`, `{"n":3}`}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+	if o.Len() != 0 || o.Bytes() != 0 {
+		t.Errorf("Len() = %d, Bytes() = %d after delivering everything", o.Len(), o.Bytes())
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d files left in the outbox directory", len(files))
+	}
+}
+
+func TestOutbox_Supersede(t *testing.T) {
+	o, err := Open(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	push(t, o, "heartbeat", `{"n":1}`, true)
+	push(t, o, "sync", `{"n":2}`, false)
+	push(t, o, "heartbeat", `{"n":3}`, true)
+
+	got := drain(o)
+	if strings.Join(got, ",") != `{"n":2},{"n":3}` {
+		t.Errorf("delivered %v, want the sync and the latest heartbeat", got)
+	}
+}
+
+func TestOutbox_DropsOldestWhenFull(t *testing.T) {
+	o, err := Open(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	m := push(t, o, "sync", `{"n":1}`, false)
+
+	// Room for two messages of this size
+	o.maxBytes = 2*m.size + 1
+	push(t, o, "sync", `{"n":2}`, false)
+	push(t, o, "sync", `{"n":3}`, false)
+
+	got := drain(o)
+	if strings.Join(got, ",") != `{"n":2},{"n":3}` {
+		t.Errorf("delivered %v, want the two latest messages", got)
+	}
+
+	if _, err := o.Push("sync", "/api/v1/sync", []byte(strings.Repeat("x", int(o.maxBytes))), false); err == nil {
+		t.Error("Push() of a message larger than the outbox succeeded")
+	}
+}
+
+func TestOutbox_Drop(t *testing.T) {
+	o, err := Open(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	m := push(t, o, "events", `{"n":1}`, false)
+	push(t, o, "events", `{"n":2}`, false)
+
+	o.Drop(m, DropRejected)
+	o.Drop(m, DropRejected) // Already gone
+	if got := drain(o); len(got) != 1 || got[0] != `{"n":2}` {
+		t.Errorf("delivered %v, want the message that wasn't dropped", got)
+	}
+}
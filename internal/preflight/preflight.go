@@ -0,0 +1,190 @@
+// Package preflight checks that a configuration works against the real
+// environment: targets resolve and complete a TLS handshake, the API accepts
+// the key, and agent state can be persisted.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	gosync "sync"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/state"
+	"github.com/certwatch-app/cw-agent/internal/sync"
+)
+
+// Check names
+const (
+	CheckAPIKey    = "api key"
+	CheckStateDir  = "state dir"
+	CheckDNS       = "dns"
+	CheckHandshake = "handshake"
+)
+
+// Check is the outcome of a single online check
+type Check struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	Detail string `json:"detail,omitempty"`
+	Passed bool   `json:"passed"`
+}
+
+// Resolver looks up the addresses of a host; *net.Resolver implements it
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Failed returns the number of checks that did not pass
+func Failed(checks []Check) int {
+	n := 0
+	for i := range checks {
+		if !checks[i].Passed {
+			n++
+		}
+	}
+	return n
+}
+
+// APIKey checks that the API accepts the configured key
+func APIKey(ctx context.Context, client *sync.Client, endpoint string) Check {
+	check := Check{Name: CheckAPIKey, Target: endpoint}
+	if err := client.VerifyAPIKey(ctx); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	check.Passed = true
+	check.Detail = "authenticated"
+	return check
+}
+
+// StateDir checks that the agent can persist its state
+func StateDir(m *state.Manager) Check {
+	check := Check{Name: CheckStateDir, Target: m.FilePath()}
+	if err := m.CheckWritable(); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	check.Passed = true
+	check.Detail = "writable"
+	return check
+}
+
+// Targets resolves every hostname once, then attempts a TLS handshake with
+// each target whose hostname resolved. Checks are returned in configuration
+// order, each hostname's DNS check before its handshakes.
+func Targets(ctx context.Context, certs []config.CertificateConfig, resolver Resolver,
+	s *scanner.Scanner, timeout time.Duration, concurrency int) []Check {
+	var hosts []string
+	seen := make(map[string]bool)
+	for i := range certs {
+		if !seen[certs[i].Hostname] {
+			seen[certs[i].Hostname] = true
+			hosts = append(hosts, certs[i].Hostname)
+		}
+	}
+
+	dns := resolveAll(ctx, hosts, resolver, timeout, concurrency)
+
+	var reachable []config.CertificateConfig
+	for i := range certs {
+		if dns[certs[i].Hostname].Passed {
+			reachable = append(reachable, certs[i])
+		}
+	}
+	results := make(map[string]scanner.ScanResult, len(reachable))
+	for _, r := range s.ScanAll(ctx, reachable) {
+		results[fmt.Sprintf("%s:%d", r.Hostname, r.Port)] = r
+	}
+
+	checks := make([]Check, 0, len(hosts)+len(certs))
+	for _, host := range hosts {
+		checks = append(checks, dns[host])
+		for i := range certs {
+			if certs[i].Hostname != host {
+				continue
+			}
+			key := certs[i].GetHostPort()
+			check := Check{Name: CheckHandshake, Target: key}
+			if r, ok := results[key]; ok {
+				check.Passed, check.Detail = handshakeResult(&r)
+			} else {
+				check.Detail = "skipped, hostname did not resolve"
+			}
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// resolveAll looks up hostnames in parallel, bounded by concurrency.
+// IP addresses pass without a lookup.
+func resolveAll(ctx context.Context, hosts []string, resolver Resolver,
+	timeout time.Duration, concurrency int) map[string]Check {
+	checks := make(map[string]Check, len(hosts))
+	var mu gosync.Mutex
+	var wg gosync.WaitGroup
+	sem := make(chan struct{}, max(concurrency, 1))
+
+	for _, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			check := resolve(ctx, host, resolver, timeout)
+			mu.Lock()
+			checks[host] = check
+			mu.Unlock()
+		}(host)
+	}
+	wg.Wait()
+	return checks
+}
+
+func resolve(ctx context.Context, host string, resolver Resolver, timeout time.Duration) Check {
+	check := Check{Name: CheckDNS, Target: host}
+	if net.ParseIP(host) != nil {
+		check.Passed = true
+		check.Detail = "IP address"
+		return check
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	check.Passed = true
+	check.Detail = fmt.Sprintf("%d addresses", len(addrs))
+	if len(addrs) == 1 {
+		check.Detail = addrs[0]
+	}
+	return check
+}
+
+// handshakeResult summarizes a scan; a pin mismatch fails the check since
+// it usually means the pins in the configuration are wrong
+func handshakeResult(r *scanner.ScanResult) (bool, string) {
+	if !r.Success {
+		return false, r.Error
+	}
+	if r.Chain != nil {
+		for _, issue := range r.Chain.Issues {
+			if issue.Type == scanner.IssuePinMismatch {
+				return false, issue.Message
+			}
+		}
+	}
+	return true, fmt.Sprintf("%s, expires in %d days, %s",
+		r.TLSVersion, r.Certificate.DaysUntilExpiry, r.Timings.Total.Round(time.Millisecond))
+}
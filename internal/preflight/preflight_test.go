@@ -0,0 +1,137 @@
+package preflight
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/state"
+	"github.com/certwatch-app/cw-agent/internal/sync"
+)
+
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, ok := r[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// closedPort returns a local port nothing is listening on
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	return port
+}
+
+func TestTargets(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	tlsPort, err := strconv.Atoi(srv.URL[strings.LastIndex(srv.URL, ":")+1:])
+	if err != nil {
+		t.Fatalf("failed to parse server port: %v", err)
+	}
+	closed := closedPort(t)
+
+	certs := []config.CertificateConfig{
+		{Hostname: "127.0.0.1", Port: tlsPort},
+		{Hostname: "localhost", Port: closed},
+		{Hostname: "missing.example.com", Port: 443},
+		{Hostname: "127.0.0.1", Port: closed},
+	}
+	resolver := fakeResolver{"localhost": {"127.0.0.1"}}
+	s := scanner.New(2*time.Second, 2, zap.NewNop())
+
+	checks := Targets(context.Background(), certs, resolver, s, 2*time.Second, 2)
+
+	want := []struct {
+		name   string
+		target string
+		passed bool
+	}{
+		{CheckDNS, "127.0.0.1", true},
+		{CheckHandshake, "127.0.0.1:" + strconv.Itoa(tlsPort), true},
+		{CheckHandshake, "127.0.0.1:" + strconv.Itoa(closed), false},
+		{CheckDNS, "localhost", true},
+		{CheckHandshake, "localhost:" + strconv.Itoa(closed), false},
+		{CheckDNS, "missing.example.com", false},
+		{CheckHandshake, "missing.example.com:443", false},
+	}
+	if len(checks) != len(want) {
+		t.Fatalf("got %d checks, want %d: %+v", len(checks), len(want), checks)
+	}
+	for i, w := range want {
+		c := checks[i]
+		if c.Name != w.name || c.Target != w.target || c.Passed != w.passed {
+			t.Errorf("check %d = %+v, want %s %s passed=%v", i, c, w.name, w.target, w.passed)
+		}
+	}
+	if d := checks[6].Detail; !strings.Contains(d, "skipped") {
+		t.Errorf("handshake detail = %q, want it skipped after a failed lookup", d)
+	}
+	if got := Failed(checks); got != 4 {
+		t.Errorf("Failed() = %d, want 4", got)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		passed bool
+	}{
+		{"accepted", "", http.StatusOK, true},
+		{"unknown agent", `{"success":false,"error":{"code":"AGENT_NOT_FOUND","message":"agent not found"}}`, http.StatusNotFound, true},
+		{"wrong endpoint", "404 page not found", http.StatusNotFound, false},
+		{"rejected", "", http.StatusUnauthorized, false},
+		{"forbidden", "", http.StatusForbidden, false},
+		{"server error", "", http.StatusBadGateway, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			stateManager := state.NewManagerWithStateDir(t.TempDir())
+			client := sync.NewWithConfig(&sync.ClientConfig{Endpoint: srv.URL, APIKey: "cw_test", Timeout: time.Second},
+				"agent", zap.NewNop(), stateManager)
+
+			c := APIKey(context.Background(), client, srv.URL)
+			if c.Passed != tt.passed {
+				t.Errorf("APIKey() = %+v, want passed=%v", c, tt.passed)
+			}
+		})
+	}
+}
+
+func TestStateDir(t *testing.T) {
+	if c := StateDir(state.NewManagerWithStateDir(t.TempDir())); !c.Passed {
+		t.Errorf("StateDir() = %+v, want a writable directory to pass", c)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if c := StateDir(state.NewManagerWithStateDir(missing)); c.Passed {
+		t.Error("expected a missing directory to fail")
+	}
+}
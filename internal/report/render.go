@@ -0,0 +1,221 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report formats
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+)
+
+// Formats lists the accepted report formats
+var Formats = []string{FormatHTML, FormatMarkdown, FormatCSV}
+
+// Write renders the report in one of the Formats
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatHTML:
+		return r.WriteHTML(w)
+	case FormatMarkdown:
+		return r.WriteMarkdown(w)
+	case FormatCSV:
+		return r.WriteCSV(w)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// csvHeader names the columns of CSV reports
+var csvHeader = []string{
+	"group", "target", "owner", "tags", "subject", "issuer", "key_type", "not_after", "days_left",
+	"tls_version", "status", "chain_issues", "error", "notes", "last_scanned_at",
+}
+
+// WriteCSV writes a row per target and group. A target with several tags
+// is listed once per tag when grouped by tag.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, g := range r.Groups {
+		for i := range g.Rows {
+			row := &g.Rows[i]
+			record := []string{
+				g.Name, row.Target, row.Owner, strings.Join(row.Tags, ";"), row.Subject, row.Issuer, row.KeyType,
+				"", "", row.TLSVersion, row.Status, issueList(row), row.Error, row.Notes, "",
+			}
+			if row.NotAfter != nil {
+				record[7] = row.NotAfter.UTC().Format(time.RFC3339)
+				record[8] = strconv.Itoa(row.DaysLeft)
+			}
+			if !row.ScannedAt.IsZero() {
+				record[14] = row.ScannedAt.UTC().Format(time.RFC3339)
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteMarkdown writes a table per group, with chain issues in bold
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Certificate Inventory\n\n")
+	fmt.Fprintf(&b, "Generated %s from the %s, grouped by %s.\n\n",
+		r.GeneratedAt.UTC().Format("2006-01-02 15:04 MST"), sourceName(r.Source), r.GroupBy)
+
+	b.WriteString("| Targets | Expired | Expiring within 30 days | With chain issues | Failed or not scanned |\n")
+	b.WriteString("|---------|---------|-------------------------|-------------------|-----------------------|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d |\n",
+		r.Summary.Targets, r.Summary.Expired, r.Summary.Expiring, r.Summary.Issues, r.Summary.Failed)
+
+	for _, g := range r.Groups {
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", markdownEscape(g.Name), len(g.Rows))
+		b.WriteString("| Target | Owner | Tags | Subject | Issuer | Key | Expires | Days Left | Status | Chain Issues |\n")
+		b.WriteString("|--------|-------|------|---------|--------|-----|---------|-----------|--------|--------------|\n")
+		for i := range g.Rows {
+			row := &g.Rows[i]
+			expires, days := "-", "-"
+			if row.NotAfter != nil {
+				expires = row.NotAfter.UTC().Format(time.DateOnly)
+				days = strconv.Itoa(row.DaysLeft)
+			}
+			issues := "-"
+			if len(row.Issues) > 0 {
+				list := make([]string, 0, len(row.Issues))
+				for _, issue := range row.Issues {
+					list = append(list, fmt.Sprintf("**%s** (%s): %s",
+						markdownEscape(issue.Type), issue.Severity, markdownEscape(issue.Message)))
+				}
+				issues = strings.Join(list, "<br>")
+			} else if row.Error != "" {
+				issues = markdownEscape(row.Error)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+				markdownEscape(row.Target), orDash(markdownEscape(row.Owner)), orDash(markdownEscape(strings.Join(row.Tags, ", "))),
+				orDash(markdownEscape(row.Subject)), orDash(markdownEscape(row.Issuer)), orDash(row.KeyType),
+				expires, days, statusLabel(row.Status), issues)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHTML writes a standalone HTML page with a table per group. Expired
+// and failed targets, and targets with chain issues, are highlighted.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.UTC().Format(time.DateOnly)
+	},
+	"datetime": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+	"join":     strings.Join,
+	"or":       orDash,
+	"source":   sourceName,
+	"status":   statusLabel,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Certificate Inventory</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #1f2328; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2rem; font-size: 0.9rem; }
+th, td { border: 1px solid #d0d7de; padding: 0.4rem 0.6rem; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+.summary td { font-size: 1.2rem; text-align: center; }
+tr.expired, tr.failed, tr.unknown { background: #ffebe9; }
+tr.issues { background: #fff8c5; }
+.soon { color: #9a6700; font-weight: bold; }
+.issue { display: block; }
+.high { color: #cf222e; font-weight: bold; }
+.medium { color: #9a6700; font-weight: bold; }
+.muted { color: #656d76; }
+</style>
+</head>
+<body>
+<h1>Certificate Inventory</h1>
+<p class="muted">Generated {{datetime .GeneratedAt}} from the {{source .Source}}, grouped by {{.GroupBy}}.</p>
+<table class="summary">
+<tr><th>Targets</th><th>Expired</th><th>Expiring within 30 days</th><th>With chain issues</th><th>Failed or not scanned</th></tr>
+<tr><td>{{.Summary.Targets}}</td><td>{{.Summary.Expired}}</td><td>{{.Summary.Expiring}}</td><td>{{.Summary.Issues}}</td><td>{{.Summary.Failed}}</td></tr>
+</table>
+{{range .Groups}}
+<h2>{{.Name}} <span class="muted">({{len .Rows}})</span></h2>
+<table>
+<tr><th>Target</th><th>Owner</th><th>Tags</th><th>Subject</th><th>Issuer</th><th>Key</th><th>Expires</th><th>Days Left</th><th>Status</th><th>Chain Issues</th></tr>
+{{range .Rows}}<tr class="{{.Status}}">
+<td>{{.Target}}</td><td>{{or .Owner}}</td><td>{{or (join .Tags ", ")}}</td><td>{{or .Subject}}</td><td>{{or .Issuer}}</td><td>{{or .KeyType}}</td>
+<td>{{date .NotAfter}}</td><td{{if and .NotAfter (le .DaysLeft 30)}} class="soon"{{end}}>{{if .NotAfter}}{{.DaysLeft}}{{else}}-{{end}}</td>
+<td>{{status .Status}}</td>
+<td>{{range .Issues}}<span class="issue"><span class="{{.Severity}}">{{.Type}}</span> ({{.Severity}}): {{.Message}}</span>{{else}}{{or .Error}}{{end}}</td>
+</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// issueList lists the chain issues of a row by type and severity
+func issueList(row *Row) string {
+	list := make([]string, 0, len(row.Issues))
+	for _, issue := range row.Issues {
+		list = append(list, fmt.Sprintf("%s (%s)", issue.Type, issue.Severity))
+	}
+	return strings.Join(list, "; ")
+}
+
+func statusLabel(status string) string {
+	switch status {
+	case StatusOK:
+		return "OK"
+	case StatusIssues:
+		return "Chain issues"
+	case StatusExpired:
+		return "Expired"
+	case StatusFailed:
+		return "Scan failed"
+	default:
+		return "Not scanned"
+	}
+}
+
+func sourceName(source string) string {
+	if source == SourceHistory {
+		return "scan history"
+	}
+	return "current scan"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// markdownEscape keeps a value from breaking out of a table cell or being
+// rendered as HTML
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "*", `\*`, "_", `\_`, "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,325 @@
+// Package report builds certificate inventory reports for audits, from a
+// fresh scan or from the scan history, and renders them as HTML, Markdown
+// or CSV.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/history"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// Ways to group the rows of a report
+const (
+	GroupByExpiry = "expiry"
+	GroupByIssuer = "issuer"
+	GroupByTag    = "tag"
+)
+
+// GroupBys lists the accepted groupings
+var GroupBys = []string{GroupByExpiry, GroupByIssuer, GroupByTag}
+
+// Sources of the rows of a report
+const (
+	SourceScan    = "scan"
+	SourceHistory = "history"
+)
+
+// Statuses of a row
+const (
+	StatusOK      = "ok"
+	StatusIssues  = "issues"  // The chain has issues
+	StatusExpired = "expired" // The certificate has expired
+	StatusFailed  = "failed"  // The last scan failed
+	StatusUnknown = "unknown" // The target was never scanned
+)
+
+// Names of the groups of rows without a value to group by
+const (
+	untagged      = "(untagged)"
+	unknownIssuer = "(unknown)"
+)
+
+// expiryBuckets groups certificates by the days until they expire; the
+// first bucket whose limit a certificate is within applies
+var expiryBuckets = []struct {
+	name string
+	days int
+}{
+	{"Within 7 days", 7},
+	{"Within 30 days", 30},
+	{"Within 90 days", 90},
+}
+
+// Names of the expiry groups outside the buckets
+const (
+	bucketExpired = "Expired"
+	bucketLater   = "Later"
+	bucketUnknown = "Unknown"
+)
+
+// Row is a target in the inventory
+// Fields are ordered for optimal memory alignment
+type Row struct {
+	NotAfter    *time.Time
+	Tags        []string
+	Issues      []scanner.ChainIssue
+	Target      string // hostname:port
+	Owner       string
+	Notes       string
+	Subject     string
+	Issuer      string
+	KeyType     string
+	Fingerprint string
+	TLSVersion  string
+	Error       string
+	Status      string
+	ScannedAt   time.Time
+	DaysLeft    int // Until NotAfter, negative once expired
+}
+
+// Group is a set of rows sharing a tag, issuer or expiry bucket
+type Group struct {
+	Name string
+	Rows []Row
+}
+
+// Summary counts the rows of a report by what needs attention
+type Summary struct {
+	Targets  int
+	Expired  int
+	Expiring int // Within 30 days
+	Issues   int // Targets with chain issues
+	Failed   int // Targets whose last scan failed or that were never scanned
+}
+
+// Report is a certificate inventory
+// Fields are ordered for optimal memory alignment
+type Report struct {
+	GeneratedAt time.Time
+	Groups      []Group
+	Source      string // SourceScan or SourceHistory
+	GroupBy     string
+	Summary     Summary
+}
+
+// FromScan returns the rows of the targets with their scan results
+func FromScan(certs []config.CertificateConfig, results []scanner.ScanResult, now time.Time) []Row {
+	byTarget := make(map[string]*scanner.ScanResult, len(results))
+	for i := range results {
+		byTarget[fmt.Sprintf("%s:%d", results[i].Hostname, results[i].Port)] = &results[i]
+	}
+
+	rows := make([]Row, 0, len(certs))
+	for i := range certs {
+		row := newRow(&certs[i])
+		if r, ok := byTarget[row.Target]; ok {
+			row.ScannedAt = r.ScannedAt
+			row.TLSVersion = r.TLSVersion
+			row.Error = r.Error
+			if cert := r.Certificate; cert != nil {
+				notAfter := cert.NotAfter
+				row.NotAfter = &notAfter
+				row.Subject = cert.Subject
+				row.Issuer = cert.Issuer
+				row.KeyType = cert.KeyType
+				row.Fingerprint = cert.FingerprintSHA256
+			}
+			if r.Chain != nil {
+				row.Issues = r.Chain.Issues
+			}
+			row.Status = status(&row, r.Success, now)
+		}
+		row.setDaysLeft(now)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// FromHistory returns the rows of the targets with their latest results
+// in the scan history in dir
+func FromHistory(certs []config.CertificateConfig, dir string, now time.Time) ([]Row, error) {
+	latest, err := history.Latest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, 0, len(certs))
+	for i := range certs {
+		row := newRow(&certs[i])
+		if e, ok := latest[row.Target]; ok {
+			row.NotAfter = e.NotAfter
+			row.Issues = e.Issues
+			row.Subject = e.Subject
+			row.Issuer = e.Issuer
+			row.KeyType = e.KeyType
+			row.Fingerprint = e.Fingerprint
+			row.TLSVersion = e.TLSVersion
+			row.Error = e.Error
+			row.ScannedAt = e.LastScannedAt
+			row.Status = status(&row, e.Success, now)
+		}
+		row.setDaysLeft(now)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func newRow(cert *config.CertificateConfig) Row {
+	return Row{
+		Target: fmt.Sprintf("%s:%d", cert.Hostname, cert.Port),
+		Tags:   cert.Tags,
+		Owner:  cert.Owner,
+		Notes:  cert.Notes,
+		Status: StatusUnknown,
+	}
+}
+
+func status(row *Row, success bool, now time.Time) string {
+	switch {
+	case !success:
+		return StatusFailed
+	case row.NotAfter != nil && !row.NotAfter.After(now):
+		return StatusExpired
+	case len(row.Issues) > 0:
+		return StatusIssues
+	default:
+		return StatusOK
+	}
+}
+
+// setDaysLeft computes the days until expiry the same way as scans do
+func (r *Row) setDaysLeft(now time.Time) {
+	if r.NotAfter != nil {
+		r.DaysLeft = int(r.NotAfter.Sub(now).Hours() / 24)
+	}
+}
+
+// New groups the rows into a report
+func New(rows []Row, groupBy, source string, now time.Time) (*Report, error) {
+	r := &Report{GeneratedAt: now, Source: source, GroupBy: groupBy}
+	switch groupBy {
+	case GroupByExpiry:
+		r.Groups = groupRows(rows, expiryBucket, bucketOrder)
+	case GroupByIssuer:
+		r.Groups = groupRows(rows, func(row *Row) []string {
+			if row.Issuer == "" {
+				return []string{unknownIssuer}
+			}
+			return []string{row.Issuer}
+		}, lastGroup(unknownIssuer))
+	case GroupByTag:
+		r.Groups = groupRows(rows, func(row *Row) []string {
+			if len(row.Tags) == 0 {
+				return []string{untagged}
+			}
+			return row.Tags
+		}, lastGroup(untagged))
+	default:
+		return nil, fmt.Errorf("unknown grouping %q", groupBy)
+	}
+
+	r.Summary.Targets = len(rows)
+	for i := range rows {
+		row := &rows[i]
+		switch {
+		case row.Status == StatusFailed || row.Status == StatusUnknown:
+			r.Summary.Failed++
+		case row.Status == StatusExpired:
+			r.Summary.Expired++
+		case row.NotAfter != nil && row.DaysLeft <= 30:
+			r.Summary.Expiring++
+		}
+		if len(row.Issues) > 0 {
+			r.Summary.Issues++
+		}
+	}
+	return r, nil
+}
+
+// groupRows puts every row in the groups named by key, ordered by less,
+// with the rows of each group ordered by expiry
+func groupRows(rows []Row, key func(*Row) []string, less func(a, b string) bool) []Group {
+	byName := make(map[string]*Group)
+	var names []string
+	for i := range rows {
+		for _, name := range key(&rows[i]) {
+			g, ok := byName[name]
+			if !ok {
+				g = &Group{Name: name}
+				byName[name] = g
+				names = append(names, name)
+			}
+			g.Rows = append(g.Rows, rows[i])
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool { return less(names[i], names[j]) })
+	groups := make([]Group, 0, len(names))
+	for _, name := range names {
+		g := byName[name]
+		sort.SliceStable(g.Rows, func(i, j int) bool { return expiresBefore(&g.Rows[i], &g.Rows[j]) })
+		groups = append(groups, *g)
+	}
+	return groups
+}
+
+// expiresBefore orders rows by expiry, those without a certificate last
+func expiresBefore(a, b *Row) bool {
+	switch {
+	case a.NotAfter == nil || b.NotAfter == nil:
+		return a.NotAfter != nil && b.NotAfter == nil
+	case !a.NotAfter.Equal(*b.NotAfter):
+		return a.NotAfter.Before(*b.NotAfter)
+	default:
+		return a.Target < b.Target
+	}
+}
+
+func expiryBucket(row *Row) []string {
+	switch {
+	case row.NotAfter == nil:
+		return []string{bucketUnknown}
+	case row.Status == StatusExpired:
+		return []string{bucketExpired}
+	}
+	for _, b := range expiryBuckets {
+		if row.DaysLeft <= b.days {
+			return []string{b.name}
+		}
+	}
+	return []string{bucketLater}
+}
+
+// bucketOrder orders expiry groups from the most urgent
+func bucketOrder(a, b string) bool {
+	order := []string{bucketExpired}
+	for _, bucket := range expiryBuckets {
+		order = append(order, bucket.name)
+	}
+	order = append(order, bucketLater, bucketUnknown)
+
+	rank := func(name string) int {
+		for i, o := range order {
+			if o == name {
+				return i
+			}
+		}
+		return len(order)
+	}
+	return rank(a) < rank(b)
+}
+
+// lastGroup orders groups by name, with the group named last at the end
+func lastGroup(last string) func(a, b string) bool {
+	return func(a, b string) bool {
+		if a == last || b == last {
+			return b == last && a != last
+		}
+		return a < b
+	}
+}
@@ -0,0 +1,216 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/history"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+var now = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func testCerts() []config.CertificateConfig {
+	return []config.CertificateConfig{
+		{Hostname: "api.example.com", Port: 443, Tags: []string{"prod", "api"}, Owner: "platform"},
+		{Hostname: "old.example.com", Port: 443, Tags: []string{"legacy"}},
+		{Hostname: "mail.example.com", Port: 993, Owner: "it | ops"},
+		{Hostname: "down.example.com", Port: 443, Tags: []string{"prod"}},
+		{Hostname: "new.example.com", Port: 443},
+	}
+}
+
+func testScan(host string, port, days int, issuer string, issues ...scanner.ChainIssue) scanner.ScanResult {
+	return scanner.ScanResult{
+		Hostname:   host,
+		Port:       port,
+		Success:    true,
+		ScannedAt:  now,
+		TLSVersion: "TLS 1.3",
+		Certificate: &scanner.CertificateInfo{
+			Subject:  host,
+			Issuer:   issuer,
+			KeyType:  "ECDSA P-256",
+			NotAfter: now.Add(time.Duration(days)*24*time.Hour + time.Hour),
+		},
+		Chain: &scanner.ChainInfo{Issues: issues},
+	}
+}
+
+func testRows() []Row {
+	incomplete := scanner.ChainIssue{Type: "incomplete_chain", Severity: scanner.SeverityHigh, Message: "missing <intermediate>"}
+	results := []scanner.ScanResult{
+		testScan("api.example.com", 443, 20, "R3"),
+		testScan("old.example.com", 443, -3, "Old CA"),
+		testScan("mail.example.com", 993, 200, "R3", incomplete),
+		{Hostname: "down.example.com", Port: 443, ScannedAt: now, Error: "connection refused"},
+	}
+	return FromScan(testCerts(), results, now)
+}
+
+func TestFromScan(t *testing.T) {
+	rows := testRows()
+	want := []struct {
+		status string
+		days   int
+	}{
+		{StatusOK, 20},
+		{StatusExpired, -2},
+		{StatusIssues, 200},
+		{StatusFailed, 0},
+		{StatusUnknown, 0},
+	}
+	for i, w := range want {
+		if rows[i].Status != w.status || rows[i].DaysLeft != w.days {
+			t.Errorf("row %d (%s): status = %q, days = %d, want %q, %d",
+				i, rows[i].Target, rows[i].Status, rows[i].DaysLeft, w.status, w.days)
+		}
+	}
+	if rows[0].Owner != "platform" || rows[0].KeyType != "ECDSA P-256" || rows[0].Issuer != "R3" {
+		t.Errorf("row 0 = %+v", rows[0])
+	}
+}
+
+func TestFromHistory(t *testing.T) {
+	dir := t.TempDir()
+	s, err := history.Open(dir, time.Hour, 100)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	scan := testScan("api.example.com", 443, 20, "R3")
+	if err := s.Record(&scan); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	rows, err := FromHistory(testCerts(), dir, now)
+	if err != nil {
+		t.Fatalf("FromHistory() error = %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("FromHistory() = %d rows, want 5", len(rows))
+	}
+	if r := rows[0]; r.Status != StatusOK || r.Issuer != "R3" || r.KeyType != "ECDSA P-256" || r.DaysLeft != 20 {
+		t.Errorf("recorded row = %+v", r)
+	}
+	if rows[1].Status != StatusUnknown {
+		t.Errorf("unrecorded row status = %q, want %q", rows[1].Status, StatusUnknown)
+	}
+}
+
+func groupNames(r *Report) []string {
+	names := make([]string, 0, len(r.Groups))
+	for _, g := range r.Groups {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		groupBy string
+		want    string
+	}{
+		{GroupByExpiry, "Expired,Within 30 days,Later,Unknown"},
+		{GroupByIssuer, "Old CA,R3,(unknown)"},
+		{GroupByTag, "api,legacy,prod,(untagged)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			r, err := New(testRows(), tt.groupBy, SourceScan, now)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := strings.Join(groupNames(r), ","); got != tt.want {
+				t.Errorf("groups = %s, want %s", got, tt.want)
+			}
+			if r.Summary != (Summary{Targets: 5, Expired: 1, Expiring: 1, Issues: 1, Failed: 2}) {
+				t.Errorf("Summary = %+v", r.Summary)
+			}
+		})
+	}
+
+	r, _ := New(testRows(), GroupByTag, SourceScan, now)
+	prod := r.Groups[2]
+	if len(prod.Rows) != 2 || prod.Rows[0].Target != "api.example.com:443" || prod.Rows[1].Target != "down.example.com:443" {
+		t.Errorf("prod group = %+v, want rows ordered by expiry", prod.Rows)
+	}
+
+	if _, err := New(testRows(), "owner", SourceScan, now); err == nil {
+		t.Error("New() with an unknown grouping succeeded")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r, _ := New(testRows(), GroupByTag, SourceScan, now)
+	var buf bytes.Buffer
+	if err := r.Write(&buf, FormatCSV); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	// The header and a row per tag of each target
+	if len(records) != 7 {
+		t.Fatalf("got %d records, want 7", len(records))
+	}
+	if got := records[1]; got[0] != "api" || got[1] != "api.example.com:443" || got[3] != "prod;api" || got[8] != "20" {
+		t.Errorf("first row = %v", got)
+	}
+	mail := records[5]
+	if mail[0] != untagged || mail[2] != "it | ops" || mail[10] != StatusIssues || mail[11] != "incomplete_chain (high)" {
+		t.Errorf("mail row = %v", mail)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	r, _ := New(testRows(), GroupByIssuer, SourceScan, now)
+	var buf bytes.Buffer
+	if err := r.Write(&buf, FormatMarkdown); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"## R3 (2)",
+		`| mail.example.com:993 | it \| ops |`,
+		`**incomplete\_chain** (high): missing &lt;intermediate&gt;`,
+		"| 5 | 1 | 1 | 1 | 2 |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Markdown missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	r, _ := New(testRows(), GroupByExpiry, SourceHistory, now)
+	var buf bytes.Buffer
+	if err := r.Write(&buf, FormatHTML); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"from the scan history, grouped by expiry",
+		`<tr class="issues">`,
+		`<tr class="expired">`,
+		`<span class="high">incomplete_chain</span> (high): missing &lt;intermediate&gt;`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML missing %q", want)
+		}
+	}
+
+	if err := r.Write(&buf, "pdf"); err == nil {
+		t.Error("Write() with an unknown format succeeded")
+	}
+}
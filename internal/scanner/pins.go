@@ -0,0 +1,61 @@
+package scanner
+
+import (
+	"fmt"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+// IssuePinMismatch is raised when a target serves a certificate that does not
+// match its configured pins (possible interception or unexpected reissue).
+const IssuePinMismatch = "pin_mismatch"
+
+// CheckPins compares the scanned chain against the configured pins and
+// appends a pin_mismatch issue for every pin list that does not match.
+// Results without a certificate are left untouched.
+func CheckPins(result *ScanResult, pins *config.PinConfig) {
+	if pins == nil || pins.IsEmpty() || !result.Success || result.Certificate == nil || result.Chain == nil {
+		return
+	}
+
+	if len(pins.LeafSHA256) > 0 && !pinMatches(pins.LeafSHA256, result.Certificate.FingerprintSHA256) {
+		addPinIssue(result.Chain, 0, fmt.Sprintf("Leaf certificate fingerprint %s does not match any pinned value",
+			result.Certificate.FingerprintSHA256))
+	}
+
+	if len(pins.SPKISHA256) > 0 && !pinMatches(pins.SPKISHA256, result.Certificate.SPKISHA256) {
+		addPinIssue(result.Chain, 0, fmt.Sprintf("Leaf public key hash %s does not match any pinned value",
+			result.Certificate.SPKISHA256))
+	}
+
+	if len(pins.CASHA256) > 0 {
+		matched := false
+		for _, cert := range result.Chain.Certificates[min(1, len(result.Chain.Certificates)):] {
+			if pinMatches(pins.CASHA256, cert.SPKISHA256) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			addPinIssue(result.Chain, 0, "No issuing CA in the served chain matches a pinned CA")
+		}
+	}
+}
+
+func addPinIssue(chain *ChainInfo, index int, msg string) {
+	chain.Issues = append(chain.Issues, ChainIssue{
+		Type:             IssuePinMismatch,
+		Severity:         SeverityHigh,
+		Message:          msg,
+		CertificateIndex: index,
+	})
+}
+
+func pinMatches(pins []string, value string) bool {
+	for _, p := range pins {
+		if config.NormalizePin(p) == value {
+			return true
+		}
+	}
+	return false
+}
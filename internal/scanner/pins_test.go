@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+const (
+	leafFP   = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	leafSPKI = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	caSPKI   = "cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+	otherPin = "dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+)
+
+func pinnedResult() ScanResult {
+	return ScanResult{
+		Hostname: "example.com",
+		Port:     443,
+		Success:  true,
+		Certificate: &CertificateInfo{
+			FingerprintSHA256: leafFP,
+			SPKISHA256:        leafSPKI,
+		},
+		Chain: &ChainInfo{
+			Valid: true,
+			Certificates: []ChainCertificate{
+				{FingerprintSHA256: leafFP, SPKISHA256: leafSPKI},
+				{SPKISHA256: caSPKI},
+			},
+		},
+	}
+}
+
+func TestCheckPins(t *testing.T) {
+	tests := []struct {
+		name       string
+		pins       config.PinConfig
+		wantIssues int
+	}{
+		{"no pins", config.PinConfig{}, 0},
+		{"leaf matches", config.PinConfig{LeafSHA256: []string{leafFP}}, 0},
+		{"leaf matches openssl format", config.PinConfig{LeafSHA256: []string{colonize(leafFP)}}, 0},
+		{"leaf mismatch", config.PinConfig{LeafSHA256: []string{otherPin}}, 1},
+		{"spki matches one of many", config.PinConfig{SPKISHA256: []string{otherPin, leafSPKI}}, 0},
+		{"spki mismatch", config.PinConfig{SPKISHA256: []string{otherPin}}, 1},
+		{"ca matches", config.PinConfig{CASHA256: []string{caSPKI}}, 0},
+		{"ca does not match leaf key", config.PinConfig{CASHA256: []string{leafSPKI}}, 1},
+		{"all mismatch", config.PinConfig{
+			LeafSHA256: []string{otherPin},
+			SPKISHA256: []string{otherPin},
+			CASHA256:   []string{otherPin},
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := pinnedResult()
+			CheckPins(&result, &tt.pins)
+
+			if len(result.Chain.Issues) != tt.wantIssues {
+				t.Fatalf("got %d issues, want %d: %+v", len(result.Chain.Issues), tt.wantIssues, result.Chain.Issues)
+			}
+			for _, issue := range result.Chain.Issues {
+				if issue.Type != IssuePinMismatch || issue.Severity != SeverityHigh {
+					t.Errorf("unexpected issue %+v", issue)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPins_FailedScan(t *testing.T) {
+	result := ScanResult{Hostname: "example.com", Port: 443, Error: "connection failed"}
+	CheckPins(&result, &config.PinConfig{LeafSHA256: []string{otherPin}})
+
+	if result.Chain != nil {
+		t.Error("expected failed scan to be left untouched")
+	}
+}
+
+func colonize(hexStr string) string {
+	parts := make([]string, 0, len(hexStr)/2)
+	for i := 0; i < len(hexStr); i += 2 {
+		parts = append(parts, strings.ToUpper(hexStr[i:i+2]))
+	}
+	return strings.Join(parts, ":")
+}
@@ -2,12 +2,17 @@ package scanner
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
 	"fmt"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,64 +26,127 @@ import (
 // Fields are ordered for optimal memory alignment
 type Scanner struct {
 	logger      *zap.Logger
+	scanFunc    func(context.Context, config.CertificateConfig) ScanResult // Overridable for tests
 	timeout     time.Duration
 	concurrency int
 }
 
 // New creates a new Scanner
 func New(timeout time.Duration, concurrency int, logger *zap.Logger) *Scanner {
-	return &Scanner{
+	s := &Scanner{
 		timeout:     timeout,
-		concurrency: concurrency,
+		concurrency: max(concurrency, 1),
 		logger:      logger,
 	}
+	s.scanFunc = s.ScanTarget
+	return s
 }
 
-// ScanAll scans all configured certificates concurrently
+// ScanAll scans all configured certificates concurrently and collects the results.
+// Results are returned in completion order, not configuration order.
 func (s *Scanner) ScanAll(ctx context.Context, certs []config.CertificateConfig) []ScanResult {
-	results := make([]ScanResult, len(certs))
-	var wg sync.WaitGroup
+	results := make([]ScanResult, 0, len(certs))
+	for r := range s.ScanStream(ctx, Feed(certs)) {
+		results = append(results, r)
+	}
+	return results
+}
 
-	// Use a semaphore channel for concurrency control
-	sem := make(chan struct{}, s.concurrency)
+// ScanStream scans targets received on the input channel with a bounded pool of
+// workers and streams the results as they complete. Memory use depends on the
+// concurrency, not on the number of targets. Every target received yields exactly
+// one result; once ctx is canceled, remaining targets yield a "context canceled"
+// result without being scanned. The returned channel is closed after the input
+// channel is closed and all in-flight scans have finished.
+func (s *Scanner) ScanStream(ctx context.Context, targets <-chan config.CertificateConfig) <-chan ScanResult {
+	results := make(chan ScanResult, s.concurrency)
 
-	for i, cert := range certs {
+	var wg sync.WaitGroup
+	for i := 0; i < s.concurrency; i++ {
 		wg.Add(1)
-		go func(idx int, c config.CertificateConfig) {
+		go func() {
 			defer wg.Done()
-
-			// Acquire semaphore
-			select {
-			case sem <- struct{}{}:
-				defer func() { <-sem }()
-			case <-ctx.Done():
-				results[idx] = ScanResult{
-					Hostname:  c.Hostname,
-					Port:      c.Port,
-					Success:   false,
-					Error:     "context canceled",
-					ScannedAt: time.Now().UTC(),
+			for c := range targets {
+				if ctx.Err() != nil {
+					results <- ScanResult{
+						Hostname:  c.Hostname,
+						Port:      c.Port,
+						Success:   false,
+						Error:     "context canceled",
+						ScannedAt: time.Now().UTC(),
+					}
+					continue
 				}
-				return
+				results <- s.scanFunc(ctx, c)
 			}
-
-			results[idx] = s.Scan(ctx, c.Hostname, c.Port)
-		}(i, cert)
+		}()
 	}
 
-	wg.Wait()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
 	return results
 }
 
+// Feed returns a channel that yields the given certificates in priority order
+// (highest priority first, configuration order otherwise). The channel is closed
+// once all certificates have been sent, so it must be drained; ScanStream does,
+// and yields a result for every target even after its context is canceled.
+func Feed(certs []config.CertificateConfig) <-chan config.CertificateConfig {
+	ch := make(chan config.CertificateConfig)
+
+	go func() {
+		defer close(ch)
+		for _, i := range priorityOrder(certs) {
+			ch <- certs[i]
+		}
+	}()
+
+	return ch
+}
+
+// priorityOrder returns the indices of certs sorted by descending priority.
+// The sort is stable, so equal priorities keep their original order.
+func priorityOrder(certs []config.CertificateConfig) []int {
+	order := make([]int, len(certs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return certs[order[a]].Priority > certs[order[b]].Priority
+	})
+	return order
+}
+
+// ScanTarget scans a configured target and applies its per-target options and checks
+func (s *Scanner) ScanTarget(ctx context.Context, cert config.CertificateConfig) ScanResult {
+	result, _ := s.scan(ctx, cert.Hostname, cert.Port, cert.PQOnly)
+	CheckPins(&result, &cert.Pins)
+	return result
+}
+
 // Scan performs a TLS connection and extracts certificate information
 func (s *Scanner) Scan(ctx context.Context, hostname string, port int) ScanResult {
+	result, _ := s.scan(ctx, hostname, port, false)
+	return result
+}
+
+// Inspect scans a target like Scan and also returns the certificates it
+// served, leaf first, for details the scan result doesn't keep
+func (s *Scanner) Inspect(ctx context.Context, hostname string, port int) (ScanResult, []*x509.Certificate) {
+	return s.scan(ctx, hostname, port, false)
+}
+
+func (s *Scanner) scan(ctx context.Context, hostname string, port int, pqOnly bool) (ScanResult, []*x509.Certificate) {
 	result := ScanResult{
 		Hostname:  hostname,
 		Port:      port,
 		ScannedAt: time.Now().UTC(),
 	}
 
-	addr := fmt.Sprintf("%s:%d", hostname, port)
+	addr := net.JoinHostPort(hostname, strconv.Itoa(port))
 
 	// Create TLS config
 	// We intentionally skip TLS verification and validate manually to inspect the full chain
@@ -87,31 +155,55 @@ func (s *Scanner) Scan(ctx context.Context, hostname string, port int) ScanResul
 		InsecureSkipVerify: true, //nolint:gosec // We validate manually to inspect the full certificate chain
 	}
 
-	// Create dialer with timeout
-	dialer := &net.Dialer{
-		Timeout: s.timeout,
+	// Offer only hybrid post-quantum groups so the handshake fails
+	// against servers that cannot negotiate them
+	if pqOnly {
+		tlsConfig.MinVersion = tls.VersionTLS13
+		tlsConfig.CurvePreferences = PostQuantumGroups
+	}
+
+	// The timeout covers DNS resolution, connect and handshake together
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	// Measure DNS and connect phases through the dialer's trace hooks
+	trace := newPhaseTrace()
+	dialer := &net.Dialer{}
+
+	rawConn, err := dialer.DialContext(trace.withContext(ctx), "tcp", addr)
+	trace.apply(&result.Timings)
+	if err != nil {
+		result.Timings.Total = time.Since(trace.start)
+		return s.scanFailed(result, "connection failed", pqOnly, err), nil
 	}
 
-	// Establish connection with context
-	conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	handshakeStart := time.Now()
+	conn := tls.Client(rawConn, tlsConfig)
+	err = conn.HandshakeContext(ctx)
+	result.Timings.TLSHandshake = time.Since(handshakeStart)
+	result.Timings.Total = time.Since(trace.start)
 	if err != nil {
-		result.Success = false
-		result.Error = fmt.Sprintf("connection failed: %v", err)
-		s.logger.Debug("scan failed",
-			zap.String("hostname", hostname),
-			zap.Int("port", port),
-			zap.Error(err),
-		)
-		return result
+		rawConn.Close()
+		return s.scanFailed(result, "TLS handshake failed", pqOnly, err), nil
 	}
 	defer conn.Close()
 
-	// Get peer certificates
+	// Record negotiated protocol parameters
 	state := conn.ConnectionState()
+	result.TLSVersion = tls.VersionName(state.Version)
+	if state.CurveID != 0 {
+		result.KeyExchange = state.CurveID.String()
+	}
+	result.PostQuantum = IsPostQuantum(state.CurveID)
+
+	// Get peer certificates
 	if len(state.PeerCertificates) == 0 {
 		result.Success = false
 		result.Error = "no certificates received"
-		return result
+		return result, nil
 	}
 
 	// Parse leaf certificate
@@ -127,16 +219,44 @@ func (s *Scanner) Scan(ctx context.Context, hostname string, port int) ScanResul
 		zap.Int("port", port),
 		zap.String("subject", result.Certificate.Subject),
 		zap.Int("days_until_expiry", result.Certificate.DaysUntilExpiry),
+		zap.String("tls_version", result.TLSVersion),
+		zap.String("key_exchange", result.KeyExchange),
+		zap.Duration("duration", result.Timings.Total),
 	)
 
+	return result, state.PeerCertificates
+}
+
+// scanFailed marks a result as failed during the given phase
+func (s *Scanner) scanFailed(result ScanResult, phase string, pqOnly bool, err error) ScanResult {
+	result.Success = false
+	result.Error = fmt.Sprintf("%s: %v", phase, err)
+	if pqOnly {
+		result.Error = fmt.Sprintf("%s (post-quantum key exchange only): %v", phase, err)
+	}
+	s.logger.Debug("scan failed",
+		zap.String("hostname", result.Hostname),
+		zap.Int("port", result.Port),
+		zap.Error(err),
+	)
 	return result
 }
 
-func (s *Scanner) parseCertificate(cert *x509.Certificate) *CertificateInfo {
-	// Calculate SHA256 fingerprint
-	fingerprint := sha256.Sum256(cert.Raw)
-	fingerprintHex := hex.EncodeToString(fingerprint[:])
+// PostQuantumGroups lists the hybrid post-quantum key exchange groups
+// offered when probing a target with pq_only
+var PostQuantumGroups = []tls.CurveID{tls.X25519MLKEM768}
 
+// IsPostQuantum reports whether a negotiated key exchange group is post-quantum
+func IsPostQuantum(id tls.CurveID) bool {
+	for _, g := range PostQuantumGroups {
+		if id == g {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scanner) parseCertificate(cert *x509.Certificate) *CertificateInfo {
 	// Extract issuer organization
 	issuerOrg := ""
 	if len(cert.Issuer.Organization) > 0 {
@@ -158,7 +278,9 @@ func (s *Scanner) parseCertificate(cert *x509.Certificate) *CertificateInfo {
 		Issuer:            cert.Issuer.CommonName,
 		IssuerOrg:         issuerOrg,
 		SerialNumber:      cert.SerialNumber.String(),
-		FingerprintSHA256: fingerprintHex,
+		FingerprintSHA256: Fingerprint(cert),
+		SPKISHA256:        SPKIHash(cert),
+		KeyType:           KeyType(cert),
 		NotBefore:         cert.NotBefore.UTC(),
 		NotAfter:          cert.NotAfter.UTC(),
 		SANList:           sanList,
@@ -178,10 +300,12 @@ func (s *Scanner) parseChain(certs []*x509.Certificate, hostname string) *ChainI
 	// Build chain certificates list
 	for i, cert := range certs {
 		chain.Certificates = append(chain.Certificates, ChainCertificate{
-			Subject:   cert.Subject.CommonName,
-			Issuer:    cert.Issuer.CommonName,
-			NotBefore: cert.NotBefore.UTC(),
-			NotAfter:  cert.NotAfter.UTC(),
+			Subject:           cert.Subject.CommonName,
+			Issuer:            cert.Issuer.CommonName,
+			FingerprintSHA256: Fingerprint(cert),
+			SPKISHA256:        SPKIHash(cert),
+			NotBefore:         cert.NotBefore.UTC(),
+			NotAfter:          cert.NotAfter.UTC(),
 		})
 
 		// Check for expiration
@@ -189,6 +313,7 @@ func (s *Scanner) parseChain(certs []*x509.Certificate, hostname string) *ChainI
 			chain.Valid = false
 			chain.Issues = append(chain.Issues, ChainIssue{
 				Type:             "expired",
+				Severity:         SeverityHigh,
 				Message:          fmt.Sprintf("Certificate expired on %s", cert.NotAfter.Format(time.RFC3339)),
 				CertificateIndex: i,
 			})
@@ -199,6 +324,7 @@ func (s *Scanner) parseChain(certs []*x509.Certificate, hostname string) *ChainI
 			chain.Valid = false
 			chain.Issues = append(chain.Issues, ChainIssue{
 				Type:             "not_yet_valid",
+				Severity:         SeverityHigh,
 				Message:          fmt.Sprintf("Certificate not valid until %s", cert.NotBefore.Format(time.RFC3339)),
 				CertificateIndex: i,
 			})
@@ -208,6 +334,7 @@ func (s *Scanner) parseChain(certs []*x509.Certificate, hostname string) *ChainI
 		if i == 0 && cert.Subject.String() == cert.Issuer.String() {
 			chain.Issues = append(chain.Issues, ChainIssue{
 				Type:             "self_signed",
+				Severity:         SeverityMedium,
 				Message:          "Leaf certificate is self-signed",
 				CertificateIndex: i,
 			})
@@ -220,6 +347,7 @@ func (s *Scanner) parseChain(certs []*x509.Certificate, hostname string) *ChainI
 		if err := leaf.VerifyHostname(hostname); err != nil {
 			chain.Issues = append(chain.Issues, ChainIssue{
 				Type:             "hostname_mismatch",
+				Severity:         SeverityHigh,
 				Message:          fmt.Sprintf("Certificate does not match hostname: %v", err),
 				CertificateIndex: 0,
 			})
@@ -231,6 +359,7 @@ func (s *Scanner) parseChain(certs []*x509.Certificate, hostname string) *ChainI
 		if isWeakSignature(cert.SignatureAlgorithm.String()) {
 			chain.Issues = append(chain.Issues, ChainIssue{
 				Type:             "weak_crypto",
+				Severity:         SeverityMedium,
 				Message:          fmt.Sprintf("Weak signature algorithm: %s", cert.SignatureAlgorithm.String()),
 				CertificateIndex: i,
 			})
@@ -240,6 +369,32 @@ func (s *Scanner) parseChain(certs []*x509.Certificate, hostname string) *ChainI
 	return chain
 }
 
+// Fingerprint returns the hex SHA-256 fingerprint of a certificate
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// SPKIHash returns the hex SHA-256 hash of a certificate's SubjectPublicKeyInfo
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyType describes the public key of a certificate by algorithm and size
+func KeyType(cert *x509.Certificate) string {
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return cert.PublicKeyAlgorithm.String()
+	}
+}
+
 func isWeakSignature(algo string) bool {
 	weak := []string{"MD2", "MD5", "SHA1"}
 	algo = strings.ToUpper(algo)
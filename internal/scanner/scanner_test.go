@@ -0,0 +1,303 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+)
+
+// newTLSServer starts a local TLS server; curves restricts the key exchange
+// groups the server accepts (nil keeps the Go defaults).
+func newTLSServer(t *testing.T, curves []tls.CurveID) (string, int) {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	srv.TLS = &tls.Config{CurvePreferences: curves}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // Expected handshake failures
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	host, portStr, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to parse listener address: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("failed to parse listener port: %v", err)
+	}
+	return host, port
+}
+
+func TestScan_RecordsKeyExchange(t *testing.T) {
+	host, port := newTLSServer(t, nil)
+	s := New(5*time.Second, 1, zap.NewNop())
+
+	result := s.Scan(context.Background(), host, port)
+	if !result.Success {
+		t.Fatalf("scan failed: %s", result.Error)
+	}
+
+	if result.TLSVersion != "TLS 1.3" {
+		t.Errorf("TLSVersion = %q, want TLS 1.3", result.TLSVersion)
+	}
+	if result.KeyExchange != "X25519MLKEM768" {
+		t.Errorf("KeyExchange = %q, want X25519MLKEM768", result.KeyExchange)
+	}
+	if !result.PostQuantum {
+		t.Error("PostQuantum = false, want true")
+	}
+}
+
+func TestInspect_ReturnsServedChain(t *testing.T) {
+	host, port := newTLSServer(t, nil)
+	s := New(5*time.Second, 1, zap.NewNop())
+
+	result, certs := s.Inspect(context.Background(), host, port)
+	if !result.Success {
+		t.Fatalf("scan failed: %s", result.Error)
+	}
+	if len(certs) != len(result.Chain.Certificates) {
+		t.Fatalf("got %d certificates, want the %d of the chain", len(certs), len(result.Chain.Certificates))
+	}
+	if result.Certificate.KeyType != "RSA 2048" {
+		t.Errorf("KeyType = %q, want RSA 2048", result.Certificate.KeyType)
+	}
+	if got := Fingerprint(certs[0]); got != result.Certificate.FingerprintSHA256 {
+		t.Errorf("leaf fingerprint = %s, want %s", got, result.Certificate.FingerprintSHA256)
+	}
+
+	if _, certs := s.Inspect(context.Background(), "127.0.0.1", 1); certs != nil {
+		t.Errorf("got %d certificates from a failed scan", len(certs))
+	}
+}
+
+func TestScan_RecordsTimings(t *testing.T) {
+	_, port := newTLSServer(t, nil)
+	s := New(5*time.Second, 1, zap.NewNop())
+
+	// Use a name so the DNS phase runs
+	result := s.Scan(context.Background(), "localhost", port)
+	if !result.Success {
+		t.Fatalf("scan failed: %s", result.Error)
+	}
+
+	tm := result.Timings
+	if tm.DNSLookup <= 0 || tm.TCPConnect <= 0 || tm.TLSHandshake <= 0 {
+		t.Errorf("expected all phases to be measured, got %+v", tm)
+	}
+	if tm.Total < tm.DNSLookup+tm.TCPConnect+tm.TLSHandshake {
+		t.Errorf("total %v is less than the sum of its phases %+v", tm.Total, tm)
+	}
+
+	// IP addresses skip DNS
+	result = s.Scan(context.Background(), "127.0.0.1", port)
+	if result.Timings.DNSLookup != 0 {
+		t.Errorf("expected no DNS phase for an IP address, got %v", result.Timings.DNSLookup)
+	}
+}
+
+func TestScan_ConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	result := New(time.Second, 1, zap.NewNop()).Scan(context.Background(), "127.0.0.1", port)
+	if result.Success {
+		t.Fatal("expected scan of a closed port to fail")
+	}
+	if !strings.HasPrefix(result.Error, "connection failed") {
+		t.Errorf("Error = %q, want connection failed", result.Error)
+	}
+	if result.Timings.TLSHandshake != 0 {
+		t.Errorf("expected no handshake phase, got %v", result.Timings.TLSHandshake)
+	}
+}
+
+func TestScan_ClassicalKeyExchange(t *testing.T) {
+	host, port := newTLSServer(t, []tls.CurveID{tls.X25519})
+	s := New(5*time.Second, 1, zap.NewNop())
+
+	result := s.Scan(context.Background(), host, port)
+	if !result.Success {
+		t.Fatalf("scan failed: %s", result.Error)
+	}
+
+	if result.KeyExchange != "X25519" {
+		t.Errorf("KeyExchange = %q, want X25519", result.KeyExchange)
+	}
+	if result.PostQuantum {
+		t.Error("PostQuantum = true, want false")
+	}
+}
+
+func TestScanTarget_PQOnly(t *testing.T) {
+	s := New(5*time.Second, 1, zap.NewNop())
+
+	host, port := newTLSServer(t, nil)
+	result := s.ScanTarget(context.Background(), config.CertificateConfig{Hostname: host, Port: port, PQOnly: true})
+	if !result.Success || !result.PostQuantum {
+		t.Errorf("expected post-quantum handshake to succeed, got success=%v error=%q", result.Success, result.Error)
+	}
+
+	host, port = newTLSServer(t, []tls.CurveID{tls.X25519, tls.CurveP256})
+	result = s.ScanTarget(context.Background(), config.CertificateConfig{Hostname: host, Port: port, PQOnly: true})
+	if result.Success {
+		t.Error("expected post-quantum only handshake to fail against a classical-only server")
+	}
+}
+
+// stubScanner returns a scanner whose scans complete instantly without network access
+func stubScanner(concurrency int) *Scanner {
+	s := New(time.Second, concurrency, zap.NewNop())
+	s.scanFunc = func(_ context.Context, c config.CertificateConfig) ScanResult {
+		return ScanResult{Hostname: c.Hostname, Port: c.Port, Success: true}
+	}
+	return s
+}
+
+func TestScanAll_PriorityOrder(t *testing.T) {
+	certs := []config.CertificateConfig{
+		{Hostname: "low.example.com", Port: 443},
+		{Hostname: "high.example.com", Port: 443, Priority: 10},
+		{Hostname: "mid.example.com", Port: 443, Priority: 5},
+		{Hostname: "low2.example.com", Port: 443},
+	}
+
+	// A single worker scans in feed order
+	results := stubScanner(1).ScanAll(context.Background(), certs)
+
+	want := []string{"high.example.com", "mid.example.com", "low.example.com", "low2.example.com"}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, r := range results {
+		if r.Hostname != want[i] {
+			t.Errorf("result[%d] = %s, want %s", i, r.Hostname, want[i])
+		}
+	}
+}
+
+func TestScanStream_BoundedConcurrency(t *testing.T) {
+	const concurrency = 4
+
+	var running, peak atomic.Int32
+	s := New(time.Second, concurrency, zap.NewNop())
+	s.scanFunc = func(_ context.Context, c config.CertificateConfig) ScanResult {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		return ScanResult{Hostname: c.Hostname, Port: c.Port, Success: true}
+	}
+
+	certs := make([]config.CertificateConfig, 100)
+	for i := range certs {
+		certs[i] = config.CertificateConfig{Hostname: fmt.Sprintf("host%d.example.com", i), Port: 443}
+	}
+
+	count := 0
+	for range s.ScanStream(context.Background(), Feed(certs)) {
+		count++
+	}
+
+	if count != len(certs) {
+		t.Errorf("got %d results, want %d", count, len(certs))
+	}
+	if peak.Load() > concurrency {
+		t.Errorf("peak concurrency %d exceeds limit %d", peak.Load(), concurrency)
+	}
+}
+
+func TestScanStream_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	targets := make(chan config.CertificateConfig, 3)
+	for i := 0; i < 3; i++ {
+		targets <- config.CertificateConfig{Hostname: fmt.Sprintf("host%d.example.com", i), Port: 443}
+	}
+	close(targets)
+
+	count := 0
+	for r := range stubScanner(2).ScanStream(ctx, targets) {
+		count++
+		if r.Success || r.Error != "context canceled" {
+			t.Errorf("expected canceled result, got %+v", r)
+		}
+	}
+	if count != 3 {
+		t.Errorf("got %d results, want 3", count)
+	}
+}
+
+func TestScanAll_CanceledYieldsEveryTarget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	certs := make([]config.CertificateConfig, 10)
+	for i := range certs {
+		certs[i] = config.CertificateConfig{Hostname: fmt.Sprintf("host%d.example.com", i), Port: 443}
+	}
+
+	results := stubScanner(2).ScanAll(ctx, certs)
+	if len(results) != len(certs) {
+		t.Fatalf("got %d results, want one per target", len(results))
+	}
+	for _, r := range results {
+		if r.Error != "context canceled" {
+			t.Errorf("expected canceled result, got %+v", r)
+		}
+	}
+}
+
+// BenchmarkScanStream50k measures the worker pool overhead for a large
+// target set. Results are consumed as they arrive, so allocations per
+// operation should not include a results slice sized by the target count.
+func BenchmarkScanStream50k(b *testing.B) {
+	const targets = 50_000
+
+	certs := make([]config.CertificateConfig, targets)
+	for i := range certs {
+		certs[i] = config.CertificateConfig{
+			Hostname: fmt.Sprintf("host%d.example.com", i),
+			Port:     443,
+			Priority: i % 3,
+		}
+	}
+	s := stubScanner(50)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		for range s.ScanStream(ctx, Feed(certs)) {
+			count++
+		}
+		if count != targets {
+			b.Fatalf("got %d results, want %d", count, targets)
+		}
+	}
+}
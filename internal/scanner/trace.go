@@ -0,0 +1,65 @@
+package scanner
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// phaseTrace records DNS and TCP connect timings using the httptrace hooks
+// that net.Dialer invokes for the context it dials with.
+type phaseTrace struct {
+	start        time.Time
+	dnsStart     time.Time
+	connectStart map[string]time.Time // The dialer may race several addresses
+	dnsLookup    time.Duration
+	tcpConnect   time.Duration
+	mu           sync.Mutex
+}
+
+func newPhaseTrace() *phaseTrace {
+	return &phaseTrace{
+		start:        time.Now(),
+		connectStart: make(map[string]time.Time),
+	}
+}
+
+// withContext returns a context that reports dial phases to the trace
+func (t *phaseTrace) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if !t.dnsStart.IsZero() {
+				t.dnsLookup = time.Since(t.dnsStart)
+			}
+		},
+		ConnectStart: func(_, addr string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.connectStart[addr] = time.Now()
+		},
+		ConnectDone: func(_, addr string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// Only the successful attempt counts as the connect time
+			if start, ok := t.connectStart[addr]; ok && err == nil {
+				t.tcpConnect = time.Since(start)
+			}
+		},
+	})
+}
+
+// apply copies the recorded dial phases into timings
+func (t *phaseTrace) apply(timings *Timings) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings.DNSLookup = t.dnsLookup
+	timings.TCPConnect = t.tcpConnect
+}
@@ -13,9 +13,22 @@ type ScanResult struct {
 	Chain       *ChainInfo
 	Hostname    string
 	Error       string
+	TLSVersion  string // Negotiated protocol version, e.g. "TLS 1.3"
+	KeyExchange string // Negotiated key exchange group, e.g. "X25519MLKEM768"
 	ScannedAt   time.Time
+	Timings     Timings
 	Port        int
 	Success     bool
+	PostQuantum bool // Key exchange used a hybrid post-quantum group
+}
+
+// Timings contains the duration of each phase of a scan.
+// Phases that did not run (e.g. DNS for an IP address) are zero.
+type Timings struct {
+	DNSLookup    time.Duration
+	TCPConnect   time.Duration
+	TLSHandshake time.Duration
+	Total        time.Duration
 }
 
 // CertificateInfo contains parsed certificate information
@@ -25,6 +38,8 @@ type CertificateInfo struct {
 	IssuerOrg         string
 	SerialNumber      string
 	FingerprintSHA256 string
+	SPKISHA256        string
+	KeyType           string // E.g. "RSA 2048", "ECDSA P-256" or "Ed25519"
 	SANList           []string
 	NotBefore         time.Time
 	NotAfter          time.Time
@@ -39,9 +54,17 @@ type ChainInfo struct {
 	Valid        bool
 }
 
+// Chain issue severities
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
 // ChainIssue represents an issue with the certificate chain
 type ChainIssue struct {
 	Type             string `json:"type"`
+	Severity         string `json:"severity"`
 	Message          string `json:"message"`
 	CertificateIndex int    `json:"certificate_index,omitempty"`
 }
@@ -49,10 +72,12 @@ type ChainIssue struct {
 // ChainCertificate represents a certificate in the chain
 // Fields are ordered for optimal memory alignment
 type ChainCertificate struct {
-	Subject   string    `json:"subject"`
-	Issuer    string    `json:"issuer"`
-	NotBefore time.Time `json:"not_before"`
-	NotAfter  time.Time `json:"not_after"`
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+	SPKISHA256        string    `json:"spki_sha256"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
 }
 
 // GetHostPort returns the hostname:port string
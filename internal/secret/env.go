@@ -0,0 +1,137 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// envRef matches $$ (an escaped dollar sign) and ${NAME} or ${NAME:-default}
+var envRef = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// ReferencePattern matches a value that is a single environment reference,
+// for schemas of values that are only valid once expanded
+const ReferencePattern = `^\$\{[A-Za-z_][A-Za-z0-9_]*(:-[^}]*)?\}$`
+
+// Expand replaces ${NAME} references in s using lookup. ${NAME:-default}
+// falls back to default when NAME is unset or empty, and $$ yields a
+// literal $. Referencing an unset variable without a default is an error.
+func Expand(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var missing []string
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+
+		m := envRef.FindStringSubmatch(ref)
+		name, hasDefault := m[1], strings.Contains(ref, ":-")
+		if value, ok := lookup(name); ok && (value != "" || !hasDefault) {
+			return value
+		}
+		if hasDefault {
+			return m[2]
+		}
+		missing = append(missing, name)
+		return ref
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// ReadInConfig reads the config file like viper's ReadInConfig, then expands
+// ${NAME} environment references in all of its values. References are
+// expanded after parsing, so values can't break the YAML structure and
+// comments are left alone.
+func ReadInConfig(v *viper.Viper) error {
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	path := v.ConfigFileUsed()
+	data, err := os.ReadFile(path) //nolint:gosec // Path is the config file viper just read
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if !bytes.Contains(data, []byte("$")) {
+		return nil
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	expanded, err := expandTree(doc, "", os.LookupEnv)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	out, err := yaml.Marshal(expanded)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	v.SetConfigType("yaml")
+	return v.ReadConfig(bytes.NewReader(out))
+}
+
+// expandTree expands references in every string of a decoded YAML document.
+// path is used to name the offending setting in errors.
+func expandTree(node any, path string, lookup func(string) (string, bool)) (any, error) {
+	switch n := node.(type) {
+	case string:
+		s, err := Expand(n, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return s, nil
+
+	case map[string]any:
+		// Sorted so the first error reported is deterministic
+		keys := make([]string, 0, len(n))
+		for key := range n {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			value, err := expandTree(n[key], child, lookup)
+			if err != nil {
+				return nil, err
+			}
+			n[key] = value
+		}
+		return n, nil
+
+	case []any:
+		for i := range n {
+			value, err := expandTree(n[i], path+"["+strconv.Itoa(i)+"]", lookup)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = value
+		}
+		return n, nil
+
+	default:
+		return node, nil
+	}
+}
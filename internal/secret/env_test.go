@@ -0,0 +1,104 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestExpand(t *testing.T) {
+	env := map[string]string{"HOST": "example.com", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"no references", "plain $value", "plain $value", false},
+		{"variable", "api.${HOST}:443", "api.example.com:443", false},
+		{"default unused", "${HOST:-other}", "example.com", false},
+		{"default for unset", "${MISSING:-fallback}", "fallback", false},
+		{"default for empty", "${EMPTY:-fallback}", "fallback", false},
+		{"empty without default", "x${EMPTY}x", "xx", false},
+		{"escaped", "$${HOST}", "${HOST}", false},
+		{"unset", "${MISSING}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.in, lookup)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadInConfig(t *testing.T) {
+	t.Setenv("CW_TEST_KEY", "cw_from_env")
+	t.Setenv("CW_TEST_PORT", "8443")
+	t.Setenv("CW_TEST_NOTES", "contains: colon # and hash")
+
+	path := filepath.Join(t.TempDir(), "certwatch.yaml")
+	content := `# ${NOT_EXPANDED_IN_COMMENTS}
+api:
+  key: ${CW_TEST_KEY}
+certificates:
+  - hostname: example.com
+    port: ${CW_TEST_PORT}
+    notes: ${CW_TEST_NOTES}
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := ReadInConfig(v); err != nil {
+		t.Fatalf("ReadInConfig() error = %v", err)
+	}
+
+	if got := v.GetString("api.key"); got != "cw_from_env" {
+		t.Errorf("api.key = %q, want cw_from_env", got)
+	}
+
+	var certs []struct {
+		Hostname string `mapstructure:"hostname"`
+		Notes    string `mapstructure:"notes"`
+		Port     int    `mapstructure:"port"`
+	}
+	if err := v.UnmarshalKey("certificates", &certs); err != nil {
+		t.Fatalf("failed to unmarshal certificates: %v", err)
+	}
+	if len(certs) != 1 || certs[0].Port != 8443 || certs[0].Notes != "contains: colon # and hash" {
+		t.Errorf("certificates = %+v", certs)
+	}
+}
+
+func TestReadInConfig_UnsetVariable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certwatch.yaml")
+	if err := os.WriteFile(path, []byte("api:\n  key: ${CW_TEST_UNSET_VARIABLE}\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	err := ReadInConfig(v)
+	if err == nil {
+		t.Fatal("expected an error for an unset variable")
+	}
+	if !strings.Contains(err.Error(), "api.key") || !strings.Contains(err.Error(), "CW_TEST_UNSET_VARIABLE") {
+		t.Errorf("error = %v, want it to name the setting and variable", err)
+	}
+}
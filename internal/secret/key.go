@@ -0,0 +1,107 @@
+// Package secret resolves secrets referenced from the agent configuration:
+// API keys read from files and ${VAR} environment references.
+package secret
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key is an API key given literally or read from a file. A file-backed key
+// is re-read whenever the file changes, so a rotated secret (e.g. a mounted
+// Kubernetes Secret) is picked up without a restart.
+// Fields are ordered for optimal memory alignment
+type Key struct {
+	modTime time.Time
+	value   string
+	file    string
+	size    int64
+	mu      sync.Mutex
+}
+
+// NewKey creates a key. When file is set it takes precedence over value,
+// which is used until the file has been read successfully.
+func NewKey(value, file string) *Key {
+	k := &Key{}
+	k.Set(value, file)
+	return k
+}
+
+// Set replaces the key's value and file, e.g. after a configuration reload
+func (k *Key) Set(value, file string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.value = value
+	k.file = file
+	k.modTime = time.Time{}
+	k.size = 0
+	if file != "" {
+		_, _ = k.refresh(false)
+	}
+}
+
+// Value returns the current key, re-reading the file if it changed.
+// If the file can't be read the last known value is returned.
+func (k *Key) Value() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.file != "" {
+		_, _ = k.refresh(false)
+	}
+	return k.value
+}
+
+// Reload re-reads the key file even if it looks unchanged, and reports
+// whether the key changed. Used when the API rejects the current key.
+func (k *Key) Reload() (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.file == "" {
+		return false, nil
+	}
+	return k.refresh(true)
+}
+
+// refresh reads the key file if forced or if its size or modification time
+// changed. The caller must hold k.mu.
+func (k *Key) refresh(force bool) (bool, error) {
+	info, err := os.Stat(k.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if !force && info.ModTime().Equal(k.modTime) && info.Size() == k.size {
+		return false, nil
+	}
+
+	value, err := ReadKeyFile(k.file)
+	if err != nil {
+		return false, err
+	}
+
+	k.modTime = info.ModTime()
+	k.size = info.Size()
+	changed := value != k.value
+	k.value = value
+	return changed, nil
+}
+
+// ReadKeyFile reads an API key or token from a file, ignoring surrounding
+// whitespace
+func ReadKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Path comes from the operator's config
+	if err != nil {
+		return "", fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("key file %s is empty", path)
+	}
+	return key, nil
+}
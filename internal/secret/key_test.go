@@ -0,0 +1,88 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKey(t *testing.T, path, key string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	// Make changes visible regardless of filesystem timestamp resolution
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("failed to set key file time: %v", err)
+	}
+}
+
+func TestKey_Literal(t *testing.T) {
+	k := NewKey("cw_literal", "")
+	if got := k.Value(); got != "cw_literal" {
+		t.Errorf("Value() = %q, want cw_literal", got)
+	}
+	if changed, err := k.Reload(); changed || err != nil {
+		t.Errorf("Reload() = %v, %v, want no change for a literal key", changed, err)
+	}
+}
+
+func TestKey_FileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	now := time.Now()
+	writeKey(t, path, "cw_first", now.Add(-time.Hour))
+
+	k := NewKey("cw_fallback", path)
+	if got := k.Value(); got != "cw_first" {
+		t.Fatalf("Value() = %q, want the key from the file", got)
+	}
+
+	writeKey(t, path, "cw_second", now)
+	if got := k.Value(); got != "cw_second" {
+		t.Errorf("Value() = %q, want the rotated key", got)
+	}
+
+	// A removed file keeps the last known key
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove key file: %v", err)
+	}
+	if got := k.Value(); got != "cw_second" {
+		t.Errorf("Value() = %q, want the last known key", got)
+	}
+	if _, err := k.Reload(); err == nil {
+		t.Error("expected Reload() to fail without a key file")
+	}
+}
+
+func TestKey_ReloadDetectsSameTimestamp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	stamp := time.Now().Add(-time.Hour)
+	writeKey(t, path, "cw_aaaa", stamp)
+
+	k := NewKey("", path)
+
+	// Same size and modification time: only a forced reload notices
+	writeKey(t, path, "cw_bbbb", stamp)
+	if got := k.Value(); got != "cw_aaaa" {
+		t.Fatalf("Value() = %q, expected the cached key", got)
+	}
+
+	changed, err := k.Reload()
+	if err != nil || !changed {
+		t.Fatalf("Reload() = %v, %v, want changed", changed, err)
+	}
+	if got := k.Value(); got != "cw_bbbb" {
+		t.Errorf("Value() = %q, want cw_bbbb", got)
+	}
+}
+
+func TestReadKeyFile_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("  \n"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	if _, err := ReadKeyFile(path); err == nil {
+		t.Error("expected an error for an empty key file")
+	}
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+// Errors a Controller returns for requests it can't serve
+var (
+	ErrUnknownTarget = errors.New("target is not monitored")
+	ErrOffline       = errors.New("agent is offline")
+)
+
+// Controller is what the control API reads results from and triggers
+// scans and syncs on
+type Controller interface {
+	// Results returns the latest scan result of every target
+	Results() []scanner.ScanResult
+	// Scan scans a target given as hostname:port, or every target if
+	// target is empty, and returns the new results
+	Scan(ctx context.Context, target string) ([]scanner.ScanResult, error)
+	// Sync syncs the latest results to CertWatch
+	Sync(ctx context.Context) error
+}
+
+// ControlServer serves the control API, a REST API to read scan results
+// and trigger scans and syncs. Every request needs the bearer token.
+type ControlServer struct {
+	httpServer *http.Server
+	controller Controller
+	logger     *zap.Logger
+	token      atomic.Pointer[string]
+}
+
+// ResultJSON is a scan result as served by the control API and written
+// to state dumps
+// Fields are ordered for optimal memory alignment
+type ResultJSON struct {
+	NotBefore         *time.Time           `json:"not_before,omitempty"`
+	NotAfter          *time.Time           `json:"not_after,omitempty"`
+	DaysUntilExpiry   *int                 `json:"days_until_expiry,omitempty"`
+	ChainValid        *bool                `json:"chain_valid,omitempty"`
+	PostQuantum       *bool                `json:"post_quantum,omitempty"` // nil without a TLS handshake
+	ChainIssues       []scanner.ChainIssue `json:"chain_issues,omitempty"`
+	SANList           []string             `json:"san_list,omitempty"`
+	Target            string               `json:"target"` // hostname:port
+	Hostname          string               `json:"hostname"`
+	Error             string               `json:"error,omitempty"`
+	Subject           string               `json:"subject,omitempty"`
+	Issuer            string               `json:"issuer,omitempty"`
+	IssuerOrg         string               `json:"issuer_org,omitempty"`
+	SerialNumber      string               `json:"serial_number,omitempty"`
+	FingerprintSHA256 string               `json:"fingerprint_sha256,omitempty"`
+	SPKISHA256        string               `json:"spki_sha256,omitempty"`
+	KeyType           string               `json:"key_type,omitempty"`
+	TLSVersion        string               `json:"tls_version,omitempty"`
+	KeyExchange       string               `json:"key_exchange,omitempty"`
+	ScannedAt         time.Time            `json:"scanned_at"`
+	DurationMS        int64                `json:"duration_ms"`
+	Port              int                  `json:"port"`
+	Success           bool                 `json:"success"`
+}
+
+// NewResultJSON converts a scan result for JSON output
+func NewResultJSON(r *scanner.ScanResult) ResultJSON {
+	j := ResultJSON{
+		Target:      fmt.Sprintf("%s:%d", r.Hostname, r.Port),
+		Hostname:    r.Hostname,
+		Port:        r.Port,
+		Success:     r.Success,
+		Error:       r.Error,
+		TLSVersion:  r.TLSVersion,
+		KeyExchange: r.KeyExchange,
+		ScannedAt:   r.ScannedAt,
+		DurationMS:  r.Timings.Total.Milliseconds(),
+	}
+	if r.TLSVersion != "" {
+		postQuantum := r.PostQuantum
+		j.PostQuantum = &postQuantum
+	}
+	if c := r.Certificate; c != nil {
+		notBefore, notAfter, days := c.NotBefore, c.NotAfter, c.DaysUntilExpiry
+		j.NotBefore = &notBefore
+		j.NotAfter = &notAfter
+		j.DaysUntilExpiry = &days
+		j.Subject = c.Subject
+		j.Issuer = c.Issuer
+		j.IssuerOrg = c.IssuerOrg
+		j.SerialNumber = c.SerialNumber
+		j.FingerprintSHA256 = c.FingerprintSHA256
+		j.SPKISHA256 = c.SPKISHA256
+		j.KeyType = c.KeyType
+		j.SANList = c.SANList
+	}
+	if r.Chain != nil {
+		valid := r.Chain.Valid
+		j.ChainValid = &valid
+		j.ChainIssues = r.Chain.Issues
+	}
+	return j
+}
+
+// scanRequest is the optional body of POST /api/v1/scan
+type scanRequest struct {
+	Target string `json:"target"` // host[:port]; every target if empty
+}
+
+// NewControl creates a control API server listening on addr
+func NewControl(addr, token string, controller Controller, logger *zap.Logger) *ControlServer {
+	s := &ControlServer{controller: controller, logger: logger}
+	s.SetToken(token)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/results", s.handleResults)
+	mux.HandleFunc("GET /api/v1/results/{target}", s.handleResult)
+	mux.HandleFunc("POST /api/v1/scan", s.handleScan)
+	mux.HandleFunc("POST /api/v1/sync", s.handleSync)
+
+	s.httpServer = &http.Server{
+		Addr:        addr,
+		Handler:     s.authenticate(mux),
+		ReadTimeout: 5 * time.Second,
+		// No write timeout: scans and syncs take as long as they take, and
+		// stop being waited for when the client goes away
+		IdleTimeout: 120 * time.Second,
+	}
+	return s
+}
+
+// SetToken changes the bearer token required by requests
+func (s *ControlServer) SetToken(token string) {
+	s.token.Store(&token)
+}
+
+// Handler returns the handler serving the API, including authentication
+func (s *ControlServer) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// Start binds the listen address and serves the control API in a
+// goroutine. It returns an error if the address can't be bound, so a
+// misconfigured control API stops the agent instead of going unnoticed.
+func (s *ControlServer) Start() error {
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start control API: %w", err)
+	}
+
+	s.logger.Info("starting control API", zap.String("addr", ln.Addr().String()))
+	go func() {
+		if err := s.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("control API error", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Shutdown gracefully shuts down the control API server.
+func (s *ControlServer) Shutdown(ctx context.Context) error {
+	s.logger.Info("shutting down control API")
+	return s.httpServer.Shutdown(ctx)
+}
+
+// authenticate rejects requests without the bearer token
+func (s *ControlServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(*s.token.Load())) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="cw-agent"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *ControlServer) handleResults(w http.ResponseWriter, _ *http.Request) {
+	writeResults(w, s.controller.Results())
+}
+
+func (s *ControlServer) handleResult(w http.ResponseWriter, r *http.Request) {
+	target, err := config.ParseTarget(r.PathValue("target"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	results := s.controller.Results()
+	for i := range results {
+		if results[i].Hostname == target.Hostname && results[i].Port == target.Port {
+			w.Header().Set("Content-Type", "application/json")
+			writeJSON(w, NewResultJSON(&results[i]))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "no scan result for "+target.GetHostPort())
+}
+
+func (s *ControlServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	var req scanRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	var key string
+	if req.Target != "" {
+		target, err := config.ParseTarget(req.Target)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		key = target.GetHostPort()
+	}
+
+	s.logger.Info("scan requested through the control API", zap.String("target", key))
+	results, err := s.controller.Scan(r.Context(), key)
+	if err != nil {
+		writeControlError(w, err)
+		return
+	}
+	writeResults(w, results)
+}
+
+func (s *ControlServer) handleSync(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("sync requested through the control API")
+	if err := s.controller.Sync(r.Context()); err != nil {
+		writeControlError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]any{
+		"status":    "synced",
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// writeResults writes scan results as {"results": [...]}
+func writeResults(w http.ResponseWriter, results []scanner.ScanResult) {
+	list := make([]ResultJSON, 0, len(results))
+	for i := range results {
+		list = append(list, NewResultJSON(&results[i]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]any{"results": list})
+}
+
+// writeControlError maps a Controller error to a status code
+func writeControlError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownTarget):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrOffline):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		writeError(w, http.StatusBadGateway, err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSON(w, map[string]any{"error": msg})
+}
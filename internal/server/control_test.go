@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+)
+
+const testToken = "0123456789abcdef"
+
+// fakeController records the requests of the control API
+type fakeController struct {
+	syncErr error
+	scanned []string
+	results []scanner.ScanResult
+}
+
+func (f *fakeController) Results() []scanner.ScanResult {
+	return f.results
+}
+
+func (f *fakeController) Scan(_ context.Context, target string) ([]scanner.ScanResult, error) {
+	f.scanned = append(f.scanned, target)
+	if target == "unknown.example.com:443" {
+		return nil, ErrUnknownTarget
+	}
+	return f.results, nil
+}
+
+func (f *fakeController) Sync(context.Context) error {
+	return f.syncErr
+}
+
+func request(t *testing.T, h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestControlServer_Authentication(t *testing.T) {
+	s := NewControl("127.0.0.1:0", testToken, &fakeController{}, zap.NewNop())
+
+	for _, token := range []string{"", "wrong-token-0000"} {
+		rec := request(t, s.Handler(), http.MethodGet, "/api/v1/results", token, "")
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("token %q: status = %d, want 401 with a challenge", token, rec.Code)
+		}
+	}
+	if rec := request(t, s.Handler(), http.MethodGet, "/api/v1/results", testToken, ""); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+
+	// Tokens can be rotated on reload
+	s.SetToken("fedcba9876543210")
+	if rec := request(t, s.Handler(), http.MethodGet, "/api/v1/results", testToken, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("old token: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestControlServer_Results(t *testing.T) {
+	c := &fakeController{results: []scanner.ScanResult{
+		{Hostname: "a.example.com", Port: 443, Success: true, TLSVersion: "TLS 1.3"},
+		{Hostname: "b.example.com", Port: 8443, Error: "timeout"},
+	}}
+	h := NewControl("127.0.0.1:0", testToken, c, zap.NewNop()).Handler()
+
+	rec := request(t, h, http.MethodGet, "/api/v1/results", testToken, "")
+	var all struct {
+		Results []ResultJSON `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil || len(all.Results) != 2 {
+		t.Fatalf("results = %s, error = %v", rec.Body.String(), err)
+	}
+	if r := all.Results[1]; r.Target != "b.example.com:8443" || r.Error != "timeout" || r.NotAfter != nil || r.PostQuantum != nil {
+		t.Errorf("second result = %+v", r)
+	}
+	// A classical key exchange is reported, not left out
+	if pq := all.Results[0].PostQuantum; pq == nil || *pq {
+		t.Errorf("first result post_quantum = %v, want false", pq)
+	}
+
+	tests := []struct {
+		path string
+		want int
+		host string
+	}{
+		{"/api/v1/results/a.example.com", http.StatusOK, "a.example.com"},
+		{"/api/v1/results/b.example.com:8443", http.StatusOK, "b.example.com"},
+		{"/api/v1/results/b.example.com", http.StatusNotFound, ""},
+		{"/api/v1/results/a.example.com:99999", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := request(t, h, http.MethodGet, tt.path, testToken, "")
+		if rec.Code != tt.want {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, rec.Code, tt.want)
+			continue
+		}
+		var r ResultJSON
+		if tt.host != "" && (json.Unmarshal(rec.Body.Bytes(), &r) != nil || r.Hostname != tt.host) {
+			t.Errorf("GET %s = %s", tt.path, rec.Body.String())
+		}
+	}
+}
+
+func TestControlServer_Scan(t *testing.T) {
+	c := &fakeController{results: []scanner.ScanResult{{Hostname: "a.example.com", Port: 443, Success: true}}}
+	h := NewControl("127.0.0.1:0", testToken, c, zap.NewNop()).Handler()
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{"", http.StatusOK},
+		{`{"target": "a.example.com"}`, http.StatusOK},
+		{`{"target": "unknown.example.com"}`, http.StatusNotFound},
+		{`{"target": "a.example.com:0"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := request(t, h, http.MethodPost, "/api/v1/scan", testToken, tt.body); rec.Code != tt.want {
+			t.Errorf("POST /api/v1/scan %q: status = %d, want %d: %s", tt.body, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+	if got := fmt.Sprint(c.scanned); got != "[ a.example.com:443 unknown.example.com:443]" {
+		t.Errorf("scanned = %s", got)
+	}
+
+	if rec := request(t, h, http.MethodGet, "/api/v1/scan", testToken, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /api/v1/scan: status = %d, want 405", rec.Code)
+	}
+}
+
+func TestControlServer_Sync(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{ErrOffline, http.StatusConflict},
+		{fmt.Errorf("sync failed: %w", context.DeadlineExceeded), http.StatusServiceUnavailable},
+		{fmt.Errorf("API returned 500"), http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		h := NewControl("127.0.0.1:0", testToken, &fakeController{syncErr: tt.err}, zap.NewNop()).Handler()
+		if rec := request(t, h, http.MethodPost, "/api/v1/sync", testToken, ""); rec.Code != tt.want {
+			t.Errorf("sync error %v: status = %d, want %d", tt.err, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestControlServer_StartFailsWhenAddressInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s := NewControl(ln.Addr().String(), testToken, &fakeController{}, zap.NewNop())
+	if err := s.Start(); err == nil {
+		_ = s.Shutdown(context.Background())
+		t.Fatal("Start() succeeded on an address in use")
+	}
+
+	s = NewControl("127.0.0.1:0", testToken, &fakeController{}, zap.NewNop())
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
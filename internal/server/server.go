@@ -1,4 +1,5 @@
-// Package server provides an HTTP server for metrics and health endpoints.
+// Package server provides HTTP servers for metrics and health endpoints and
+// for the local control API.
 package server
 
 import (
@@ -9,15 +9,21 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/alerting"
+	"github.com/certwatch-app/cw-agent/internal/config"
 )
 
 // State holds persisted agent state
 type State struct {
-	AgentID         string    `json:"agent_id"`
-	AgentName       string    `json:"agent_name"`
-	PreviousAgentID string    `json:"previous_agent_id,omitempty"` // For migration
-	LastSyncAt      time.Time `json:"last_sync_at,omitempty"`
-	LastUpdated     time.Time `json:"last_updated"`
+	AgentID           string                `json:"agent_id"`
+	AgentName         string                `json:"agent_name"`
+	PreviousAgentID   string                `json:"previous_agent_id,omitempty"` // For migration
+	RemoteTargetsETag string                `json:"remote_targets_etag,omitempty"`
+	RemoteTargets     []config.RemoteTarget `json:"remote_targets,omitempty"` // Cached for offline starts
+	Alerts            []alerting.Alert      `json:"alerts,omitempty"`         // Firing alerts
+	LastSyncAt        time.Time             `json:"last_sync_at,omitempty"`
+	LastUpdated       time.Time             `json:"last_updated"`
 }
 
 // Manager handles state persistence
@@ -174,6 +180,35 @@ func (m *Manager) SetLastSyncAt(t time.Time) {
 	m.state.LastSyncAt = t
 }
 
+// GetRemoteTargets returns the cached remote targets and their ETag
+func (m *Manager) GetRemoteTargets() ([]config.RemoteTarget, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state.RemoteTargets, m.state.RemoteTargetsETag
+}
+
+// SetRemoteTargets caches the remote targets and their ETag (call Save() to persist)
+func (m *Manager) SetRemoteTargets(targets []config.RemoteTarget, etag string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.RemoteTargets = targets
+	m.state.RemoteTargetsETag = etag
+}
+
+// GetAlerts returns the persisted firing alerts
+func (m *Manager) GetAlerts() []alerting.Alert {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state.Alerts
+}
+
+// SetAlerts sets the firing alerts (call Save() to persist)
+func (m *Manager) SetAlerts(alerts []alerting.Alert) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Alerts = alerts
+}
+
 // HasNameChanged checks if the config name differs from the persisted name
 // Returns false if no previous name is stored (first run)
 func (m *Manager) HasNameChanged(configName string) bool {
@@ -210,7 +245,37 @@ func (m *Manager) Reset() error {
 	return nil
 }
 
+// CheckWritable verifies that the state file can be written, without
+// touching an existing state file
+func (m *Manager) CheckWritable() error {
+	f, err := os.CreateTemp(filepath.Dir(m.filePath), stateFileName+".check-*")
+	if err != nil {
+		return fmt.Errorf("state directory is not writable: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("state directory is not writable: %w", err)
+	}
+
+	// An existing state file is rewritten in place
+	existing, err := os.OpenFile(m.filePath, os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("state file is not writable: %w", err)
+	}
+	return existing.Close()
+}
+
 // FilePath returns the path to the state file
 func (m *Manager) FilePath() string {
 	return m.filePath
 }
+
+// Dir returns the directory holding the state file, where other files the
+// agent keeps across restarts are stored too
+func (m *Manager) Dir() string {
+	return filepath.Dir(m.filePath)
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
 )
 
 func TestNewManager(t *testing.T) {
@@ -246,6 +249,35 @@ func TestPreviousAgentID(t *testing.T) {
 	}
 }
 
+func TestRemoteTargets(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "certwatch.yaml")
+
+	m := NewManager(configPath)
+	targets := []config.RemoteTarget{
+		{Hostname: "api.example.com", Port: 8443, Tags: []string{"ui"}, Notes: "Added in CertWatch"},
+		{Hostname: "www.example.com"},
+	}
+	m.SetRemoteTargets(targets, `"v1"`)
+
+	if err := m.Save(); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	m2 := NewManager(configPath)
+	if err := m2.Load(); err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+
+	got, etag := m2.GetRemoteTargets()
+	if etag != `"v1"` {
+		t.Errorf("expected ETag %q, got %q", `"v1"`, etag)
+	}
+	if !reflect.DeepEqual(got, targets) {
+		t.Errorf("expected remote targets to persist, got %+v", got)
+	}
+}
+
 func TestReset(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "certwatch.yaml")
@@ -372,3 +404,24 @@ func TestConcurrentAccess(t *testing.T) {
 
 	// If we get here without deadlock or race, the test passes
 }
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManagerWithStateDir(dir)
+
+	if err := m.CheckWritable(); err != nil {
+		t.Errorf("CheckWritable() error = %v for a writable directory", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read state dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the check to leave no files behind, found %d", len(entries))
+	}
+
+	missing := NewManagerWithStateDir(filepath.Join(dir, "missing"))
+	if err := missing.CheckWritable(); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
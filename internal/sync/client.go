@@ -7,12 +7,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	gosync "sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/outbox"
 	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/secret"
 	"github.com/certwatch-app/cw-agent/internal/state"
 	"github.com/certwatch-app/cw-agent/internal/version"
 )
@@ -20,26 +24,37 @@ import (
 // Client handles communication with the CertWatch API
 type Client struct {
 	endpoint          string
-	apiKey            string
+	apiKey            *secret.Key
+	userAgent         string
 	httpClient        *http.Client
 	logger            *zap.Logger
 	agentName         string
 	stateManager      *state.Manager
 	heartbeatInterval time.Duration
+
+	// Payloads waiting for delivery; nil sends payloads directly
+	outbox     *outbox.Outbox
+	pending    chan struct{} // Wakes RunOutbox up
+	retryAt    time.Time     // No delivery attempts before
+	retryDelay time.Duration
+	retryMu    gosync.Mutex // Guards retryAt and retryDelay, never held during requests
+	deliverMu  gosync.Mutex // Delivers queued payloads one at a time, in order
 }
 
 // New creates a new sync Client with state manager for agent ID persistence
 func New(cfg *config.Config, logger *zap.Logger, stateManager *state.Manager) *Client {
 	return &Client{
 		endpoint:          cfg.API.Endpoint,
-		apiKey:            cfg.API.Key,
+		apiKey:            secret.NewKey(cfg.API.Key, cfg.API.KeyFile),
+		userAgent:         fmt.Sprintf("cw-agent/%s", version.GetVersion()),
 		agentName:         cfg.Agent.Name,
 		stateManager:      stateManager,
 		heartbeatInterval: cfg.Agent.HeartbeatInterval,
 		httpClient: &http.Client{
 			Timeout: cfg.API.Timeout,
 		},
-		logger: logger,
+		logger:  logger,
+		pending: make(chan struct{}, 1),
 	}
 }
 
@@ -49,28 +64,33 @@ func (c *Client) Sync(ctx context.Context, certs []config.CertificateConfig, res
 	req := c.buildSyncRequest(certs, results)
 
 	// Send request
-	resp, err := c.doRequest(ctx, "POST", "/api/v1/agent/sync", req)
+	resp, err := c.doRequest(ctx, "/api/v1/agent/sync", req)
 	if err != nil {
 		return nil, err
 	}
 
 	// Persist agent ID and name for future restarts
 	if resp.Success && resp.AgentID != "" {
-		c.stateManager.SetAgentID(resp.AgentID)
-		c.stateManager.SetAgentName(c.agentName)
-		c.stateManager.SetLastSyncAt(resp.Data.SyncedAt)
+		c.saveSyncState(resp.AgentID, resp.Data.SyncedAt, resp.Data.Migrated)
+	}
 
-		// Clear previous agent ID after successful migration
-		if c.stateManager.GetPreviousAgentID() != "" && resp.Data.Migrated > 0 {
-			c.stateManager.ClearPreviousAgentID()
-		}
+	return resp, nil
+}
 
-		if err := c.stateManager.Save(); err != nil {
-			c.logger.Warn("failed to save state", zap.Error(err))
-		}
+// saveSyncState persists the agent ID the API assigned in a sync response
+func (c *Client) saveSyncState(agentID string, syncedAt time.Time, migrated int) {
+	c.stateManager.SetAgentID(agentID)
+	c.stateManager.SetAgentName(c.agentName)
+	c.stateManager.SetLastSyncAt(syncedAt)
+
+	// Clear previous agent ID after successful migration
+	if c.stateManager.GetPreviousAgentID() != "" && migrated > 0 {
+		c.stateManager.ClearPreviousAgentID()
 	}
 
-	return resp, nil
+	if err := c.stateManager.Save(); err != nil {
+		c.logger.Warn("failed to save state", zap.Error(err))
+	}
 }
 
 // Heartbeat sends a heartbeat to the CertWatch API
@@ -106,41 +126,88 @@ func (c *Client) Heartbeat(ctx context.Context, certCount int, lastScan, lastSyn
 // ErrAgentNotFound is returned when the agent ID is no longer valid on the server
 var ErrAgentNotFound = fmt.Errorf("agent not found")
 
-func (c *Client) doHeartbeatRequest(ctx context.Context, body *HeartbeatRequest) (*HeartbeatResponse, error) {
-	url := c.endpoint + "/api/v1/agent/heartbeat"
-
-	jsonData, err := json.Marshal(body)
+// ErrUnauthorized is returned when the API rejects the API key
+var ErrUnauthorized = fmt.Errorf("API key rejected")
+
+// VerifyAPIKey checks that the API accepts the API key. It fetches the
+// agent's targets, which is read-only, so no agent is registered or
+// updated. A 2xx response, or a 404 with the API's JSON error for an agent
+// it doesn't know yet, means the key works. A 401 or 403 means it was
+// rejected; any other status, such as a plain 404 from a wrong endpoint, is
+// an error.
+func (c *Client) VerifyAPIKey(ctx context.Context) error {
+	query := url.Values{"agent_name": {c.agentName}}
+	status, body, err := c.send(ctx, "GET", "/api/v1/agent/targets?"+query.Encode(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal heartbeat request: %w", err)
+		return fmt.Errorf("request failed: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create heartbeat request: %w", err)
+	switch {
+	case status >= 200 && status <= 299:
+		return nil
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return fmt.Errorf("%w: %v", ErrUnauthorized, apiError(status, body))
+	case status == http.StatusNotFound && isAPIError(body):
+		return nil
 	}
+	return apiError(status, body)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("User-Agent", fmt.Sprintf("cw-agent/%s", version.GetVersion()))
+// ErrNotModified is returned by FetchTargets when the target list hasn't
+// changed since the given ETag
+var ErrNotModified = fmt.Errorf("not modified")
 
-	resp, err := c.httpClient.Do(req)
+// FetchTargets fetches the targets managed in CertWatch for this agent. If
+// etag is set and the list hasn't changed, ErrNotModified is returned.
+// The returned ETag identifies the list for the next fetch.
+func (c *Client) FetchTargets(ctx context.Context, etag string) ([]config.RemoteTarget, string, error) {
+	query := url.Values{"agent_name": {c.agentName}}
+	if agentID := c.stateManager.GetAgentID(); agentID != "" {
+		query.Set("agent_id", agentID)
+	}
+
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+
+	resp, err := c.request(ctx, "GET", "/api/v1/agent/targets?"+query.Encode(), nil, header)
 	if err != nil {
-		return nil, fmt.Errorf("heartbeat request failed: %w", err)
+		return nil, "", fmt.Errorf("targets request failed: %w", err)
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	switch {
+	case resp.status == http.StatusNotModified:
+		return nil, etag, ErrNotModified
+	case resp.status >= 400:
+		return nil, "", apiError(resp.status, resp.body)
+	}
+
+	var targetsResp TargetsResponse
+	if err := json.Unmarshal(resp.body, &targetsResp); err != nil {
+		return nil, "", fmt.Errorf("failed to parse targets response: %w", err)
+	}
+	if !targetsResp.Success && targetsResp.Error != nil {
+		return nil, "", fmt.Errorf("API error (%s): %s", targetsResp.Error.Code, targetsResp.Error.Message)
+	}
+
+	return targetsResp.Targets, resp.header.Get("ETag"), nil
+}
+
+func (c *Client) doHeartbeatRequest(ctx context.Context, body *HeartbeatRequest) (*HeartbeatResponse, error) {
+	// Only the latest heartbeat is worth delivering
+	status, respBody, err := c.post(ctx, kindHeartbeat, "/api/v1/agent/heartbeat", body, true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read heartbeat response: %w", err)
+		return nil, fmt.Errorf("heartbeat request failed: %w", err)
 	}
 
 	// Handle 404 - agent was deleted from server
-	if resp.StatusCode == 404 {
+	if status == 404 {
 		return nil, ErrAgentNotFound
 	}
 
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("heartbeat API returned status %d: %s", resp.StatusCode, string(respBody))
+	if status >= 400 {
+		return nil, fmt.Errorf("heartbeat API returned status %d: %s", status, string(respBody))
 	}
 
 	var heartbeatResp HeartbeatResponse
@@ -165,11 +232,16 @@ func (c *Client) buildSyncRequest(certs []config.CertificateConfig, results []sc
 		key := fmt.Sprintf("%s:%d", cert.Hostname, cert.Port)
 		data := CertificateSyncData{
 			Hostname: cert.Hostname,
+			Source:   SourceConfig,
 			Port:     cert.Port,
 			Tags:     cert.Tags,
 			Notes:    cert.Notes,
 		}
 
+		if cert.Remote {
+			data.Source = SourceRemote
+		}
+
 		// Add scan results if available
 		if result, ok := resultMap[key]; ok {
 			scannedAt := result.ScannedAt
@@ -182,15 +254,20 @@ func (c *Client) buildSyncRequest(certs []config.CertificateConfig, results []sc
 				data.IssuerOrg = info.IssuerOrg
 				data.SerialNumber = info.SerialNumber
 				data.FingerprintSHA256 = info.FingerprintSHA256
+				data.SPKISHA256 = info.SPKISHA256
 				data.NotBefore = &info.NotBefore
 				data.NotAfter = &info.NotAfter
 				data.SANList = info.SANList
+				data.TLSVersion = result.TLSVersion
+				data.KeyExchange = result.KeyExchange
+				data.PostQuantum = &result.PostQuantum
 
 				if result.Chain != nil {
 					data.ChainValid = &result.Chain.Valid
 					for _, issue := range result.Chain.Issues {
 						data.ChainIssues = append(data.ChainIssues, ChainIssueData{
 							Type:             issue.Type,
+							Severity:         issue.Severity,
 							Message:          issue.Message,
 							CertificateIndex: issue.CertificateIndex,
 						})
@@ -223,35 +300,102 @@ func (c *Client) buildSyncRequest(certs []config.CertificateConfig, results []sc
 	}
 }
 
-func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}) (*SyncResponse, error) {
-	url := c.endpoint + path
+func (c *Client) doRequest(ctx context.Context, path string, body interface{}) (*SyncResponse, error) {
+	c.logger.Debug("sending sync request",
+		zap.String("url", c.endpoint+path),
+	)
+
+	status, respBody, err := c.post(ctx, kindSync, path, body, false)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
 
-	var bodyReader io.Reader
+	if status >= 400 {
+		var errResp struct {
+			Error   *APIError `json:"error"`
+			Success bool      `json:"success"`
+		}
+		if unmarshalErr := json.Unmarshal(respBody, &errResp); unmarshalErr == nil && errResp.Error != nil {
+			return nil, fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("API returned status %d: %s", status, string(respBody))
+	}
+
+	var syncResp SyncResponse
+	if err := json.Unmarshal(respBody, &syncResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &syncResp, nil
+}
+
+// send performs an API request and returns the response status and body
+func (c *Client) send(ctx context.Context, method, path string, body interface{}) (int, []byte, error) {
+	resp, err := c.request(ctx, method, path, body, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.status, resp.body, nil
+}
+
+// response is a completed API response
+type response struct {
+	header http.Header
+	body   []byte
+	status int
+}
+
+// request performs an API request with additional request headers.
+// If the API rejects the key, the key file is re-read and the request is
+// retried once, so a rotated key is picked up before a failure is reported.
+func (c *Client) request(ctx context.Context, method, path string, body interface{}, header http.Header) (*response, error) {
+	var jsonData []byte
 	if body != nil {
-		jsonData, err := json.Marshal(body)
+		var err error
+		jsonData, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
+	}
+
+	resp, err := c.sendOnce(ctx, method, path, jsonData, header)
+	if err != nil || resp.status != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	changed, reloadErr := c.apiKey.Reload()
+	if reloadErr != nil {
+		c.logger.Warn("API key rejected and key file could not be re-read", zap.Error(reloadErr))
+	}
+	if !changed {
+		return resp, nil
+	}
+
+	c.logger.Info("API key rejected, retrying with the updated key from key_file")
+	return c.sendOnce(ctx, method, path, jsonData, header)
+}
+
+func (c *Client) sendOnce(ctx context.Context, method, path string, jsonData []byte, header http.Header) (*response, error) {
+	var bodyReader io.Reader
+	if jsonData != nil {
 		bodyReader = bytes.NewReader(jsonData)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
+	for name, values := range header {
+		req.Header[name] = values
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("User-Agent", fmt.Sprintf("cw-agent/%s", version.GetVersion()))
-
-	c.logger.Debug("sending sync request",
-		zap.String("url", url),
-		zap.String("method", method),
-	)
+	req.Header.Set("X-API-Key", c.apiKey.Value())
+	req.Header.Set("User-Agent", c.userAgent)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -265,23 +409,12 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 		zap.Int("body_length", len(respBody)),
 	)
 
-	if resp.StatusCode >= 400 {
-		var errResp struct {
-			Error   *APIError `json:"error"`
-			Success bool      `json:"success"`
-		}
-		if unmarshalErr := json.Unmarshal(respBody, &errResp); unmarshalErr == nil && errResp.Error != nil {
-			return nil, fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
-		}
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var syncResp SyncResponse
-	if err := json.Unmarshal(respBody, &syncResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
+	return &response{header: resp.Header, body: respBody, status: resp.StatusCode}, nil
+}
 
-	return &syncResp, nil
+// SetAPIKey replaces the API key, e.g. after a configuration reload
+func (c *Client) SetAPIKey(key, keyFile string) {
+	c.apiKey.Set(key, keyFile)
 }
 
 // GetAgentID returns the persisted agent ID (empty if not yet synced)
@@ -303,9 +436,10 @@ func getHostname() string {
 
 // ClientConfig holds configuration for creating a sync client without the full config package
 type ClientConfig struct {
-	Endpoint string
-	APIKey   string
-	Timeout  time.Duration
+	Endpoint   string
+	APIKey     string
+	APIKeyFile string // Takes precedence over APIKey; re-read when it changes
+	Timeout    time.Duration
 }
 
 // NewWithConfig creates a new sync Client with explicit configuration
@@ -318,13 +452,15 @@ func NewWithConfig(cfg *ClientConfig, agentName string, logger *zap.Logger, stat
 
 	return &Client{
 		endpoint:     cfg.Endpoint,
-		apiKey:       cfg.APIKey,
+		apiKey:       secret.NewKey(cfg.APIKey, cfg.APIKeyFile),
+		userAgent:    fmt.Sprintf("cw-agent-certmanager/%s", version.GetVersion()),
 		agentName:    agentName,
 		stateManager: stateManager,
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
-		logger: logger,
+		logger:  logger,
+		pending: make(chan struct{}, 1),
 	}
 }
 
@@ -338,54 +474,17 @@ func (c *Client) SyncCertManagerCertificates(ctx context.Context, clusterName st
 		Certificates: certs,
 	}
 
-	url := c.endpoint + "/api/v1/agent/certmanager/sync"
-
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("X-API-Key", c.apiKey)
-	httpReq.Header.Set("User-Agent", fmt.Sprintf("cw-agent-certmanager/%s", version.GetVersion()))
-
 	c.logger.Debug("sending certmanager sync request",
-		zap.String("url", url),
+		zap.String("url", c.endpoint+"/api/v1/agent/certmanager/sync"),
 		zap.Int("certificates", len(certs)),
-		zap.Bool("api_key_present", c.apiKey != ""),
-		zap.Int("api_key_length", len(c.apiKey)),
 	)
 
-	resp, err := c.httpClient.Do(httpReq)
+	status, body, err := c.post(ctx, kindCertManagerSync, "/api/v1/agent/certmanager/sync", req, false)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	c.logger.Debug("received response",
-		zap.Int("status", resp.StatusCode),
-		zap.Int("body_length", len(body)),
-	)
-
-	if resp.StatusCode >= 400 {
-		var errResp struct {
-			Error   *APIError `json:"error"`
-			Success bool      `json:"success"`
-		}
-		if unmarshalErr := json.Unmarshal(body, &errResp); unmarshalErr == nil && errResp.Error != nil {
-			return nil, fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
-		}
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+	if status >= 400 {
+		return nil, apiError(status, body)
 	}
 
 	var syncResp CertManagerSyncResponse
@@ -395,12 +494,7 @@ func (c *Client) SyncCertManagerCertificates(ctx context.Context, clusterName st
 
 	// Persist agent ID for future syncs
 	if syncResp.Success && syncResp.AgentID != "" {
-		c.stateManager.SetAgentID(syncResp.AgentID)
-		c.stateManager.SetAgentName(c.agentName)
-		c.stateManager.SetLastSyncAt(syncResp.Data.SyncedAt)
-		if err := c.stateManager.Save(); err != nil {
-			c.logger.Warn("failed to save state", zap.Error(err))
-		}
+		c.saveSyncState(syncResp.AgentID, syncResp.Data.SyncedAt, 0)
 	}
 
 	return &syncResp, nil
@@ -419,52 +513,17 @@ func (c *Client) SyncCertManagerEvents(ctx context.Context, clusterName string,
 		Events:      events,
 	}
 
-	url := c.endpoint + "/api/v1/agent/certmanager/events"
-
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("X-API-Key", c.apiKey)
-	httpReq.Header.Set("User-Agent", fmt.Sprintf("cw-agent-certmanager/%s", version.GetVersion()))
-
 	c.logger.Debug("sending certmanager event sync request",
-		zap.String("url", url),
+		zap.String("url", c.endpoint+"/api/v1/agent/certmanager/events"),
 		zap.Int("events", len(events)),
 	)
 
-	resp, err := c.httpClient.Do(httpReq)
+	status, body, err := c.post(ctx, kindCertManagerEvents, "/api/v1/agent/certmanager/events", req, false)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
-	}
-
-	c.logger.Debug("received response",
-		zap.Int("status", resp.StatusCode),
-		zap.Int("body_length", len(body)),
-	)
-
-	if resp.StatusCode >= 400 {
-		var errResp struct {
-			Error   *APIError `json:"error"`
-			Success bool      `json:"success"`
-		}
-		if unmarshalErr := json.Unmarshal(body, &errResp); unmarshalErr == nil && errResp.Error != nil {
-			return fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
-		}
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+	if status >= 400 {
+		return apiError(status, body)
 	}
 
 	return nil
@@ -483,53 +542,38 @@ func (c *Client) SyncCertManagerRequests(ctx context.Context, clusterName string
 		Requests:    requests,
 	}
 
-	url := c.endpoint + "/api/v1/agent/certmanager/requests"
-
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("X-API-Key", c.apiKey)
-	httpReq.Header.Set("User-Agent", fmt.Sprintf("cw-agent-certmanager/%s", version.GetVersion()))
-
 	c.logger.Debug("sending certmanager request sync",
-		zap.String("url", url),
+		zap.String("url", c.endpoint+"/api/v1/agent/certmanager/requests"),
 		zap.Int("requests", len(requests)),
 	)
 
-	resp, err := c.httpClient.Do(httpReq)
+	status, body, err := c.post(ctx, kindCertManagerRequests, "/api/v1/agent/certmanager/requests", req, false)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+	if status >= 400 {
+		return apiError(status, body)
 	}
 
-	c.logger.Debug("received response",
-		zap.Int("status", resp.StatusCode),
-		zap.Int("body_length", len(body)),
-	)
+	return nil
+}
 
-	if resp.StatusCode >= 400 {
-		var errResp struct {
-			Error   *APIError `json:"error"`
-			Success bool      `json:"success"`
-		}
-		if unmarshalErr := json.Unmarshal(body, &errResp); unmarshalErr == nil && errResp.Error != nil {
-			return fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
-		}
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+// isAPIError reports whether body is the API's JSON error response
+func isAPIError(body []byte) bool {
+	var errResp struct {
+		Error *APIError `json:"error"`
 	}
+	return json.Unmarshal(body, &errResp) == nil && errResp.Error != nil
+}
 
-	return nil
+// apiError converts an error response of the cert-manager endpoints to an error
+func apiError(status int, body []byte) error {
+	var errResp struct {
+		Error   *APIError `json:"error"`
+		Success bool      `json:"success"`
+	}
+	if unmarshalErr := json.Unmarshal(body, &errResp); unmarshalErr == nil && errResp.Error != nil {
+		return fmt.Errorf("API error (%s): %s", errResp.Error.Code, errResp.Error.Message)
+	}
+	return fmt.Errorf("API error %d: %s", status, string(body))
 }
@@ -0,0 +1,218 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
+	"github.com/certwatch-app/cw-agent/internal/scanner"
+	"github.com/certwatch-app/cw-agent/internal/state"
+)
+
+// newKeyServer starts an API stub that accepts only the given key
+func newKeyServer(t *testing.T, validKey string, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("X-API-Key") != validKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"success":false,"error":{"code":"UNAUTHORIZED","message":"invalid API key"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":true,"agent_id":"agent-1"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_RetriesWithRotatedKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(keyFile, []byte("cw_old"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+
+	var requests atomic.Int32
+	srv := newKeyServer(t, "cw_new", &requests)
+
+	c := NewWithConfig(&ClientConfig{Endpoint: srv.URL, APIKeyFile: keyFile, Timeout: time.Second},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+
+	// Rotate the key with the same size and timestamp, so only the
+	// re-read after the 401 picks it up
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatalf("failed to stat key file: %v", err)
+	}
+	if err := os.WriteFile(keyFile, []byte("cw_new"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	if err := os.Chtimes(keyFile, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("failed to set key file time: %v", err)
+	}
+
+	if _, err := c.SyncCertManagerCertificates(context.Background(), "cluster", nil); err != nil {
+		t.Fatalf("SyncCertManagerCertificates() error = %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want the rejected one and one retry", got)
+	}
+}
+
+func TestClient_UnauthorizedWithoutRotation(t *testing.T) {
+	var requests atomic.Int32
+	srv := newKeyServer(t, "cw_valid", &requests)
+
+	c := NewWithConfig(&ClientConfig{Endpoint: srv.URL, APIKey: "cw_invalid", Timeout: time.Second},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+
+	_, err := c.SyncCertManagerCertificates(context.Background(), "cluster", nil)
+	if err == nil || !strings.Contains(err.Error(), "UNAUTHORIZED") {
+		t.Errorf("error = %v, want the API's unauthorized error", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want no retry without a new key", got)
+	}
+}
+
+func TestClient_VerifyAPIKey(t *testing.T) {
+	var requests atomic.Int32
+	srv := newKeyServer(t, "cw_valid", &requests)
+
+	valid := NewWithConfig(&ClientConfig{Endpoint: srv.URL, APIKey: "cw_valid", Timeout: time.Second},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+	if err := valid.VerifyAPIKey(context.Background()); err != nil {
+		t.Errorf("VerifyAPIKey() error = %v for a valid key", err)
+	}
+
+	invalid := NewWithConfig(&ClientConfig{Endpoint: srv.URL, APIKey: "cw_invalid", Timeout: time.Second},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+	if err := invalid.VerifyAPIKey(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("VerifyAPIKey() error = %v, want ErrUnauthorized", err)
+	}
+
+	// An agent the API doesn't know yet doesn't make the key invalid, and
+	// the check doesn't report the agent's status
+	var method string
+	unknown := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"success":false,"error":{"code":"AGENT_NOT_FOUND","message":"agent not found"}}`))
+	}))
+	t.Cleanup(unknown.Close)
+	newAgent := NewWithConfig(&ClientConfig{Endpoint: unknown.URL, APIKey: "cw_valid", Timeout: time.Second},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+	if err := newAgent.VerifyAPIKey(context.Background()); err != nil {
+		t.Errorf("VerifyAPIKey() error = %v for an unknown agent", err)
+	}
+	if method != http.MethodGet {
+		t.Errorf("VerifyAPIKey() sent a %s request, want a read-only GET", method)
+	}
+
+	// A wrong endpoint doesn't authenticate the key
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(notFound.Close)
+	wrong := NewWithConfig(&ClientConfig{Endpoint: notFound.URL, APIKey: "cw_valid", Timeout: time.Second},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+	err := wrong.VerifyAPIKey(context.Background())
+	if err == nil || errors.Is(err, ErrUnauthorized) || !strings.Contains(err.Error(), "404") {
+		t.Errorf("VerifyAPIKey() error = %v, want the 404", err)
+	}
+}
+
+func TestClient_FetchTargets(t *testing.T) {
+	const etag = `"v2"`
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(`{"success":true,"targets":[{"hostname":"api.example.com","port":8443,"tags":["ui"]}]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewWithConfig(&ClientConfig{Endpoint: srv.URL, APIKey: "cw_valid", Timeout: time.Second},
+		"edge agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+
+	targets, got, err := c.FetchTargets(context.Background(), `"v1"`)
+	if err != nil {
+		t.Fatalf("FetchTargets() error = %v", err)
+	}
+	if got != etag {
+		t.Errorf("ETag = %q, want %q", got, etag)
+	}
+	if len(targets) != 1 || targets[0].Hostname != "api.example.com" || targets[0].Port != 8443 {
+		t.Errorf("targets = %+v", targets)
+	}
+	if query != "agent_name=edge+agent" {
+		t.Errorf("query = %q, want the agent name", query)
+	}
+
+	targets, got, err = c.FetchTargets(context.Background(), etag)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("FetchTargets() error = %v, want ErrNotModified", err)
+	}
+	if got != etag || targets != nil {
+		t.Errorf("FetchTargets() = %v, %q for an unchanged list", targets, got)
+	}
+}
+
+func TestBuildSyncRequest_Source(t *testing.T) {
+	c := NewWithConfig(&ClientConfig{Endpoint: "http://localhost", APIKey: "cw_valid"},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+
+	req := c.buildSyncRequest([]config.CertificateConfig{
+		{Hostname: "local.example.com", Port: 443},
+		{Hostname: "api.example.com", Port: 443, Remote: true},
+	}, nil)
+
+	if got := req.Certificates[0].Source; got != SourceConfig {
+		t.Errorf("source = %q, want %q", got, SourceConfig)
+	}
+	if got := req.Certificates[1].Source; got != SourceRemote {
+		t.Errorf("source = %q, want %q", got, SourceRemote)
+	}
+}
+
+func TestBuildSyncRequest_PostQuantum(t *testing.T) {
+	c := NewWithConfig(&ClientConfig{Endpoint: "http://localhost", APIKey: "cw_valid"},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+
+	req := c.buildSyncRequest([]config.CertificateConfig{
+		{Hostname: "classical.example.com", Port: 443},
+		{Hostname: "down.example.com", Port: 443},
+	}, []scanner.ScanResult{
+		{Hostname: "classical.example.com", Port: 443, Success: true, TLSVersion: "TLS 1.3",
+			KeyExchange: "X25519", Certificate: &scanner.CertificateInfo{}},
+		{Hostname: "down.example.com", Port: 443, Error: "connection refused"},
+	})
+
+	data, err := json.Marshal(req.Certificates)
+	if err != nil {
+		t.Fatalf("failed to marshal certificates: %v", err)
+	}
+	var certs []map[string]any
+	if err := json.Unmarshal(data, &certs); err != nil {
+		t.Fatalf("failed to unmarshal certificates: %v", err)
+	}
+	// A classical key exchange is sent as false, unknown ones not at all
+	if pq, ok := certs[0]["post_quantum"]; !ok || pq != false {
+		t.Errorf("post_quantum = %v, want false for a classical key exchange", pq)
+	}
+	if pq, ok := certs[1]["post_quantum"]; ok {
+		t.Errorf("post_quantum = %v, want it left out without a handshake", pq)
+	}
+}
@@ -0,0 +1,254 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/outbox"
+)
+
+// Outbox message kinds
+const (
+	kindSync                = "sync"
+	kindHeartbeat           = "heartbeat"
+	kindCertManagerSync     = "certmanager_sync"
+	kindCertManagerEvents   = "certmanager_events"
+	kindCertManagerRequests = "certmanager_requests"
+)
+
+// Delays between delivery attempts while the API can't be reached. The
+// delay doubles after every failed attempt.
+const (
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// ErrQueued is returned when a payload couldn't be delivered yet and stays
+// in the outbox to be retried
+var ErrQueued = fmt.Errorf("payload queued for retry")
+
+// SetOutbox makes the client queue sync, heartbeat and event payloads in o
+// before sending them, so payloads that can't be delivered are retried by
+// RunOutbox instead of being lost. Only the latest heartbeat is kept.
+func (c *Client) SetOutbox(o *outbox.Outbox) {
+	c.outbox = o
+}
+
+// Backlog describes the payloads waiting in the outbox
+type Backlog struct {
+	NextRetry *time.Time `json:"next_retry,omitempty"` // Set while delivery is backing off
+	Messages  int        `json:"messages"`
+	Bytes     int64      `json:"bytes"`
+}
+
+// Backlog returns the payloads waiting in the outbox; none without one
+func (c *Client) Backlog() Backlog {
+	if c.outbox == nil {
+		return Backlog{}
+	}
+	b := Backlog{Messages: c.outbox.Len(), Bytes: c.outbox.Bytes()}
+
+	// Not c.deliverMu, which is held while the API is slow to answer
+	c.retryMu.Lock()
+	defer c.retryMu.Unlock()
+	if b.Messages > 0 && c.retryAt.After(time.Now()) {
+		retryAt := c.retryAt
+		b.NextRetry = &retryAt
+	}
+	return b
+}
+
+// RunOutbox delivers queued payloads in the background until ctx is done:
+// those queued before a restart, and those left behind while the API
+// couldn't be reached, once the retry delay has passed
+func (c *Client) RunOutbox(ctx context.Context) {
+	if c.outbox == nil {
+		return
+	}
+	if c.outbox.Len() > 0 {
+		c.signalPending()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.pending:
+		}
+
+		replayed := 0
+		for c.outbox.Len() > 0 {
+			timer := time.NewTimer(c.untilRetry())
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			resp, err := c.deliverNext(ctx, nil)
+			if err != nil {
+				c.logger.Debug("outbox delivery failed",
+					zap.Int("queued", c.outbox.Len()),
+					zap.Error(err),
+				)
+				continue
+			}
+			if resp != nil && resp.status < 400 {
+				replayed++
+			}
+		}
+		if replayed > 0 {
+			c.logger.Info("delivered queued payloads", zap.Int("delivered", replayed))
+		}
+	}
+}
+
+// post sends a payload, through the outbox if there is one, and returns
+// the response to it. With supersede, queued payloads of the same kind are
+// dropped. A payload queued behind older ones is left to RunOutbox, so
+// payloads are delivered in order without blocking the caller.
+func (c *Client) post(ctx context.Context, kind, path string, body interface{}, supersede bool) (int, []byte, error) {
+	if c.outbox == nil {
+		return c.send(ctx, "POST", path, body)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	m, err := c.outbox.Push(kind, path, data, supersede)
+	if err != nil {
+		c.logger.Warn("failed to queue payload, sending it directly", zap.String("kind", kind), zap.Error(err))
+		return c.send(ctx, "POST", path, json.RawMessage(data))
+	}
+
+	resp, err := c.deliverNext(ctx, m)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.status, resp.body, nil
+}
+
+// deliverNext sends the oldest queued message. With a target, only the
+// target is sent, and only if no older message is queued before it. If the
+// API can't be reached, the message stays queued and delivery is retried
+// after a delay. c.deliverMu is held for a single message at a time.
+func (c *Client) deliverNext(ctx context.Context, target *outbox.Message) (*response, error) {
+	c.deliverMu.Lock()
+	defer c.deliverMu.Unlock()
+
+	if wait := c.untilRetry(); wait > 0 {
+		return nil, fmt.Errorf("%w in %s (%d payloads queued)", ErrQueued, wait.Round(time.Second), c.outbox.Len())
+	}
+
+	m := c.outbox.Peek()
+	switch {
+	case m == nil && target == nil:
+		return nil, nil
+	case target != nil && (m == nil || m.Seq() > target.Seq()):
+		return nil, fmt.Errorf("%s payload was dropped from the outbox", target.Kind)
+	case target != nil && m.Seq() < target.Seq():
+		c.signalPending()
+		return nil, fmt.Errorf("%w behind older payloads (%d payloads queued)", ErrQueued, c.outbox.Len())
+	}
+	own := target != nil
+
+	resp, err := c.request(ctx, "POST", m.Path, m.Body, nil)
+	if err == nil && retryable(resp.status) {
+		err = apiError(resp.status, resp.body)
+	}
+	if err != nil {
+		delay := c.retryLater()
+		return nil, fmt.Errorf("%w in %s (%d payloads queued): %v", ErrQueued, delay, c.outbox.Len(), err)
+	}
+	c.resetRetry()
+
+	// The caller handles the response to its own payload
+	if resp.status >= 400 {
+		c.outbox.Drop(m, outbox.DropRejected)
+		if !own {
+			c.logger.Warn("API rejected queued payload, dropping it",
+				zap.String("kind", m.Kind),
+				zap.Time("queued_at", m.CreatedAt),
+				zap.Error(apiError(resp.status, resp.body)),
+			)
+		}
+	} else {
+		c.outbox.Delivered(m)
+		if !own {
+			c.replayed(m, resp)
+		}
+	}
+	return resp, nil
+}
+
+// retryable returns true for responses that may succeed later, like those
+// of an overloaded API. A rejected key is retried as it may be rotated.
+func retryable(status int) bool {
+	return status == http.StatusUnauthorized ||
+		status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests ||
+		status >= 500
+}
+
+// retryLater schedules the next delivery attempt and returns its delay
+func (c *Client) retryLater() time.Duration {
+	c.retryMu.Lock()
+	c.retryDelay = min(max(2*c.retryDelay, minRetryDelay), maxRetryDelay)
+	c.retryAt = time.Now().Add(c.retryDelay)
+	delay := c.retryDelay
+	c.retryMu.Unlock()
+
+	outbox.RetriesTotal.Inc()
+	c.signalPending()
+	return delay
+}
+
+// resetRetry resets the retry delay after a successful delivery
+func (c *Client) resetRetry() {
+	c.retryMu.Lock()
+	defer c.retryMu.Unlock()
+	c.retryDelay = 0
+}
+
+// untilRetry returns how long to wait before the next delivery attempt
+func (c *Client) untilRetry() time.Duration {
+	c.retryMu.Lock()
+	defer c.retryMu.Unlock()
+	return max(time.Until(c.retryAt), 0)
+}
+
+// signalPending wakes RunOutbox up to deliver queued messages
+func (c *Client) signalPending() {
+	select {
+	case c.pending <- struct{}{}:
+	default: // A wake-up is already pending
+	}
+}
+
+// replayed processes the response to a payload queued by an earlier call.
+// Sync responses register the agent, as they would have when sent directly.
+func (c *Client) replayed(m *outbox.Message, resp *response) {
+	c.logger.Debug("delivered queued payload",
+		zap.String("kind", m.Kind),
+		zap.Time("queued_at", m.CreatedAt),
+	)
+
+	switch m.Kind {
+	case kindSync:
+		var r SyncResponse
+		if json.Unmarshal(resp.body, &r) == nil && r.Success && r.AgentID != "" {
+			c.saveSyncState(r.AgentID, r.Data.SyncedAt, r.Data.Migrated)
+		}
+	case kindCertManagerSync:
+		var r CertManagerSyncResponse
+		if json.Unmarshal(resp.body, &r) == nil && r.Success && r.AgentID != "" {
+			c.saveSyncState(r.AgentID, r.Data.SyncedAt, 0)
+		}
+	}
+}
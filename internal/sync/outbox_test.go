@@ -0,0 +1,222 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/certwatch-app/cw-agent/internal/outbox"
+	"github.com/certwatch-app/cw-agent/internal/state"
+)
+
+// eventServer is an API stub recording the reasons of the cert-manager
+// events it accepts, and answering with status while it's set
+type eventServer struct {
+	reasons  []string
+	status   int
+	requests int
+	mu       gosync.Mutex
+}
+
+func (s *eventServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	var req CertManagerEventSyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, e := range req.Events {
+		s.reasons = append(s.reasons, e.Reason)
+	}
+	_, _ = w.Write([]byte(`{"success":true}`))
+}
+
+func newOutboxClient(t *testing.T, endpoint, dir string) *Client {
+	t.Helper()
+	o, err := outbox.Open(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("outbox.Open() error = %v", err)
+	}
+	c := NewWithConfig(&ClientConfig{Endpoint: endpoint, APIKey: "cw_valid", Timeout: time.Second},
+		"agent", zap.NewNop(), state.NewManagerWithStateDir(t.TempDir()))
+	c.SetOutbox(o)
+	return c
+}
+
+func sendEvent(c *Client, reason string) error {
+	return c.SyncCertManagerEvents(context.Background(), "cluster", []CertManagerEvent{{Reason: reason}})
+}
+
+func TestClient_OutboxReplaysInOrder(t *testing.T) {
+	api := &eventServer{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	dir := t.TempDir()
+
+	c := newOutboxClient(t, srv.URL, dir)
+	if err := sendEvent(c, "Failed"); !errors.Is(err, ErrQueued) {
+		t.Fatalf("SyncCertManagerEvents() error = %v, want ErrQueued", err)
+	}
+	if c.retryDelay != minRetryDelay {
+		t.Errorf("retry delay = %s, want %s", c.retryDelay, minRetryDelay)
+	}
+
+	// Within the retry delay the API isn't contacted
+	if err := sendEvent(c, "Issuing"); !errors.Is(err, ErrQueued) {
+		t.Fatalf("SyncCertManagerEvents() error = %v, want ErrQueued", err)
+	}
+	if api.requests != 1 {
+		t.Errorf("got %d requests, want none during the retry delay", api.requests)
+	}
+	if b := c.Backlog(); b.Messages != 2 || b.Bytes == 0 || b.NextRetry == nil {
+		t.Errorf("Backlog() = %+v, want both payloads waiting for the retry", b)
+	}
+
+	// The delay doubles with every failed attempt, made by RunOutbox as
+	// new payloads wait behind the queued ones
+	c.retryAt = time.Time{}
+	if err := sendEvent(c, "Retrying"); !errors.Is(err, ErrQueued) {
+		t.Fatalf("SyncCertManagerEvents() error = %v, want ErrQueued", err)
+	}
+	if _, err := c.deliverNext(context.Background(), nil); !errors.Is(err, ErrQueued) {
+		t.Fatalf("deliverNext() error = %v, want ErrQueued", err)
+	}
+	if c.retryDelay != 2*minRetryDelay {
+		t.Errorf("retry delay = %s, want %s", c.retryDelay, 2*minRetryDelay)
+	}
+
+	// After a restart, a new event waits behind the queued ones instead of
+	// replaying them, and RunOutbox delivers them all in order
+	api.mu.Lock()
+	api.status = 0
+	requests := api.requests
+	api.mu.Unlock()
+	c = newOutboxClient(t, srv.URL, dir)
+	if err := sendEvent(c, "Issued"); !errors.Is(err, ErrQueued) {
+		t.Fatalf("SyncCertManagerEvents() error = %v, want ErrQueued", err)
+	}
+	api.mu.Lock()
+	if api.requests != requests {
+		t.Errorf("got %d requests, want the backlog left to RunOutbox", api.requests-requests)
+	}
+	api.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.RunOutbox(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for c.outbox.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	want := []string{"Failed", "Issuing", "Retrying", "Issued"}
+	if len(api.reasons) != len(want) {
+		t.Fatalf("API received %v, want %v", api.reasons, want)
+	}
+	for i := range want {
+		if api.reasons[i] != want[i] {
+			t.Errorf("API received %v, want %v", api.reasons, want)
+			break
+		}
+	}
+	if c.outbox.Len() != 0 {
+		t.Errorf("%d payloads still queued", c.outbox.Len())
+	}
+	if b := c.Backlog(); b.Messages != 0 || b.NextRetry != nil {
+		t.Errorf("Backlog() = %+v, want none", b)
+	}
+}
+
+func TestClient_RunOutbox(t *testing.T) {
+	api := &eventServer{}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	dir := t.TempDir()
+
+	// Queued before a restart
+	queued, err := outbox.Open(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("outbox.Open() error = %v", err)
+	}
+	body, _ := json.Marshal(&CertManagerEventSyncRequest{Events: []CertManagerEvent{{Reason: "Failed"}}})
+	if _, err := queued.Push(kindCertManagerEvents, "/api/v1/agent/certmanager/events", body, false); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	c := newOutboxClient(t, srv.URL, dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.RunOutbox(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.outbox.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.reasons) != 1 || api.reasons[0] != "Failed" {
+		t.Errorf("API received %v, want the queued event", api.reasons)
+	}
+}
+
+func TestClient_OutboxDropsRejectedPayloads(t *testing.T) {
+	api := &eventServer{status: http.StatusBadRequest}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	c := newOutboxClient(t, srv.URL, t.TempDir())
+	if err := sendEvent(c, "Failed"); err == nil || errors.Is(err, ErrQueued) {
+		t.Errorf("SyncCertManagerEvents() error = %v, want the API error", err)
+	}
+	if c.outbox.Len() != 0 {
+		t.Errorf("%d payloads queued, want the rejected payload dropped", c.outbox.Len())
+	}
+}
+
+func TestClient_BacklogDuringDelivery(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-release
+		_, _ = w.Write([]byte(`{"success":true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := newOutboxClient(t, srv.URL, t.TempDir())
+	sent := make(chan error)
+	go func() { sent <- sendEvent(c, "Issuing") }()
+	defer func() {
+		close(release)
+		<-sent
+	}()
+	<-arrived
+
+	// The delivery holds c.deliverMu until the API answers
+	done := make(chan Backlog)
+	go func() { done <- c.Backlog() }()
+	select {
+	case b := <-done:
+		if b.Messages != 1 || b.NextRetry != nil {
+			t.Errorf("Backlog() = %+v, want the payload being delivered", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Backlog() blocked on the delivery in progress")
+	}
+}
@@ -3,6 +3,8 @@ package sync
 
 import (
 	"time"
+
+	"github.com/certwatch-app/cw-agent/internal/config"
 )
 
 // SyncRequest represents the agent sync request payload
@@ -23,23 +25,35 @@ type CertificateSyncData struct {
 	NotAfter          *time.Time       `json:"not_after,omitempty"`
 	LastCheckAt       *time.Time       `json:"last_check_at,omitempty"`
 	ChainValid        *bool            `json:"chain_valid,omitempty"`
+	PostQuantum       *bool            `json:"post_quantum,omitempty"` // nil without a TLS handshake
 	Hostname          string           `json:"hostname"`
+	Source            string           `json:"source"` // SourceConfig or SourceRemote
 	Notes             string           `json:"notes,omitempty"`
 	Subject           string           `json:"subject,omitempty"`
 	Issuer            string           `json:"issuer,omitempty"`
 	IssuerOrg         string           `json:"issuer_org,omitempty"`
 	SerialNumber      string           `json:"serial_number,omitempty"`
 	FingerprintSHA256 string           `json:"fingerprint_sha256,omitempty"`
+	SPKISHA256        string           `json:"spki_sha256,omitempty"`
 	LastError         string           `json:"last_error,omitempty"`
+	TLSVersion        string           `json:"tls_version,omitempty"`
+	KeyExchange       string           `json:"key_exchange,omitempty"`
 	Tags              []string         `json:"tags,omitempty"`
 	SANList           []string         `json:"san_list,omitempty"`
 	ChainIssues       []ChainIssueData `json:"chain_issues,omitempty"`
 	Port              int              `json:"port"`
 }
 
+// Target sources in the sync payload
+const (
+	SourceConfig = "config" // Defined in the agent's configuration files
+	SourceRemote = "remote" // Managed in CertWatch and fetched by the agent
+)
+
 // ChainIssueData represents a chain issue in the sync payload
 type ChainIssueData struct {
 	Type             string `json:"type"`
+	Severity         string `json:"severity,omitempty"`
 	Message          string `json:"message"`
 	CertificateIndex int    `json:"certificate_index,omitempty"`
 }
@@ -77,6 +91,15 @@ type APIError struct {
 	Message string `json:"message"`
 }
 
+// TargetsResponse represents the API response with the targets managed in
+// CertWatch for an agent
+// Fields are ordered for optimal memory alignment
+type TargetsResponse struct {
+	Error   *APIError             `json:"error,omitempty"`
+	Targets []config.RemoteTarget `json:"targets"`
+	Success bool                  `json:"success"`
+}
+
 // HeartbeatRequest represents the agent heartbeat request payload
 type HeartbeatRequest struct {
 	AgentID          string     `json:"agent_id"`
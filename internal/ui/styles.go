@@ -7,6 +7,7 @@ import (
 
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
 )
 
 // Theme colors - CertWatch brand colors
@@ -145,12 +146,10 @@ func RenderCommandHeader(context string) string {
 
 // RenderSection renders a section divider with title.
 func RenderSection(title string) string {
-	line := "─────────────────────────────────────────"
-	maxLen := 40 - len(title)
-	if maxLen < 0 {
-		maxLen = 0
-	}
-	return SectionStyle.Render("─── " + title + " " + line[:maxLen])
+	// Shorter titles get a longer line. "─" is 3 bytes in UTF-8, so the
+	// line is built from whole characters rather than sliced by bytes.
+	dashes := max(40-len(title), 0) / 3
+	return SectionStyle.Render("─── " + title + " " + strings.Repeat("─", dashes))
 }
 
 // RenderSuccess renders a success message with green checkmark.
@@ -201,6 +200,31 @@ func RenderWarningBox(title string, lines []string) string {
 	return WarningBoxStyle.Render(content)
 }
 
+// RenderTable renders rows under a bold header with a rounded border.
+// Cells may already be styled.
+func RenderTable(headers []string, rows [][]string) string {
+	return table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(MutedStyle).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		}).
+		Headers(headers...).
+		Rows(rows...).
+		Render()
+}
+
+// RenderPassFail renders a PASS or FAIL table cell
+func RenderPassFail(passed bool) string {
+	if passed {
+		return SuccessStyle.Render("PASS")
+	}
+	return ErrorStyle.Render("FAIL")
+}
+
 // TruncateID truncates a UUID for display (shows first 12 chars + ...)
 func TruncateID(id string) string {
 	if len(id) > 12 {